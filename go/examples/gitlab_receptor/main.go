@@ -71,9 +71,9 @@ func (r *Receptor) ReportBatch(credentials interface{}, evidenceChan chan []*rec
 }
 
 // GetEvidenceInfo returns a list of all the possible evidence created. The return value should not have any actual
-// rows or source data, just the Caption and Description
+// rows or source data, just the Caption and Description, and the RowType of structured evidence
 func (r *Receptor) GetEvidenceInfo(credentials interface{}) (evidences []*receptor_sdk.Evidence) {
-	return
+	return receptorPackage.GetEvidenceInfoImpl()
 }
 
 func (r *Receptor) Configure(credentials interface{}) (config *receptor_v1.ReceptorConfiguration, err error) {
@@ -78,6 +78,12 @@ func ReportImpl(token string, groupId string) (evidences []*receptor_sdk.Evidenc
 	return report.Evidences, err
 }
 
+func GetEvidenceInfoImpl() (evidences []*receptor_sdk.Evidence) {
+	evidence := newMemberEvidence()
+	evidence.RowType = TrusteroGitLabUser{}
+	return []*receptor_sdk.Evidence{evidence}
+}
+
 func ConfigureImpl(token string, groupId string) (config *receptor_v1.ReceptorConfiguration, err error) {
 	return nil, nil
 }
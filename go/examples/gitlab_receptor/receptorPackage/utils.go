@@ -26,8 +26,7 @@ const (
 // NOTE: Any time any queries that need to be made for an evidence object should
 // be recorded in the evidence object via the "AddSource" command
 func getMemberEvidence(git *gitlab.Client, groupId string) (evidence *receptor_sdk.Evidence, err error) {
-	evidence = receptor_sdk.NewEvidence(serviceName, memberEntity, serviceName+" Group Members",
-		"List of GitLab group and inherited members includes whether a member has multi-factor authentication on and if they have group admin privilege.")
+	evidence = newMemberEvidence()
 	var (
 		user    *gitlab.User
 		group   *gitlab.Group
@@ -55,6 +54,13 @@ func getMemberEvidence(git *gitlab.Client, groupId string) (evidence *receptor_s
 	return
 }
 
+// newMemberEvidence returns the group members evidence without rows.  GetEvidenceInfo declares it with its
+// TrusteroGitLabUser row type.
+func newMemberEvidence() *receptor_sdk.Evidence {
+	return receptor_sdk.NewEvidence(serviceName, memberEntity, serviceName+" Group Members",
+		"List of GitLab group and inherited members includes whether a member has multi-factor authentication on and if they have group admin privilege.")
+}
+
 // TrusteroGitLabUser represents a type of evidence to emit to Trustero as part of a finding.
 // A list of users returned from the GitLab API will be converted to this type
 // and added to an evidence object. Trustero will use the "display" and "order"
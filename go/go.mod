@@ -3,6 +3,7 @@ module github.com/trustero/api/go
 go 1.21
 
 require (
+	github.com/klauspost/compress v1.17.11
 	github.com/rs/zerolog v1.31.0
 	github.com/spf13/cobra v1.5.0
 	github.com/stretchr/testify v1.8.1
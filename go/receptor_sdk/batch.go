@@ -0,0 +1,49 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package receptor_sdk
+
+import (
+	"context"
+	"errors"
+)
+
+// EmitChannel adapts a channel based ReportBatch to an [Emitter].  EmitChannel calls reportBatch with a channel
+// and emits each batch received from it until reportBatch closes the channel or ctx is done.  Batches that fail
+// to be emitted don't stop reportBatch, which can't observe the failure, and their errors are returned joined
+// once the channel is closed.  An error returned by several batches, such as a failed upload, is returned once.
+//
+// When ctx is done, EmitChannel returns without waiting for reportBatch.  Batches sent afterwards are discarded
+// until reportBatch closes the channel, so a reportBatch that doesn't observe ctx isn't blocked sending forever.
+func EmitChannel(ctx context.Context, emitter Emitter, reportBatch func(evidenceChan chan []*Evidence)) error {
+	evidenceChan := make(chan []*Evidence)
+	go reportBatch(evidenceChan)
+
+	var errs []error
+	for {
+		select {
+		case <-ctx.Done():
+			go func() {
+				for range evidenceChan {
+				}
+			}()
+			return errors.Join(append(errs, ctx.Err())...)
+		case evidences, ok := <-evidenceChan:
+			if !ok {
+				return errors.Join(errs...)
+			}
+			if err := emitter.Emit(evidences...); err != nil && !containsError(errs, err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+}
+
+func containsError(errs []error, err error) bool {
+	for _, e := range errs {
+		if errors.Is(e, err) {
+			return true
+		}
+	}
+	return false
+}
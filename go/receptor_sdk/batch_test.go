@@ -0,0 +1,62 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package receptor_sdk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// emitterFunc is an [Emitter] calling a function.
+type emitterFunc func(evidences ...*Evidence) error
+
+func (f emitterFunc) Emit(evidences ...*Evidence) error { return f(evidences...) }
+
+func TestEmitChannel(t *testing.T) {
+	failed := errors.New("upload failed")
+	var emitted int
+	emitter := emitterFunc(func(evidences ...*Evidence) error {
+		emitted += len(evidences)
+		return failed
+	})
+
+	err := EmitChannel(context.Background(), emitter, func(evidenceChan chan []*Evidence) {
+		for i := 0; i < 3; i++ {
+			evidenceChan <- []*Evidence{{Caption: "Users"}}
+		}
+		close(evidenceChan)
+	})
+	// every batch is emitted, and an error returned by several batches is returned once
+	if emitted != 3 || !errors.Is(err, failed) || err.Error() != failed.Error() {
+		t.Errorf("EmitChannel emitted %d evidences and returned %v, want 3 and %v once", emitted, err, failed)
+	}
+}
+
+func TestEmitChannelCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	emitter := emitterFunc(func(evidences ...*Evidence) error {
+		cancel()
+		return nil
+	})
+
+	// a reportBatch ignoring ctx keeps sending after EmitChannel returns
+	done := make(chan struct{})
+	err := EmitChannel(ctx, emitter, func(evidenceChan chan []*Evidence) {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			evidenceChan <- []*Evidence{{Caption: "Users"}}
+		}
+		close(evidenceChan)
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("EmitChannel = %v, want context.Canceled", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reportBatch is blocked sending after EmitChannel returned")
+	}
+}
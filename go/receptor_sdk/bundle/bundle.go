@@ -0,0 +1,70 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+// Package bundle reads and writes offline evidence bundles.  A bundle records the Trustero GRPC requests of a
+// receptor scan so they can be carried to a host that can reach Trustero and replayed later.
+//
+// A bundle is a tar archive.  Each recorded request is stored as a separate entry in the order it was made:
+// Discovered and Report requests as marshaled protobuf messages, and StreamReport uploads as their complete
+// multipart payload.  The last entry, manifest.json, lists every other entry with its size and SHA-256 hash so
+// the bundle can be verified before it's uploaded.
+package bundle
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// ManifestName is the name of the manifest entry in a bundle.
+const ManifestName = "manifest.json"
+
+// Version is the bundle format version written by this package.
+const Version = 1
+
+// Entry kinds
+const (
+	KindDiscovered = "discovered" // A marshaled receptor_v1.ServiceEntities sent with Discovered.
+	KindReport     = "report"     // A marshaled receptor_v1.Finding sent with Report.
+	KindStream     = "stream"     // A multipart payload sent with StreamReport.
+)
+
+var (
+	// ErrHashMismatch is returned when the content of a bundle entry doesn't match its manifest hash.
+	ErrHashMismatch = errors.New("bundle entry hash mismatch")
+	// ErrSizeMismatch is returned when the size of a bundle entry doesn't match its manifest size.
+	ErrSizeMismatch = errors.New("bundle entry size mismatch")
+	// ErrMissingEntry is returned when an entry listed in the manifest isn't in the bundle.
+	ErrMissingEntry = errors.New("bundle entry missing")
+	// ErrUnexpectedEntry is returned when a bundle holds an entry not listed in the manifest.
+	ErrUnexpectedEntry = errors.New("bundle entry not in manifest")
+)
+
+// Manifest describes the content of a bundle.
+type Manifest struct {
+	Version      int       `json:"version"`       // Version of the bundle format.
+	ReceptorType string    `json:"receptor_type"` // ReceptorType of the receptor that recorded the bundle.
+	DiscoveryId  string    `json:"discovery_id"`  // DiscoveryId of the recorded scan.
+	CreatedAt    time.Time `json:"created_at"`    // CreatedAt is when the bundle was written.
+	Entries      []*Entry  `json:"entries"`       // Entries in the order they were recorded.
+}
+
+// Entry describes a recorded request in a bundle.
+type Entry struct {
+	Name           string `json:"name"`                      // Name of the entry in the tar archive.
+	Kind           string `json:"kind"`                      // Kind of the request, one of the Kind constants.
+	ContentType    string `json:"content_type,omitempty"`    // ContentType of a stream entry's multipart.
+	IdempotencyKey string `json:"idempotency_key,omitempty"` // IdempotencyKey the request was recorded with.
+	Size           int64  `json:"size"`                      // Size of the entry in bytes.
+	Hash           string `json:"hash"`                      // Hash is the UrlSafe base64 encoded SHA-256 of the entry.
+}
+
+func encodeHash(sum []byte) string {
+	return base64.URLEncoding.EncodeToString(sum)
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return encodeHash(sum[:])
+}
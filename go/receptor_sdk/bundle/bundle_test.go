@@ -0,0 +1,196 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// testStream is a stream entry whose size isn't a multiple of the tar block size, so the entries following it start
+// after padding.
+var testStream = []byte(strings.Repeat("--boundary\r\npart\r\n", 100))
+
+// writeTestBundle writes a bundle with a message entry, a stream entry and another message entry.
+func writeTestBundle(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "evidence.trb")
+	w, err := Create(path, "trr-test", "discovery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.AddMessage(KindDiscovered, "discovered-key", wrapperspb.String("services")); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.AddStream("multipart/tr-mixed; boundary=boundary", "stream-key", int64(len(testStream)), bytes.NewReader(testStream)); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.AddMessage(KindReport, "report-key", wrapperspb.String("finding")); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func openTestBundle(t *testing.T, path string) *Reader {
+	t.Helper()
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestRoundTrip(t *testing.T) {
+	r := openTestBundle(t, writeTestBundle(t))
+	if err := r.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := r.Manifest()
+	if manifest.Version != Version || manifest.ReceptorType != "trr-test" || manifest.DiscoveryId != "discovery" {
+		t.Errorf("manifest = %+v, want version %d of trr-test with discovery", manifest, Version)
+	}
+	if len(manifest.Entries) != 3 {
+		t.Fatalf("manifest entries = %d, want 3", len(manifest.Entries))
+	}
+	for i, want := range []struct {
+		name, kind, key string
+		content         []byte
+	}{
+		{"0001-discovered.pb", KindDiscovered, "discovered-key", marshal(t, wrapperspb.String("services"))},
+		{"0002-stream.mpart", KindStream, "stream-key", testStream},
+		{"0003-report.pb", KindReport, "report-key", marshal(t, wrapperspb.String("finding"))},
+	} {
+		entry := manifest.Entries[i]
+		if entry.Name != want.name || entry.Kind != want.kind || entry.IdempotencyKey != want.key {
+			t.Errorf("entry %d = %+v, want %s of kind %s with key %s", i, entry, want.name, want.kind, want.key)
+		}
+		content, err := r.ReadAll(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(content, want.content) {
+			t.Errorf("entry %s content = %q, want %q", entry.Name, content, want.content)
+		}
+	}
+	if contentType := manifest.Entries[1].ContentType; contentType != "multipart/tr-mixed; boundary=boundary" {
+		t.Errorf("stream entry content type = %q", contentType)
+	}
+
+	// an entry can be read again, as when an upload is retried
+	stream, err := r.Open(manifest.Entries[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.Copy(io.Discard, stream); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := io.ReadAll(stream); !bytes.Equal(content, testStream) {
+		t.Errorf("stream entry read again = %d bytes, want %d", len(content), len(testStream))
+	}
+}
+
+func TestVerify(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		tamper func(data []byte) []byte
+		want   error
+	}{
+		{"content", func(data []byte) []byte {
+			return bytes.Replace(data, []byte("\r\npart\r\n"), []byte("\r\ntart\r\n"), 1)
+		}, ErrHashMismatch},
+		{"unexpected entry", func(data []byte) []byte {
+			return appendEntry(t, data, "0004-report.pb")
+		}, ErrUnexpectedEntry},
+	} {
+		path := writeTestBundle(t)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(path, test.tamper(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err = openTestBundle(t, path).Verify(); !errors.Is(err, test.want) {
+			t.Errorf("%s: Verify = %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	// an entry of the manifest missing from the bundle
+	r := openTestBundle(t, writeTestBundle(t))
+	delete(r.sections, "0002-stream.mpart")
+	if err := r.Verify(); !errors.Is(err, ErrMissingEntry) {
+		t.Errorf("missing entry: Verify = %v, want ErrMissingEntry", err)
+	}
+}
+
+func TestOpenWithoutManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "evidence.trb")
+	if err := os.WriteFile(path, appendEntry(t, nil, "0001-report.pb"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); !errors.Is(err, ErrMissingEntry) {
+		t.Errorf("Open of a bundle without a manifest = %v, want ErrMissingEntry", err)
+	}
+}
+
+// appendEntry returns a tar archive of the entries of data, if any, followed by an entry of the given name.
+func appendEntry(t *testing.T, data []byte, name string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if data != nil {
+		tr := tar.NewReader(bytes.NewReader(data))
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if err = tw.WriteHeader(header); err != nil {
+				t.Fatal(err)
+			}
+			if _, err = io.Copy(tw, tr); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	content := []byte("extra")
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Size: int64(len(content)), Mode: 0600}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func marshal(t *testing.T, m proto.Message) []byte {
+	t.Helper()
+	data, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
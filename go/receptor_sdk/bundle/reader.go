@@ -0,0 +1,142 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package bundle
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Reader reads the entries of a bundle file.
+type Reader struct {
+	file     *os.File
+	manifest Manifest
+	sections map[string]*io.SectionReader
+	order    []string
+}
+
+// Open opens the bundle file at path and reads its manifest.
+func Open(path string) (r *Reader, err error) {
+	var file *os.File
+	if file, err = os.Open(path); err != nil {
+		return
+	}
+	r = &Reader{file: file, sections: map[string]*io.SectionReader{}}
+	if err = r.index(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read bundle %s: %w", path, err)
+	}
+	return
+}
+
+// Manifest returns the manifest of the bundle.
+func (r *Reader) Manifest() Manifest {
+	return r.manifest
+}
+
+// Open returns a reader of the content of a bundle entry.
+func (r *Reader) Open(entry *Entry) (io.ReadSeeker, error) {
+	section, ok := r.sections[entry.Name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMissingEntry, entry.Name)
+	}
+	return io.NewSectionReader(section, 0, section.Size()), nil
+}
+
+// ReadAll returns the content of a bundle entry.
+func (r *Reader) ReadAll(entry *Entry) (data []byte, err error) {
+	var content io.ReadSeeker
+	if content, err = r.Open(entry); err != nil {
+		return
+	}
+	return io.ReadAll(content)
+}
+
+// Verify checks that the bundle holds exactly the entries listed in its manifest and that the size and hash of
+// each entry match the manifest.
+func (r *Reader) Verify() (err error) {
+	listed := map[string]bool{}
+	for _, entry := range r.manifest.Entries {
+		listed[entry.Name] = true
+		var content io.ReadSeeker
+		if content, err = r.Open(entry); err != nil {
+			return
+		}
+		hash := sha256.New()
+		var size int64
+		if size, err = io.Copy(hash, content); err != nil {
+			return fmt.Errorf("failed to read bundle entry %s: %v", entry.Name, err)
+		}
+		if size != entry.Size {
+			return fmt.Errorf("%w: %s is %d bytes, expected %d", ErrSizeMismatch, entry.Name, size, entry.Size)
+		}
+		if computed := encodeHash(hash.Sum(nil)); computed != entry.Hash {
+			return fmt.Errorf("%w: %s hash is %s, expected %s", ErrHashMismatch, entry.Name, computed, entry.Hash)
+		}
+	}
+	for _, name := range r.order {
+		if !listed[name] {
+			return fmt.Errorf("%w: %s", ErrUnexpectedEntry, name)
+		}
+	}
+	return
+}
+
+// Close closes the bundle file.
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
+
+// index records the offset and size of every entry of the tar archive and decodes the manifest.
+func (r *Reader) index() (err error) {
+	counter := &countingReader{r: r.file}
+	archive := tar.NewReader(counter)
+	var manifest *io.SectionReader
+	for {
+		var header *tar.Header
+		if header, err = archive.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		// tar.Reader reads the header block of an entry and nothing further, so the entry content starts at the
+		// current offset.
+		section := io.NewSectionReader(r.file, counter.n, header.Size)
+		if header.Name == ManifestName {
+			manifest = section
+			continue
+		}
+		r.sections[header.Name] = section
+		r.order = append(r.order, header.Name)
+	}
+
+	if manifest == nil {
+		return fmt.Errorf("%w: %s", ErrMissingEntry, ManifestName)
+	}
+	if err = json.NewDecoder(manifest).Decode(&r.manifest); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", ManifestName, err)
+	}
+	if r.manifest.Version > Version {
+		return fmt.Errorf("unsupported bundle version %d", r.manifest.Version)
+	}
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (n int, err error) {
+	n, err = c.r.Read(p)
+	c.n += int64(n)
+	return
+}
@@ -0,0 +1,141 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package bundle
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Writer records requests into a bundle file.  Writer is safe for concurrent use.
+type Writer struct {
+	mu       sync.Mutex
+	file     *os.File
+	tar      *tar.Writer
+	manifest Manifest
+}
+
+// Create creates a bundle file at path, truncating it if it exists.
+func Create(path, receptorType, discoveryId string) (w *Writer, err error) {
+	var file *os.File
+	if file, err = os.Create(path); err != nil {
+		return
+	}
+	w = &Writer{
+		file: file,
+		tar:  tar.NewWriter(file),
+		manifest: Manifest{
+			Version:      Version,
+			ReceptorType: receptorType,
+			DiscoveryId:  discoveryId,
+			CreatedAt:    time.Now().UTC(),
+			Entries:      []*Entry{},
+		},
+	}
+	return
+}
+
+// AddMessage records a protobuf message sent to Trustero as an entry of the given kind.
+func (w *Writer) AddMessage(kind, idempotencyKey string, m proto.Message) (err error) {
+	var data []byte
+	if data, err = proto.Marshal(m); err != nil {
+		return fmt.Errorf("failed to marshal %s: %v", kind, err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entry := w.newEntry(kind, "pb")
+	entry.IdempotencyKey = idempotencyKey
+	entry.Size = int64(len(data))
+	entry.Hash = hashBytes(data)
+	return w.writeEntry(entry, data)
+}
+
+// AddStream records a multipart StreamReport payload of the given size read from r.
+func (w *Writer) AddStream(contentType, idempotencyKey string, size int64, r io.Reader) (err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	entry := w.newEntry(KindStream, "mpart")
+	entry.ContentType = contentType
+	entry.IdempotencyKey = idempotencyKey
+	entry.Size = size
+
+	if err = w.tar.WriteHeader(w.header(entry.Name, size)); err != nil {
+		return
+	}
+	hash := sha256.New()
+	if _, err = io.CopyN(io.MultiWriter(w.tar, hash), r, size); err != nil {
+		return fmt.Errorf("failed to write stream entry %s: %v", entry.Name, err)
+	}
+	entry.Hash = encodeHash(hash.Sum(nil))
+	w.manifest.Entries = append(w.manifest.Entries, entry)
+	return
+}
+
+// Manifest returns the manifest of the entries recorded so far.
+func (w *Writer) Manifest() Manifest {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	manifest := w.manifest
+	manifest.Entries = append([]*Entry{}, w.manifest.Entries...)
+	return manifest
+}
+
+// Close writes the manifest and closes the bundle file.
+func (w *Writer) Close() (err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var data []byte
+	if data, err = json.MarshalIndent(w.manifest, "", "  "); err != nil {
+		return
+	}
+	if err = w.tar.WriteHeader(w.header(ManifestName, int64(len(data)))); err == nil {
+		_, err = w.tar.Write(data)
+	}
+	if err == nil {
+		err = w.tar.Close()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return
+}
+
+func (w *Writer) newEntry(kind, ext string) *Entry {
+	return &Entry{
+		Name: fmt.Sprintf("%04d-%s.%s", len(w.manifest.Entries)+1, kind, ext),
+		Kind: kind,
+	}
+}
+
+func (w *Writer) writeEntry(entry *Entry, data []byte) (err error) {
+	if err = w.tar.WriteHeader(w.header(entry.Name, entry.Size)); err != nil {
+		return
+	}
+	if _, err = w.tar.Write(data); err != nil {
+		return
+	}
+	w.manifest.Entries = append(w.manifest.Entries, entry)
+	return
+}
+
+func (w *Writer) header(name string, size int64) *tar.Header {
+	return &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0600,
+		ModTime:  w.manifest.CreatedAt,
+	}
+}
@@ -25,6 +25,10 @@ import (
 
 var ServerConn *ServerConnection
 
+// DialOptions are appended to the default options of every Trustero GRPC connection.  Options set here take
+// precedence over the defaults, allowing a test harness to redirect connections to an in-process server.
+var DialOptions []grpc.DialOption
+
 type ServerConnection struct {
 	Connection    *grpc.ClientConn
 	TlsDialOption grpc.DialOption
@@ -66,6 +70,7 @@ func (sc *ServerConnection) Dial(token, host string, port int) (err error) {
 		grpc.WithStreamInterceptor(logStreamCall),
 		grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(2048 * 1024 * 1024)),
 	}
+	opts = append(opts, DialOptions...)
 
 	// Connect to local server
 	addr := host + ":" + strconv.Itoa(port)
@@ -5,6 +5,7 @@ package client
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 	"unsafe"
 )
@@ -16,11 +17,16 @@ const (
 	letterIdxMax  = 63 / letterIdxBits   // # of letter indices fitting in 63 bits
 )
 
-var src = rand.NewSource(time.Now().UnixNano())
+var (
+	src   = rand.NewSource(time.Now().UnixNano())
+	srcMu sync.Mutex // rand.Source isn't safe for concurrent use
+)
 
 // RandString returns a random string of length n.
 func RandString(n int) string {
 	b := make([]byte, n)
+	srcMu.Lock()
+	defer srcMu.Unlock()
 	// A src.Int63() generates 63 random bits, enough for letterIdxMax characters!
 	for i, cache, remain := n-1, src.Int63(), letterIdxMax; i >= 0; {
 		if remain == 0 {
@@ -0,0 +1,103 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// IdempotencyKeyHeader is the GRPC metadata key carrying the idempotency key of a request.  Trustero uses the
+// key to deduplicate requests replayed by a retry.
+const IdempotencyKeyHeader = "x-idempotency-key"
+
+// RetryPolicy configures how failed Trustero GRPC calls are retried.
+type RetryPolicy struct {
+	MaxAttempts    int           // Maximum number of attempts including the first.  Values below 1 mean 1.
+	InitialBackoff time.Duration // Backoff before the first retry.
+	MaxBackoff     time.Duration // Upper bound of the backoff between retries.
+	Multiplier     float64       // Backoff growth factor applied after each retry.
+	Jitter         float64       // Random fraction, between 0 and 1, the backoff is varied by.
+	RetryableCodes []codes.Code  // GRPC status codes that are retried.
+}
+
+// DefaultRetryPolicy is the retry policy used for all Trustero GRPC calls made by the CLI framework.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+	RetryableCodes: []codes.Code{codes.Unavailable, codes.ResourceExhausted, codes.Aborted},
+}
+
+// Do invokes call until it succeeds, returns a non-retryable error, the policy's attempts are exhausted, or ctx
+// is done.  The name of the call is used for logging only.
+func (p RetryPolicy) Do(ctx context.Context, name string, call func(ctx context.Context) error) (err error) {
+	for attempt := 1; ; attempt++ {
+		if err = call(ctx); err == nil || attempt >= p.MaxAttempts || !p.Retryable(err) {
+			return
+		}
+
+		backoff := p.Backoff(attempt)
+		log.Warn().Err(err).Msgf("retrying %s in %v, attempt %d of %d", name, backoff, attempt+1, p.MaxAttempts)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Retryable returns true if err has one of the policy's retryable GRPC status codes.
+func (p RetryPolicy) Retryable(err error) bool {
+	code := status.Code(err)
+	for _, c := range p.RetryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns the jittered backoff to wait after the given attempt failed.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if maxBackoff := float64(p.MaxBackoff); p.MaxBackoff > 0 && backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	backoff *= 1 + p.Jitter*(2*rand.Float64()-1)
+	return time.Duration(backoff)
+}
+
+// WithIdempotencyKey returns a context sending key as the [IdempotencyKeyHeader] GRPC metadata.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, IdempotencyKeyHeader, key)
+}
+
+// IdempotencyKey derives a stable idempotency key from the given parts, typically a discovery ID, an evidence
+// key, and a content hash.
+func IdempotencyKey(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,138 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/trustero/api/go/receptor_sdk"
+	"github.com/trustero/api/go/receptor_sdk/bundle"
+	"github.com/trustero/api/go/receptor_sdk/client"
+	"github.com/trustero/api/go/receptor_v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// bundleReceptorClient implements a [receptor_v1.ReceptorClient] recording Discovered, Report and StreamReport
+// requests to an offline bundle.  The bundle is replayed to Trustero later with the upload command.  All other
+// requests are accepted without being recorded.
+type bundleReceptorClient struct {
+	writer *bundle.Writer
+}
+
+func newBundleReceptorClient(path string) (rc *bundleReceptorClient, err error) {
+	var writer *bundle.Writer
+	if writer, err = bundle.Create(path, GetParsedReceptorType(), receptor_sdk.DiscoveryId); err != nil {
+		return nil, fmt.Errorf("failed to create bundle: %w", err)
+	}
+	return &bundleReceptorClient{writer: writer}, nil
+}
+
+func (rc *bundleReceptorClient) Verified(_ context.Context, _ *receptor_v1.Credential, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func (rc *bundleReceptorClient) GetConfiguration(_ context.Context, _ *receptor_v1.ReceptorOID, _ ...grpc.CallOption) (*receptor_v1.ReceptorConfiguration, error) {
+	return &receptor_v1.ReceptorConfiguration{}, nil
+}
+
+func (rc *bundleReceptorClient) Discovered(_ context.Context, in *receptor_v1.ServiceEntities, _ ...grpc.CallOption) (s *wrapperspb.StringValue, err error) {
+	key := client.IdempotencyKey(receptor_sdk.DiscoveryId, "Discovered", messageHash(in))
+	if err = rc.writer.AddMessage(bundle.KindDiscovered, key, in); err != nil {
+		return
+	}
+	return &wrapperspb.StringValue{}, nil
+}
+
+func (rc *bundleReceptorClient) Report(_ context.Context, in *receptor_v1.Finding, _ ...grpc.CallOption) (s *wrapperspb.StringValue, err error) {
+	key := client.IdempotencyKey(in.GetDiscoveryId(), "Report", messageHash(in))
+	if err = rc.writer.AddMessage(bundle.KindReport, key, in); err != nil {
+		return
+	}
+	return &wrapperspb.StringValue{}, nil
+}
+
+func (rc *bundleReceptorClient) StreamReport(ctx context.Context, _ ...grpc.CallOption) (receptor_v1.Receptor_StreamReportClient, error) {
+	return &bundleReportStream{ctx: ctx, writer: rc.writer}, nil
+}
+
+func (rc *bundleReceptorClient) Notify(_ context.Context, _ *receptor_v1.JobResult, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func (rc *bundleReceptorClient) SetConfiguration(_ context.Context, _ *receptor_v1.ReceptorConfiguration, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+// Close writes the bundle's manifest and closes the bundle file.
+func (rc *bundleReceptorClient) Close() error {
+	return rc.writer.Close()
+}
+
+// bundleReportStream buffers a streamed multipart in a temporary file and records it to the bundle, with the
+// idempotency key of the stream's context, when the stream is closed.
+type bundleReportStream struct {
+	ctx         context.Context
+	writer      *bundle.Writer
+	contentType string
+	buffer      *os.File
+	size        int64
+}
+
+func (s *bundleReportStream) Send(chunk *receptor_v1.ReportChunk) (err error) {
+	if chunk.GetIsBoundary() {
+		s.contentType = string(chunk.GetContent())
+		return
+	}
+	if s.buffer == nil {
+		if s.buffer, err = os.CreateTemp("", "bundle-stream-report_*.tmp"); err != nil {
+			return
+		}
+	}
+	n, err := s.buffer.Write(chunk.GetContent())
+	s.size += int64(n)
+	return
+}
+
+func (s *bundleReportStream) CloseAndRecv() (res *receptor_v1.ReportResponse, err error) {
+	if s.buffer == nil {
+		return nil, errors.New("no multipart content streamed")
+	}
+	defer func() {
+		s.buffer.Close()
+		os.Remove(s.buffer.Name())
+	}()
+
+	if _, err = s.buffer.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	var key string
+	if md, ok := metadata.FromOutgoingContext(s.ctx); ok {
+		if values := md.Get(client.IdempotencyKeyHeader); len(values) > 0 {
+			key = values[len(values)-1]
+		}
+	}
+	if err = s.writer.AddStream(s.contentType, key, s.size, s.buffer); err != nil {
+		return
+	}
+	return &receptor_v1.ReportResponse{Status: "ok"}, nil
+}
+
+func (s *bundleReportStream) Header() (metadata.MD, error) { return metadata.MD{}, nil }
+func (s *bundleReportStream) Trailer() metadata.MD         { return metadata.MD{} }
+func (s *bundleReportStream) CloseSend() error             { return nil }
+func (s *bundleReportStream) Context() context.Context     { return s.ctx }
+func (s *bundleReportStream) SendMsg(m any) error {
+	if chunk, ok := m.(*receptor_v1.ReportChunk); ok {
+		return s.Send(chunk)
+	}
+	return fmt.Errorf("unexpected message type %T", m)
+}
+func (s *bundleReportStream) RecvMsg(_ any) error { return io.EOF }
@@ -45,13 +45,14 @@ func (v *confi) setup() {
 	v.cmd.FParseErrWhitelist.UnknownFlags = true
 
 	addGrpcFlags(v.cmd)
+	addStrFlag(v.cmd, &receptor_sdk.SummaryFile, "summary-file", "", "", "Path of a JSON file to write the run summary of the command to")
 }
 
 // Cobra executes this function on verify command.
 func configure(_ *cobra.Command, args []string) (err error) {
 	// Run receptor's Verify function and report results to Trustero
-	err = invokeWithContext(args[0],
-		func(rc receptor_v1.ReceptorClient, credentials interface{}, config interface{}) (err error) {
+	err = invokeWithContext("configure", args[0],
+		func(ctx context.Context, rc receptor_v1.ReceptorClient, credentials interface{}, config interface{}) (err error) {
 			// Send the config back to Trustero if there is additional config
 			if config != nil {
 				jsonBytes, err := json.Marshal(receptorImpl.GetConfigObj(credentials))
@@ -62,11 +63,13 @@ func configure(_ *cobra.Command, args []string) (err error) {
 					println(string(jsonBytes))
 
 				} else {
-					_, err = rc.SetConfiguration(context.Background(), &receptor_v1.ReceptorConfiguration{
+					done := summary.phase("configure")
+					_, err = rc.SetConfiguration(ctx, &receptor_v1.ReceptorConfiguration{
 						ReceptorObjectId: receptor_sdk.ReceptorId,
 						Config:           string(jsonBytes),
 						ModelId:          receptorImpl.GetReceptorType(),
 					})
+					done(err)
 				}
 			}
 			return
@@ -0,0 +1,103 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/trustero/api/go/receptor_sdk"
+	"github.com/trustero/api/go/receptor_v1"
+)
+
+// notifyTimeout bounds the Notify call made after a command's context is already canceled.
+const notifyTimeout = 10 * time.Second
+
+// commandContext returns the context of a receptor command.  The context is canceled on SIGINT or SIGTERM and,
+// if the --timeout flag is set, once the timeout expires.
+func commandContext() (ctx context.Context, cancel context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if receptor_sdk.Timeout <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancelTimeout := context.WithTimeout(ctx, time.Duration(receptor_sdk.Timeout)*time.Second)
+	cancel = func() {
+		cancelTimeout()
+		stop()
+	}
+	return
+}
+
+// callWithContext invokes fn and returns the context's error if ctx is done before fn returns.  It's used to
+// call receptors that don't implement [receptor_sdk.ContextReceptor] and can't observe cancellation.  In that
+// case fn keeps running in the background until the receptor process exits.
+func callWithContext[T any](ctx context.Context, fn func() (T, error)) (res T, err error) {
+	type result struct {
+		res T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		r, e := fn()
+		done <- result{r, e}
+	}()
+
+	select {
+	case r := <-done:
+		return r.res, r.err
+	case <-ctx.Done():
+		err = ctx.Err()
+		return
+	}
+}
+
+func verifyReceptor(ctx context.Context, credentials interface{}, config interface{}) (ok bool, err error) {
+	if cr, isCtx := receptorImpl.(receptor_sdk.ContextReceptor); isCtx {
+		return cr.VerifyContext(ctx, credentials, config)
+	}
+	return callWithContext(ctx, func() (bool, error) {
+		return receptorImpl.Verify(credentials, config)
+	})
+}
+
+func discoverReceptor(ctx context.Context, credentials interface{}, config interface{}) (services []*receptor_v1.ServiceEntity, err error) {
+	if cr, isCtx := receptorImpl.(receptor_sdk.ContextReceptor); isCtx {
+		return cr.DiscoverContext(ctx, credentials, config)
+	}
+	return callWithContext(ctx, func() ([]*receptor_v1.ServiceEntity, error) {
+		return receptorImpl.Discover(credentials, config)
+	})
+}
+
+func reportReceptor(ctx context.Context, credentials interface{}, config interface{}) (evidences []*receptor_sdk.Evidence, err error) {
+	if cr, isCtx := receptorImpl.(receptor_sdk.ContextReceptor); isCtx {
+		return cr.ReportContext(ctx, credentials, config)
+	}
+	return callWithContext(ctx, func() ([]*receptor_sdk.Evidence, error) {
+		return receptorImpl.Report(credentials, config)
+	})
+}
+
+func reportBatchReceptor(ctx context.Context, credentials interface{}, config interface{}, evidenceChan chan []*receptor_sdk.Evidence) {
+	if cr, isCtx := receptorImpl.(receptor_sdk.ContextReceptor); isCtx {
+		cr.ReportBatchContext(ctx, credentials, config, evidenceChan)
+		return
+	}
+	receptorImpl.ReportBatch(credentials, evidenceChan)
+}
+
+// reportBatches invokes the receptor's [receptor_sdk.BatchReceptor.ReportBatches], or adapts its channel based
+// ReportBatch to emitter if the receptor doesn't implement [receptor_sdk.BatchReceptor].
+func reportBatches(ctx context.Context, credentials interface{}, config interface{}, emitter receptor_sdk.Emitter) error {
+	if br, isBatch := receptorImpl.(receptor_sdk.BatchReceptor); isBatch {
+		return br.ReportBatches(ctx, credentials, config, emitter)
+	}
+	return receptor_sdk.EmitChannel(ctx, emitter, func(evidenceChan chan []*receptor_sdk.Evidence) {
+		reportBatchReceptor(ctx, credentials, config, evidenceChan)
+	})
+}
@@ -0,0 +1,549 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/trustero/api/go/receptor_sdk/bundle"
+	"github.com/trustero/api/go/receptor_sdk/multipartkit"
+	"github.com/trustero/api/go/receptor_v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	diffUse   = "diff <old_scan> <new_scan>"
+	diffShort = "Compare the evidence of two dryrun scans or offline bundles"
+	diffLong  = `
+Compare the evidence of two scans, each either the '--output-dir' directory of
+a dryrun scan or an offline bundle file recorded by 'scan --bundle'.  Evidence
+is compared by caption.  Rows are matched by their entity instance id and
+reported as added, removed or modified, with the old and new values of each
+modified column.  Documents are matched by name and compared by hash.  Evidence
+reported by the new scan as unchanged since it was last reported is unchanged.
+The differences are printed as text, or as JSON if '--diff-format json' is
+specified.`
+
+	// Evidence and document statuses
+	diffAdded     = "added"
+	diffRemoved   = "removed"
+	diffModified  = "modified"
+	diffUnchanged = "unchanged"
+	diffUnknown   = "unknown" // reported as unchanged by the old scan, so its content can't be compared
+)
+
+var (
+	diffFormat   string
+	diffTimezone string
+)
+
+type differ struct {
+	cmd *cobra.Command
+}
+
+func (d *differ) getCommand() *cobra.Command {
+	return d.cmd
+}
+
+func (d *differ) setup() {
+	d.cmd = &cobra.Command{
+		Use:          diffUse,
+		Short:        diffShort,
+		Long:         diffLong,
+		Args:         cobra.ExactArgs(2),
+		RunE:         diff,
+		SilenceUsage: true,
+	}
+	d.cmd.FParseErrWhitelist.UnknownFlags = true
+	addStrFlag(d.cmd, &diffFormat, "diff-format", "", "text", "Format of the differences: text or json")
+	addStrFlag(d.cmd, &diffTimezone, "diff-timezone", "", "UTC", "IANA time zone of the timestamps in the differences")
+}
+
+// Cobra executes this function on diff command.
+func diff(_ *cobra.Command, args []string) (err error) {
+	if diffFormat != "text" && diffFormat != "json" {
+		return fmt.Errorf("unknown diff format %q, expected text or json", diffFormat)
+	}
+	table := &receptor_v1.Table{}
+	if table.Options.Location, err = time.LoadLocation(diffTimezone); err != nil {
+		return fmt.Errorf("invalid --diff-timezone flag: %w", err)
+	}
+
+	var old, new *scanSnapshot
+	if old, err = loadScanSnapshot(args[0]); err != nil {
+		return
+	}
+	if new, err = loadScanSnapshot(args[1]); err != nil {
+		return
+	}
+
+	differences := diffScans(table, old, new)
+	differences.Old, differences.New = args[0], args[1]
+	if diffFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(differences)
+	}
+	printScanDiff(os.Stdout, differences)
+	return
+}
+
+// scanSnapshot is the evidence reported by a scan, by caption.
+type scanSnapshot struct {
+	evidences map[string]*evidenceSnapshot
+}
+
+// evidenceSnapshot is an evidence of a scan.  The rows of an evidence reported in several findings, such as the
+// batches of a batch receptor, are merged.
+type evidenceSnapshot struct {
+	rows           *receptor_v1.Struct
+	documents      map[string]string // document name to hash
+	unchangedSince time.Time         // set if the evidence was reported as an "unchanged since" marker
+}
+
+// marker returns whether the evidence was only reported as an "unchanged since" marker, without content.
+func (e *evidenceSnapshot) marker() bool {
+	return e != nil && !e.unchangedSince.IsZero() && e.rows == nil && len(e.documents) == 0
+}
+
+// loadScanSnapshot loads the evidence of the dryrun scan output directory or the offline bundle at path.
+func loadScanSnapshot(path string) (snapshot *scanSnapshot, err error) {
+	var info os.FileInfo
+	if info, err = os.Stat(path); err != nil {
+		return
+	}
+	snapshot = &scanSnapshot{evidences: map[string]*evidenceSnapshot{}}
+	if info.IsDir() {
+		err = snapshot.loadDryRun(path)
+	} else {
+		err = snapshot.loadBundle(path)
+	}
+	return
+}
+
+// loadDryRun loads the finding records of the last dryrun scan written to an output directory, as listed in its
+// manifest.  A directory written without a manifest has all its finding records loaded.
+func (s *scanSnapshot) loadDryRun(dir string) (err error) {
+	var paths []string
+	if paths, err = dryRunPaths(dir); err != nil {
+		return
+	}
+
+	for _, path := range paths {
+		var data []byte
+		if data, err = os.ReadFile(path); err != nil {
+			return
+		}
+		var record dryRunRecord
+		if err = json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("failed to decode finding %s: %w", path, err)
+		}
+		finding := &receptor_v1.Finding{}
+		if err = protojson.Unmarshal(record.Finding, finding); err != nil {
+			return fmt.Errorf("failed to decode finding %s: %w", path, err)
+		}
+		s.add(finding, record.Documents)
+	}
+	return
+}
+
+// dryRunPaths returns the paths of the finding records of the last dryrun scan written to dir.
+func dryRunPaths(dir string) (paths []string, err error) {
+	var data []byte
+	if data, err = os.ReadFile(filepath.Join(dir, dryRunManifestName)); err == nil {
+		var manifest dryRunManifest
+		if err = json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to decode manifest %s: %w", filepath.Join(dir, dryRunManifestName), err)
+		}
+		for _, name := range manifest.Findings {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+		return
+	} else if !os.IsNotExist(err) {
+		return
+	}
+
+	if paths, err = filepath.Glob(filepath.Join(dir, "finding-*.json")); err != nil {
+		return
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no findings in %s, expected the --output-dir of a dryrun scan", dir)
+	}
+	sort.Strings(paths)
+	return
+}
+
+// loadBundle loads the Report and StreamReport requests recorded in a verified offline bundle.
+func (s *scanSnapshot) loadBundle(path string) (err error) {
+	var reader *bundle.Reader
+	if reader, err = bundle.Open(path); err != nil {
+		return
+	}
+	defer reader.Close()
+	if err = reader.Verify(); err != nil {
+		return fmt.Errorf("bundle %s failed verification: %w", path, err)
+	}
+
+	for _, entry := range reader.Manifest().Entries {
+		switch entry.Kind {
+		case bundle.KindReport:
+			var data []byte
+			if data, err = reader.ReadAll(entry); err != nil {
+				return
+			}
+			finding := &receptor_v1.Finding{}
+			if err = proto.Unmarshal(data, finding); err != nil {
+				return fmt.Errorf("failed to decode bundle entry %s: %w", entry.Name, err)
+			}
+			s.add(finding, nil)
+		case bundle.KindStream:
+			var content io.Reader
+			if content, err = reader.Open(entry); err != nil {
+				return
+			}
+			finding, documents, streamErr := readStreamedFinding(entry.ContentType, content)
+			if streamErr != nil {
+				return fmt.Errorf("failed to decode bundle entry %s: %w", entry.Name, streamErr)
+			}
+			s.add(finding, documents)
+		}
+	}
+	return
+}
+
+// readStreamedFinding reads the Finding part of a streamed multipart and hashes its documents.
+func readStreamedFinding(contentType string, r io.Reader) (finding *receptor_v1.Finding, documents []dryRunDocument, err error) {
+	var (
+		boundary string
+		reader   *multipartkit.MultipartReader
+		part     *multipartkit.Part
+	)
+	if boundary, err = multipartkit.ParseBoundary(contentType); err != nil {
+		return
+	}
+	if reader, err = multipartkit.NewMultipartReader(r, boundary, 0); err != nil {
+		return
+	}
+	for {
+		if part, err = reader.Next(); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			return
+		}
+
+		if part.Kind == multipartkit.PartProtobuf {
+			if part.Name == "receptor_v1.Finding" {
+				finding = &receptor_v1.Finding{}
+				if err = part.Decode(finding); err != nil {
+					return
+				}
+			}
+			continue
+		}
+		var size int64
+		if size, err = io.Copy(io.Discard, part); err != nil {
+			return
+		}
+		documents = append(documents, dryRunDocument{Name: part.Name, FileName: part.FileName, Size: size, Hash: part.ComputedHash()})
+	}
+	if finding == nil {
+		err = errors.New("no receptor_v1.Finding part in multipart")
+	}
+	return
+}
+
+// add adds the evidence of a finding.  The documents of a streamed finding belong to its only evidence.
+func (s *scanSnapshot) add(finding *receptor_v1.Finding, documents []dryRunDocument) {
+	for _, evidence := range finding.GetEvidences() {
+		snapshot, ok := s.evidences[evidence.GetCaption()]
+		if !ok {
+			snapshot = &evidenceSnapshot{documents: map[string]string{}}
+			s.evidences[evidence.GetCaption()] = snapshot
+		}
+		if since := evidence.GetUnchangedSince(); since != nil {
+			snapshot.unchangedSince = since.AsTime()
+		}
+		if rows := evidence.GetStruct(); rows != nil {
+			snapshot.addRows(rows)
+		}
+		for _, document := range documents {
+			snapshot.documents[document.Name] = document.Hash
+		}
+		documents = nil
+	}
+}
+
+func (e *evidenceSnapshot) addRows(rows *receptor_v1.Struct) {
+	if e.rows == nil {
+		e.rows = &receptor_v1.Struct{ColDisplayNames: map[string]string{}, ColTags: map[string]string{}}
+	}
+	e.rows.Rows = append(e.rows.Rows, rows.GetRows()...)
+	for key, name := range rows.GetColDisplayNames() {
+		e.rows.ColDisplayNames[key] = name
+	}
+	for tag, key := range rows.GetColTags() {
+		e.rows.ColTags[tag] = key
+	}
+	if len(e.rows.ColDisplayOrder) == 0 {
+		e.rows.ColDisplayOrder = rows.GetColDisplayOrder()
+	}
+}
+
+// scanDiff is the difference between the evidence of two scans.
+type scanDiff struct {
+	Old       string          `json:"old"`
+	New       string          `json:"new"`
+	Evidences []*evidenceDiff `json:"evidences"` // Evidence of both scans, ordered by caption.
+}
+
+type evidenceDiff struct {
+	Caption           string          `json:"caption"`
+	Status            string          `json:"status"`                        // added, removed, modified, unchanged or unknown
+	OldUnchangedSince *time.Time      `json:"old_unchanged_since,omitempty"` // set if the old scan reported an unchanged since marker
+	NewUnchangedSince *time.Time      `json:"new_unchanged_since,omitempty"` // set if the new scan reported an unchanged since marker
+	AddedRows         []*diffRow      `json:"added_rows,omitempty"`
+	RemovedRows       []*diffRow      `json:"removed_rows,omitempty"`
+	ModifiedRows      []*modifiedRow  `json:"modified_rows,omitempty"`
+	UnchangedRows     int             `json:"unchanged_rows"`
+	Documents         []*documentDiff `json:"documents,omitempty"` // Documents added, removed or modified.
+}
+
+type diffRow struct {
+	EntityInstanceId string        `json:"entity_instance_id"`
+	Columns          []*diffColumn `json:"columns"`
+}
+
+type modifiedRow struct {
+	EntityInstanceId string        `json:"entity_instance_id"`
+	Changes          []*diffColumn `json:"changes"`
+}
+
+// diffColumn is a column of an added or removed row, with its Value, or a changed column of a modified row, with
+// its Old and New values.
+type diffColumn struct {
+	Key    string      `json:"key"`
+	Header string      `json:"header"`
+	Value  interface{} `json:"value,omitempty"`
+	Old    interface{} `json:"old,omitempty"`
+	New    interface{} `json:"new,omitempty"`
+
+	text, oldText, newText string
+}
+
+type documentDiff struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"` // added, removed or modified
+	OldHash string `json:"old_hash,omitempty"`
+	NewHash string `json:"new_hash,omitempty"`
+}
+
+// diffScans compares the evidence of two scans.  Values are formatted with table's options.
+func diffScans(table *receptor_v1.Table, old, new *scanSnapshot) *scanDiff {
+	captions := map[string]bool{}
+	for caption := range old.evidences {
+		captions[caption] = true
+	}
+	for caption := range new.evidences {
+		captions[caption] = true
+	}
+	sorted := make([]string, 0, len(captions))
+	for caption := range captions {
+		sorted = append(sorted, caption)
+	}
+	sort.Strings(sorted)
+
+	differences := &scanDiff{Evidences: []*evidenceDiff{}}
+	for _, caption := range sorted {
+		differences.Evidences = append(differences.Evidences, diffEvidence(table, caption, old.evidences[caption], new.evidences[caption]))
+	}
+	return differences
+}
+
+// diffEvidence compares two versions of an evidence, either of which may be nil.  An "unchanged since" marker of
+// the new scan has the content of the old scan's evidence.  The content of an old scan's marker is unknown.
+func diffEvidence(table *receptor_v1.Table, caption string, old, new *evidenceSnapshot) *evidenceDiff {
+	d := &evidenceDiff{Caption: caption}
+	if old.marker() {
+		since := old.unchangedSince.In(table.Options.Location)
+		d.OldUnchangedSince = &since
+		if new != nil && !new.marker() {
+			d.Status = diffUnknown
+			return d
+		}
+	}
+	if new.marker() {
+		since := new.unchangedSince.In(table.Options.Location)
+		d.NewUnchangedSince = &since
+		if old != nil {
+			new = &evidenceSnapshot{rows: old.rows, documents: old.documents}
+		}
+	}
+	var oldRows, newRows *receptor_v1.Struct
+	oldDocuments, newDocuments := map[string]string{}, map[string]string{}
+	if old != nil {
+		oldRows, oldDocuments = old.rows, old.documents
+	}
+	if new != nil {
+		newRows, newDocuments = new.rows, new.documents
+	}
+
+	rows := receptor_v1.DiffStructs(oldRows, newRows)
+	d.UnchangedRows = rows.Unchanged
+	for _, row := range rows.Added {
+		d.AddedRows = append(d.AddedRows, newDiffRow(table, rows.Columns, row))
+	}
+	for _, row := range rows.Removed {
+		d.RemovedRows = append(d.RemovedRows, newDiffRow(table, rows.Columns, row))
+	}
+	for _, row := range rows.Modified {
+		modified := &modifiedRow{EntityInstanceId: row.EntityInstanceId}
+		for _, change := range row.Changes {
+			modified.Changes = append(modified.Changes, &diffColumn{
+				Key:     change.Column.Key,
+				Header:  change.Column.Header,
+				Old:     table.JSONValue(change.Old),
+				New:     table.JSONValue(change.New),
+				oldText: formatDiffValue(table, change.Old),
+				newText: formatDiffValue(table, change.New),
+			})
+		}
+		d.ModifiedRows = append(d.ModifiedRows, modified)
+	}
+
+	for _, name := range sortedDocumentNames(oldDocuments, newDocuments) {
+		oldHash, inOld := oldDocuments[name]
+		newHash, inNew := newDocuments[name]
+		switch {
+		case !inOld:
+			d.Documents = append(d.Documents, &documentDiff{Name: name, Status: diffAdded, NewHash: newHash})
+		case !inNew:
+			d.Documents = append(d.Documents, &documentDiff{Name: name, Status: diffRemoved, OldHash: oldHash})
+		case oldHash != newHash:
+			d.Documents = append(d.Documents, &documentDiff{Name: name, Status: diffModified, OldHash: oldHash, NewHash: newHash})
+		}
+	}
+
+	switch {
+	case old == nil:
+		d.Status = diffAdded
+	case new == nil:
+		d.Status = diffRemoved
+	case !rows.Empty() || len(d.Documents) > 0:
+		d.Status = diffModified
+	default:
+		d.Status = diffUnchanged
+	}
+	return d
+}
+
+func newDiffRow(table *receptor_v1.Table, columns []receptor_v1.Column, row *receptor_v1.Row) *diffRow {
+	d := &diffRow{EntityInstanceId: row.GetEntityInstanceId(), Columns: []*diffColumn{}}
+	for _, column := range columns {
+		value, ok := row.GetCols()[column.Key]
+		if !ok {
+			continue
+		}
+		d.Columns = append(d.Columns, &diffColumn{
+			Key:    column.Key,
+			Header: column.Header,
+			Value:  table.JSONValue(value),
+			text:   formatDiffValue(table, value),
+		})
+	}
+	return d
+}
+
+// formatDiffValue formats a value as text, quoted so empty and missing values can be told apart.
+func formatDiffValue(table *receptor_v1.Table, value *receptor_v1.Value) string {
+	if value.GetValueType() == nil {
+		return "(none)"
+	}
+	return fmt.Sprintf("%q", table.Format(value))
+}
+
+func sortedDocumentNames(old, new map[string]string) []string {
+	names := make([]string, 0, len(old)+len(new))
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// printScanDiff prints the differences between two scans as text.  Unchanged evidence is counted, not listed.
+func printScanDiff(w io.Writer, differences *scanDiff) {
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", differences.Old, differences.New)
+	unchanged := 0
+	for _, evidence := range differences.Evidences {
+		if evidence.Status == diffUnchanged {
+			unchanged++
+			continue
+		}
+		if evidence.Status == diffUnknown {
+			fmt.Fprintf(w, "Evidence %q %s: reported unchanged since %s by the old scan, so its rows weren't compared\n",
+				evidence.Caption, evidence.Status, evidence.OldUnchangedSince.Format(time.RFC3339))
+			continue
+		}
+		fmt.Fprintf(w, "Evidence %q %s: %d added, %d removed, %d modified, %d unchanged rows\n", evidence.Caption,
+			evidence.Status, len(evidence.AddedRows), len(evidence.RemovedRows), len(evidence.ModifiedRows), evidence.UnchangedRows)
+		if evidence.NewUnchangedSince != nil {
+			fmt.Fprintf(w, "  reported unchanged since %s\n", evidence.NewUnchangedSince.Format(time.RFC3339))
+		}
+		for _, row := range evidence.AddedRows {
+			printDiffRow(w, "+", row)
+		}
+		for _, row := range evidence.RemovedRows {
+			printDiffRow(w, "-", row)
+		}
+		for _, row := range evidence.ModifiedRows {
+			fmt.Fprintf(w, "  ~ %s\n", diffRowId(row.EntityInstanceId))
+			for _, change := range row.Changes {
+				fmt.Fprintf(w, "      %s: %s -> %s\n", change.Header, change.oldText, change.newText)
+			}
+		}
+		for _, document := range evidence.Documents {
+			switch document.Status {
+			case diffAdded:
+				fmt.Fprintf(w, "  + document %s %s\n", document.Name, document.NewHash)
+			case diffRemoved:
+				fmt.Fprintf(w, "  - document %s %s\n", document.Name, document.OldHash)
+			default:
+				fmt.Fprintf(w, "  ~ document %s %s -> %s\n", document.Name, document.OldHash, document.NewHash)
+			}
+		}
+	}
+	fmt.Fprintf(w, "%d evidences unchanged\n", unchanged)
+}
+
+func printDiffRow(w io.Writer, sign string, row *diffRow) {
+	columns := make([]string, 0, len(row.Columns))
+	for _, column := range row.Columns {
+		columns = append(columns, column.Header+"="+column.text)
+	}
+	fmt.Fprintf(w, "  %s %s: %s\n", sign, diffRowId(row.EntityInstanceId), strings.Join(columns, ", "))
+}
+
+func diffRowId(id string) string {
+	if len(id) == 0 {
+		return "(no id)"
+	}
+	return id
+}
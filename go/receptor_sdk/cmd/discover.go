@@ -9,13 +9,17 @@ import (
 	"github.com/trustero/api/go/receptor_v1"
 )
 
-func discover(rc receptor_v1.ReceptorClient, credentials interface{}, config interface{}) (err error) {
+func discover(ctx context.Context, rc receptor_v1.ReceptorClient, credentials interface{}, config interface{}) (err error) {
 
 	// Discover service entities
 	var discovered []*receptor_v1.ServiceEntity
-	if discovered, err = receptorImpl.Discover(credentials, config); err != nil {
+	done := summary.phase("discover")
+	discovered, err = discoverReceptor(ctx, credentials, config)
+	done(err)
+	if err != nil {
 		return
 	}
+	summary.discovered(discovered)
 
 	// Report discovered services to Trustero
 	var services receptor_v1.ServiceEntities
@@ -24,6 +28,6 @@ func discover(rc receptor_v1.ReceptorClient, credentials interface{}, config int
 	services.Entities = discovered
 
 	// Report discovered services to Trustero
-	_, err = rc.Discovered(context.Background(), &services)
+	_, err = rc.Discovered(ctx, &services)
 	return
 }
@@ -3,6 +3,7 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -47,7 +48,7 @@ func printEvidenceInfo(cmd *cobra.Command, args []string) (err error) {
 		credentialObj interface{}
 	)
 	var allEvs []EvidenceInfo
-	credentialStr, err = getCredentialStringFromCLI()
+	credentialStr, err = getCredentialStringFromCLI(context.Background())
 	if err == nil && credentialStr != "" {
 		credentialObj, err = unmarshalCredentials(credentialStr, receptorImpl.GetCredentialObj())
 	}
@@ -17,6 +17,9 @@ const (
 	controlTestField = "check"
 	methodField      = "method"
 	inputTypeField   = "input_type"
+	secretField      = "secret"
+	requiredField    = "required"
+	patternField     = "pattern"
 )
 
 func expandFieldTag(field reflect.StructField) (tags map[string]string) {
@@ -27,21 +30,12 @@ func expandFieldTag(field reflect.StructField) (tags map[string]string) {
 	return
 }
 
+// getTags splits a 'trustero' tag into its sub-tags, separated by ';'.  A sub-tag value, such as a pattern, may
+// contain a ';' escaped as '\;'.
 func getTags(str string) (m map[string]string) {
 	m = map[string]string{}
 
-	if len(str) == 0 {
-		return
-	}
-
-	if !strings.Contains(str, ";") {
-		k, v := getKVPair(str)
-		m[k] = v
-		return
-	}
-
-	pairs := strings.Split(str, ";")
-	for _, pair := range pairs {
+	for _, pair := range splitTag(str) {
 		if len(pair) > 0 {
 			k, v := getKVPair(pair)
 			m[k] = v
@@ -50,15 +44,27 @@ func getTags(str string) (m map[string]string) {
 	return
 }
 
-func getKVPair(str string) (k, v string) {
-	if strings.Contains(str, ":") {
-		kv := strings.Split(str, ":")
-		k = kv[0]
-		v = kv[1]
-	} else {
-		k = str
-		v = ""
+// splitTag splits a tag at each ';' not escaped as '\;', and unescapes the escaped ones.
+func splitTag(str string) (pairs []string) {
+	var pair strings.Builder
+	for i := 0; i < len(str); i++ {
+		switch {
+		case str[i] == '\\' && i+1 < len(str) && str[i+1] == ';':
+			pair.WriteByte(';')
+			i++
+		case str[i] == ';':
+			pairs = append(pairs, pair.String())
+			pair.Reset()
+		default:
+			pair.WriteByte(str[i])
+		}
 	}
+	return append(pairs, pair.String())
+}
+
+// getKVPair splits a sub-tag at its first colon, so values such as patterns may contain colons.
+func getKVPair(str string) (k, v string) {
+	k, v, _ = strings.Cut(str, ":")
 	return
 }
 
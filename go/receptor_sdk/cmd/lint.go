@@ -0,0 +1,351 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/trustero/api/go/receptor_sdk"
+)
+
+const (
+	lintUse   = "lint"
+	lintShort = "Validate the receptor's struct tags and descriptors"
+	lintLong  = `
+Validate the receptor's credential struct, the row types of the evidence
+returned by GetEvidenceInfo, the config descriptor and the auth methods.  Lint
+command prints every problem found with the name of the field at fault, and
+fails if any problem is found.  To lint an evidence's row type, GetEvidenceInfo
+may include a zero value of the row type in the evidence's Rows.`
+)
+
+var (
+	credentialSubTags = []string{displayField, placeholderField, methodField, inputTypeField, secretField, requiredField, patternField}
+	rowSubTags        = []string{idField, displayField, orderField, controlTestField}
+)
+
+type linter struct {
+	cmd *cobra.Command
+}
+
+func (l *linter) getCommand() *cobra.Command {
+	return l.cmd
+}
+
+func (l *linter) setup() {
+	l.cmd = &cobra.Command{
+		Use:          lintUse,
+		Short:        lintShort,
+		Long:         lintLong,
+		Args:         cobra.NoArgs,
+		RunE:         lint,
+		SilenceUsage: true,
+	}
+	l.cmd.FParseErrWhitelist.UnknownFlags = true
+}
+
+// Cobra executes this function on lint command.
+func lint(_ *cobra.Command, _ []string) error {
+	problems := ValidateReceptor(receptorImpl)
+	for _, problem := range problems {
+		println(problem.String())
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problems in receptor %s", len(problems), GetParsedReceptorType())
+	}
+	println("No problems found in receptor " + GetParsedReceptorType())
+	return nil
+}
+
+// Problem is a problem with a receptor found by [ValidateReceptor].
+type Problem struct {
+	Subject string // Subject names what has the problem.  For example, "credential field Token".
+	Message string // Message describes the problem.
+}
+
+// String returns the problem as "subject: message".
+func (p Problem) String() string {
+	return p.Subject + ": " + p.Message
+}
+
+// ValidateReceptor validates the 'trustero' struct tags and descriptors of a receptor and returns every problem
+// found.  It inspects the credential struct returned by GetCredentialObj, the row types of the evidence returned by
+// GetEvidenceInfo, the config descriptor returned by GetConfigObjDesc and the auth methods returned by
+// GetAuthMethods.  Problems that would fail silently at run time, such as two row fields with the same order or a
+// credential field that isn't a string, are reported along with the name of the field at fault.
+func ValidateReceptor(r receptor_sdk.Receptor) (problems []Problem) {
+	l := &receptorLint{}
+	methods := l.authMethods(r.GetAuthMethods())
+	credentials := r.GetCredentialObj()
+	l.credentials(credentials, methods)
+	l.evidences(r.GetEvidenceInfo(credentials))
+	l.config(r.GetConfigObjDesc())
+	return l.problems
+}
+
+type receptorLint struct {
+	problems []Problem
+}
+
+func (l *receptorLint) report(subject, format string, args ...interface{}) {
+	l.problems = append(l.problems, Problem{Subject: subject, Message: fmt.Sprintf(format, args...)})
+}
+
+// credentials validates the credential struct.  CLI flags are generated for its fields, and credentials are decoded
+// into it, so it must be a pointer to a struct of exported string fields.
+func (l *receptorLint) credentials(credentials interface{}, methods map[string]bool) {
+	t := reflect.TypeOf(credentials)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		l.report("credentials", "GetCredentialObj must return a pointer to a struct, got %v", t)
+		return
+	}
+	t = t.Elem()
+
+	flags := map[string]string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		subject := "credential field " + field.Name
+		if !field.IsExported() {
+			l.report(subject, "field isn't exported, so credentials can't be decoded into it")
+		}
+		if field.Type.Kind() != reflect.String {
+			l.report(subject, "field type %v isn't a string, credential fields must be strings", field.Type)
+		}
+		if other, ok := flags[strings.ToLower(field.Name)]; ok {
+			l.report(subject, "field's command line flag --%s is the same as field %s's", strings.ToLower(field.Name), other)
+		}
+		flags[strings.ToLower(field.Name)] = field.Name
+
+		tags := l.tags(subject, field, credentialSubTags)
+		if _, ok := tags[displayField]; !ok && strings.ToLower(field.Name) != "oauth" {
+			l.report(subject, "field has no display sub-tag, so it isn't shown in Trustero")
+		}
+		if method, ok := tags[methodField]; ok && methods != nil && !methods[method] {
+			l.report(subject, "method %q isn't one of the auth methods returned by GetAuthMethods", method)
+		}
+		if pattern, ok := tags[patternField]; ok {
+			if _, err := regexp.Compile(pattern); err != nil {
+				l.report(subject, "pattern sub-tag isn't a valid regular expression: %s", err)
+			}
+		}
+	}
+}
+
+// evidences validates the evidence captions and the row types the evidence declares, see [evidenceRowTypes].
+func (l *receptorLint) evidences(evidences []*receptor_sdk.Evidence) {
+	captions := map[string]bool{}
+	linted := map[reflect.Type]bool{}
+	for i, evidence := range evidences {
+		if evidence == nil {
+			l.report(fmt.Sprintf("evidence %d", i), "GetEvidenceInfo returned a nil evidence")
+			continue
+		}
+		subject := fmt.Sprintf("evidence %q", evidence.Caption)
+		if len(evidence.Caption) == 0 {
+			l.report(fmt.Sprintf("evidence %d", i), "evidence has no caption")
+		} else if captions[evidence.Caption] {
+			l.report(subject, "caption is used by more than one evidence")
+		}
+		captions[evidence.Caption] = true
+
+		for _, t := range evidenceRowTypes(evidence) {
+			if !linted[t] {
+				linted[t] = true
+				l.rowType(subject, t)
+			}
+		}
+	}
+}
+
+// evidenceRowTypes returns the row types of an evidence: the type of its RowType, dereferenced if it's a pointer,
+// followed by the other types of its rows.
+func evidenceRowTypes(evidence *receptor_sdk.Evidence) (types []reflect.Type) {
+	seen := map[reflect.Type]bool{}
+	add := func(t reflect.Type) {
+		if t != nil && !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	if t := reflect.TypeOf(evidence.RowType); t != nil && t.Kind() == reflect.Pointer {
+		add(t.Elem())
+	} else {
+		add(t)
+	}
+	for _, row := range evidence.Rows {
+		add(reflect.TypeOf(row))
+	}
+	return
+}
+
+// rowType validates an evidence row type as converted by [ExtractMetaData] and [RowToStructRow].
+func (l *receptorLint) rowType(evidence string, t reflect.Type) {
+	if t.Kind() != reflect.Struct {
+		l.report(evidence, "row type %v must be a struct", t)
+		return
+	}
+
+	var ids []string
+	orders := map[int]string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		subject := fmt.Sprintf("%s row field %s.%s", evidence, t.Name(), field.Name)
+		if !field.IsExported() {
+			l.report(subject, "field isn't exported, so it can't be read")
+			continue
+		}
+		if !supportedRowFieldType(field.Type) {
+			l.report(subject, "field type %v isn't supported, its value won't be reported", field.Type)
+		}
+
+		tags := l.tags(subject, field, rowSubTags)
+		if _, ok := tags[idField]; ok {
+			ids = append(ids, field.Name)
+			if field.Type.Kind() != reflect.String {
+				l.report(subject, "id field type %v isn't a string, the id field must be a string", field.Type)
+			}
+		}
+		order, ok := tags[orderField]
+		if !ok {
+			l.report(subject, "field has no order sub-tag, so it isn't displayed")
+			continue
+		}
+		n, err := strconv.Atoi(order)
+		if err != nil || n < 1 {
+			l.report(subject, "order %q must be an integer starting at 1", order)
+			continue
+		}
+		if other, ok := orders[n]; ok {
+			l.report(subject, "order %d is the same as field %s's, one of the fields isn't displayed", n, other)
+			continue
+		}
+		orders[n] = field.Name
+	}
+
+	subject := fmt.Sprintf("%s row type %s", evidence, t.Name())
+	switch len(ids) {
+	case 0:
+		l.report(subject, "row type has no id field, rows are reported without an entity instance id")
+	case 1:
+	default:
+		l.report(subject, "row type has more than one id field: %s", strings.Join(ids, ", "))
+	}
+}
+
+// supportedRowFieldType returns whether [RowToStructRow] converts fields of type t.
+func supportedRowFieldType(t reflect.Type) bool {
+	return supportedValueType(t, map[reflect.Type]bool{})
+}
+
+// tags returns the field's 'trustero' sub-tags, reporting sub-tags not in known.
+func (l *receptorLint) tags(subject string, field reflect.StructField, known []string) map[string]string {
+	tags := expandFieldTag(field)
+	var unknown []string
+	for tag := range tags {
+		if !contains(known, tag) {
+			unknown = append(unknown, tag)
+		}
+	}
+	sort.Strings(unknown)
+	for _, tag := range unknown {
+		l.report(subject, "unknown sub-tag %q, expected one of %s", tag, strings.Join(known, ", "))
+	}
+	return tags
+}
+
+// authMethods validates the auth methods and returns their values, or nil if they aren't [receptor_sdk.AuthMethod]s.
+func (l *receptorLint) authMethods(authMethods interface{}) (values map[string]bool) {
+	var methods []receptor_sdk.AuthMethod
+	switch m := authMethods.(type) {
+	case nil:
+		return nil
+	case []receptor_sdk.AuthMethod:
+		methods = m
+	case []*receptor_sdk.AuthMethod:
+		for _, method := range m {
+			if method != nil {
+				methods = append(methods, *method)
+			}
+		}
+	default:
+		l.marshals("auth methods", authMethods)
+		return nil
+	}
+
+	values = map[string]bool{}
+	for i, method := range methods {
+		subject := fmt.Sprintf("auth method %d", i)
+		if len(method.Value) == 0 {
+			l.report(subject, "auth method has no value")
+		} else if values[method.Value] {
+			l.report(subject, "value %q is used by more than one auth method", method.Value)
+		}
+		if len(method.Display) == 0 {
+			l.report(subject, "auth method has no display name")
+		}
+		values[method.Value] = true
+	}
+	return
+}
+
+// config validates the config descriptor.
+func (l *receptorLint) config(desc interface{}) {
+	var configs []receptor_sdk.Config
+	switch c := desc.(type) {
+	case nil:
+		return
+	case receptor_sdk.Config:
+		configs = []receptor_sdk.Config{c}
+	case *receptor_sdk.Config:
+		configs = []receptor_sdk.Config{*c}
+	case []receptor_sdk.Config:
+		configs = c
+	default:
+		l.marshals("config descriptor", desc)
+		return
+	}
+
+	for _, config := range configs {
+		fields := map[string]bool{}
+		for i, field := range config.Fields {
+			subject := fmt.Sprintf("config %q field %d", config.Title, i)
+			if len(field.Field) == 0 {
+				l.report(subject, "config field has no field name")
+			} else if fields[field.Field] {
+				l.report(subject, "field name %q is used by more than one config field", field.Field)
+			}
+			fields[field.Field] = true
+			if len(field.Display) == 0 {
+				l.report(subject, "config field has no display name")
+			}
+			if strings.EqualFold(field.InputType, "select") && field.Options == nil {
+				l.report(subject, "select config field has no options")
+			}
+		}
+	}
+	l.marshals("config descriptor", desc)
+}
+
+// marshals reports a descriptor that can't be encoded to JSON for Trustero.
+func (l *receptorLint) marshals(subject string, v interface{}) {
+	if _, err := json.Marshal(v); err != nil {
+		l.report(subject, "can't be encoded to JSON: %v", err)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
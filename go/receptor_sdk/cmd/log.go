@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"sync"
 	"time"
 
 	"github.com/natefinch/lumberjack"
@@ -16,6 +17,7 @@ import (
 	grpczerolog "github.com/cheapRoc/grpc-zerolog"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/trustero/api/go/receptor_sdk"
 	"google.golang.org/grpc/grpclog"
 )
 
@@ -61,17 +63,45 @@ func initLog(levelStr string, logFile string) {
 		writers = append(writers, rollingLog(logFile, 3, 1, 1))
 	}
 
-	mw := io.MultiWriter(writers...)
+	// scrub secrets from log lines before they're written
+	mw := receptor_sdk.DefaultRedactor.Writer(io.MultiWriter(writers...))
 
 	// set global logger to our setup
 	log.Logger = zerolog.New(mw).With().Timestamp().Logger()
 	log.Logger = log.Logger.With().Caller().Logger()
 
 	// setup grpc logging
-	grpczlog := log.Logger.With().CallerWithSkipFrameCount(7).Logger()
+	grpcLog.set(mw)
+}
+
+// grpcLog is the writer of the grpc logger.  The grpc logger is set once, before any grpc connection or server
+// runs, since grpclog.SetLoggerV2 isn't safe to call after.  Each command run, as with ExecuteArgs, points it at
+// its log writers instead.
+var grpcLog = &logWriter{w: io.Discard}
+
+func init() {
+	grpczlog := zerolog.New(grpcLog).With().Timestamp().CallerWithSkipFrameCount(7).Logger()
 	grpclog.SetLoggerV2(grpczerolog.New(grpczlog.With().Str("workstation", "grpc").Logger()))
 }
 
+// logWriter is an io.Writer whose destination can be replaced while it's written to.
+type logWriter struct {
+	mu sync.RWMutex
+	w  io.Writer
+}
+
+func (l *logWriter) set(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w = w
+}
+
+func (l *logWriter) Write(p []byte) (int, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.w.Write(p)
+}
+
 func rollingLog(filePath string, maxBackups, maxSize, maxAge int) io.Writer {
 	folder := path.Dir(filePath)
 	if err := os.MkdirAll(folder, 0744); err != nil {
@@ -5,11 +5,21 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/trustero/api/go/receptor_sdk"
+	"github.com/trustero/api/go/receptor_sdk/bundle"
 	"github.com/trustero/api/go/receptor_v1"
 	receptor "github.com/trustero/api/go/receptor_v1"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 	"gopkg.in/yaml.v2"
@@ -17,11 +27,17 @@ import (
 
 type mockReceptorClient struct{}
 
+// printMu serializes the output of mock calls made concurrently, for example by --upload-concurrency workers.
+var printMu sync.Mutex
+
 const header = "========\nReceptor."
 const footer = "========\n\n"
 
 // Verified implements a mock [receptor_v1.Receptor.Verified] method for testing.
 func (rc *mockReceptorClient) Verified(ctx context.Context, in *receptor.Credential, opts ...grpc.CallOption) (e *emptypb.Empty, err error) {
+	printMu.Lock()
+	defer printMu.Unlock()
+
 	e = &emptypb.Empty{}
 	println(header + "Verified(...)")
 	var yamld string
@@ -34,6 +50,9 @@ func (rc *mockReceptorClient) Verified(ctx context.Context, in *receptor.Credent
 
 // Verified implements a mock [receptor_v1.Receptor.GetConfiguration] method for testing.
 func (rc *mockReceptorClient) GetConfiguration(ctx context.Context, in *receptor.ReceptorOID, opts ...grpc.CallOption) (c *receptor.ReceptorConfiguration, err error) {
+	printMu.Lock()
+	defer printMu.Unlock()
+
 	c = &receptor.ReceptorConfiguration{
 		ReceptorObjectId:       "",
 		Credential:             "",
@@ -52,6 +71,9 @@ func (rc *mockReceptorClient) GetConfiguration(ctx context.Context, in *receptor
 
 // Verified implements a mock [receptor_v1.Receptor.Discovered] method for testing.
 func (rc *mockReceptorClient) Discovered(ctx context.Context, in *receptor.ServiceEntities, opts ...grpc.CallOption) (s *wrapperspb.StringValue, err error) {
+	printMu.Lock()
+	defer printMu.Unlock()
+
 	s = &wrapperspb.StringValue{Value: ""}
 
 	println(header + "Discovered(...)")
@@ -65,6 +87,9 @@ func (rc *mockReceptorClient) Discovered(ctx context.Context, in *receptor.Servi
 
 // Verified implements a mock [receptor_v1.Receptor.Report] method for testing.
 func (rc *mockReceptorClient) Report(ctx context.Context, in *receptor.Finding, opts ...grpc.CallOption) (s *wrapperspb.StringValue, err error) {
+	printMu.Lock()
+	defer printMu.Unlock()
+
 	println(header + "Report(...)")
 
 	println("Entities")
@@ -75,27 +100,30 @@ func (rc *mockReceptorClient) Report(ctx context.Context, in *receptor.Finding,
 	println()
 
 	println("Evidences")
+	renderer, tableOpts, err := tableRenderer()
 	for _, ev := range in.Evidences {
-
-		t := ev.GetStruct()
-		var headers []string
-		var rows [][]string
-		headers, rows, err = t.Tabulate()
-		for _, header := range headers {
-			fmt.Printf("| %-12s ", header)
+		if err != nil {
+			break
 		}
-		fmt.Println()
-
-		for _, row := range rows {
-			for _, col := range row {
-				fmt.Printf("| %-12s ", col)
-			}
-			fmt.Println()
+		if since := ev.GetUnchangedSince(); since != nil {
+			fmt.Printf("%s: unchanged since %s\n", receptor_sdk.DefaultRedactor.Redact(ev.GetCaption()), since.AsTime().Format(time.RFC3339))
+			continue
+		}
+		var table strings.Builder
+		if renderer == nil {
+			err = writeLegacyTable(&table, ev.GetStruct())
+		} else {
+			err = renderer.Render(&table, ev.GetStruct().NewTable(tableOpts))
+		}
+		if err == nil {
+			fmt.Print(receptor_sdk.DefaultRedactor.Redact(table.String()))
 		}
 	}
 
 	if err != nil {
 		println(err)
+	} else if receptor_sdk.OutputDir != "" {
+		err = writeDryRunRecord(receptor_sdk.OutputDir, bundle.KindReport, in, nil)
 	}
 
 	println(footer)
@@ -104,6 +132,9 @@ func (rc *mockReceptorClient) Report(ctx context.Context, in *receptor.Finding,
 
 // Verified implements a mock [receptor_v1.Receptor.Notify] method for testing.
 func (rc *mockReceptorClient) Notify(ctx context.Context, in *receptor.JobResult, opts ...grpc.CallOption) (e *emptypb.Empty, err error) {
+	printMu.Lock()
+	defer printMu.Unlock()
+
 	e = &emptypb.Empty{}
 
 	println(header + "Notify(...)")
@@ -115,16 +146,133 @@ func (rc *mockReceptorClient) Notify(ctx context.Context, in *receptor.JobResult
 	return
 }
 
+// tableRenderer returns the renderer and table options of the '--table-format' and '--table-timezone' flags.  The
+// renderer is nil if no table format is set, for the tables to be written by writeLegacyTable.
+func tableRenderer() (renderer receptor_v1.Renderer, opts receptor_v1.TableOptions, err error) {
+	if len(receptor_sdk.TableFormat) == 0 {
+		return
+	}
+	if renderer, err = receptor_v1.NewRenderer(receptor_sdk.TableFormat); err != nil {
+		return
+	}
+	if opts.Location, err = time.LoadLocation(receptor_sdk.TableTimezone); err != nil {
+		err = fmt.Errorf("invalid --table-timezone flag: %w", err)
+	}
+	return
+}
+
+// writeLegacyTable writes an evidence table as printed by a dryrun scan when no '--table-format' flag is set.
+func writeLegacyTable(w io.Writer, s *receptor_v1.Struct) (err error) {
+	var (
+		headers []string
+		rows    [][]string
+	)
+	if headers, rows, err = s.Tabulate(); err != nil {
+		return
+	}
+	for _, header := range headers {
+		fmt.Fprintf(w, "| %-12s ", header)
+	}
+	fmt.Fprintln(w)
+
+	for _, row := range rows {
+		for _, col := range row {
+			fmt.Fprintf(w, "| %-12s ", col)
+		}
+		fmt.Fprintln(w)
+	}
+	return
+}
+
+// dryRunRecord is a Report or StreamReport request of a dryrun scan, written to the '--output-dir' directory so
+// the findings of two scans can be compared with the diff command.
+type dryRunRecord struct {
+	Kind      string           `json:"kind"`                // Kind of the request, bundle.KindReport or bundle.KindStream.
+	Finding   json.RawMessage  `json:"finding"`             // Finding reported, encoded with protojson.
+	Documents []dryRunDocument `json:"documents,omitempty"` // Documents streamed with the finding.
+}
+
+// dryRunDocument is a document streamed in a dryrun scan.
+type dryRunDocument struct {
+	Name     string `json:"name"`
+	FileName string `json:"file_name,omitempty"`
+	Size     int64  `json:"size"`
+	Hash     string `json:"hash"` // Hash is the UrlSafe base64 encoded SHA-256 of the document.
+}
+
+// dryRunManifestName is the file name of the manifest of the last dryrun scan written to an '--output-dir'
+// directory.
+const dryRunManifestName = "run.json"
+
+// dryRunManifest lists the records written by a dryrun scan, in the order they were written.  A dryrun scan to an
+// '--output-dir' directory that already holds the records of an earlier scan replaces its manifest, so the diff
+// command only reads the records of the last scan.
+type dryRunManifest struct {
+	Started  time.Time `json:"started"`
+	Findings []string  `json:"findings"` // File names of the finding records.
+}
+
+// dryRun is the manifest of the dryrun scan, nil until the scan starts writing to its '--output-dir' directory.
+var dryRun *dryRunManifest
+
+// startDryRun starts the manifest of a dryrun scan writing to outputDir.
+func startDryRun(outputDir string) (err error) {
+	dryRun = &dryRunManifest{Started: time.Now().UTC(), Findings: []string{}}
+	return writeDryRunManifest(outputDir)
+}
+
+func writeDryRunManifest(outputDir string) (err error) {
+	var data []byte
+	if data, err = json.MarshalIndent(dryRun, "", "  "); err != nil {
+		return
+	}
+	if err = os.MkdirAll(outputDir, 0755); err != nil {
+		return
+	}
+	return os.WriteFile(filepath.Join(outputDir, dryRunManifestName), data, 0644)
+}
+
+// writeDryRunRecord writes a redacted record of a finding to outputDir and adds it to the scan's manifest.  Callers
+// hold printMu.
+func writeDryRunRecord(outputDir, kind string, finding *receptor_v1.Finding, documents []dryRunDocument) (err error) {
+	var data []byte
+	if data, err = protojson.Marshal(finding); err != nil {
+		return
+	}
+	record := dryRunRecord{
+		Kind:      kind,
+		Finding:   json.RawMessage(receptor_sdk.DefaultRedactor.Redact(string(data))),
+		Documents: documents,
+	}
+	if data, err = json.MarshalIndent(record, "", "  "); err != nil {
+		return
+	}
+	if dryRun == nil {
+		if err = startDryRun(outputDir); err != nil {
+			return
+		}
+	}
+	path := uniquePath(outputDir, fmt.Sprintf("finding-%06d.json", len(dryRun.Findings)+1), "")
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		return
+	}
+	dryRun.Findings = append(dryRun.Findings, filepath.Base(path))
+	return writeDryRunManifest(outputDir)
+}
+
 func toYaml(v interface{}) (yamld string, err error) {
 	var bytes []byte
 	if bytes, err = yaml.Marshal(v); err != nil {
 		return
 	}
-	yamld = string(bytes)
+	yamld = receptor_sdk.DefaultRedactor.Redact(string(bytes))
 	return
 }
 
 func (rc *mockReceptorClient) SetConfiguration(ctx context.Context, c *receptor.ReceptorConfiguration, opts ...grpc.CallOption) (e *emptypb.Empty, err error) {
+	printMu.Lock()
+	defer printMu.Unlock()
+
 	println(header + "SetConfiguration(...)")
 	var yamld string
 	if yamld, err = toYaml(c.ReceptorObjectId); err == nil {
@@ -134,6 +282,8 @@ func (rc *mockReceptorClient) SetConfiguration(ctx context.Context, c *receptor.
 	return
 }
 
-func (rc *mockReceptorClient) StreamReport(context.Context, ...grpc.CallOption) (grpc.ClientStreamingClient[receptor_v1.ReportChunk, receptor_v1.ReportResponse], error) {
-	return nil, nil
+// StreamReport implements a mock [receptor_v1.Receptor.StreamReport] method for testing.  The returned stream
+// decodes and prints the streamed multipart when closed.
+func (rc *mockReceptorClient) StreamReport(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[receptor_v1.ReportChunk, receptor_v1.ReportResponse], error) {
+	return &mockReportStream{ctx: ctx}, nil
 }
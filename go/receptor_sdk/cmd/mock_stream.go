@@ -0,0 +1,250 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/trustero/api/go/receptor_sdk"
+	"github.com/trustero/api/go/receptor_sdk/bundle"
+	"github.com/trustero/api/go/receptor_sdk/multipartkit"
+	"github.com/trustero/api/go/receptor_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// mockReportStream implements a mock [receptor_v1.Receptor_StreamReportClient] for testing.  The streamed
+// multipart is buffered in a temporary file and decoded when the stream is closed.
+type mockReportStream struct {
+	ctx         context.Context
+	contentType string
+	buffer      *os.File
+}
+
+// Send buffers a streamed report chunk.  The boundary chunk holds the content type of the multipart.
+func (s *mockReportStream) Send(chunk *receptor_v1.ReportChunk) (err error) {
+	if chunk.GetIsBoundary() {
+		s.contentType = string(chunk.GetContent())
+		return
+	}
+	if s.buffer == nil {
+		if s.buffer, err = os.CreateTemp("", "mock-stream-report_*.tmp"); err != nil {
+			return
+		}
+	}
+	_, err = s.buffer.Write(chunk.GetContent())
+	return
+}
+
+// CloseAndRecv decodes and prints the buffered multipart.
+func (s *mockReportStream) CloseAndRecv() (res *receptor_v1.ReportResponse, err error) {
+	printMu.Lock()
+	defer printMu.Unlock()
+
+	println(header + "StreamReport(...)")
+	defer println(footer)
+
+	if s.buffer == nil {
+		return nil, errors.New("no multipart content streamed")
+	}
+	defer func() {
+		s.buffer.Close()
+		os.Remove(s.buffer.Name())
+	}()
+
+	if _, err = s.buffer.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	if err = printMultipart(s.contentType, s.buffer, receptor_sdk.OutputDir); err != nil {
+		println(err.Error())
+		return
+	}
+	return &receptor_v1.ReportResponse{Status: "ok"}, nil
+}
+
+func (s *mockReportStream) Header() (metadata.MD, error) { return metadata.MD{}, nil }
+func (s *mockReportStream) Trailer() metadata.MD         { return metadata.MD{} }
+func (s *mockReportStream) CloseSend() error             { return nil }
+func (s *mockReportStream) Context() context.Context     { return s.ctx }
+func (s *mockReportStream) SendMsg(m any) error {
+	if chunk, ok := m.(*receptor_v1.ReportChunk); ok {
+		return s.Send(chunk)
+	}
+	return fmt.Errorf("unexpected message type %T", m)
+}
+func (s *mockReportStream) RecvMsg(_ any) error { return io.EOF }
+
+// printMultipart prints the Finding and Sources parts of a streamed report and each document with its size, MIME
+// type, metadata and verified Content-Hash.  Content-Hashes sent in the manifest part are verified, and the result
+// printed, once the manifest part is read.  Documents, and a record of the finding with the hashes of its
+// documents, are written to outputDir if set.
+func printMultipart(contentType string, r io.Reader, outputDir string) (err error) {
+	var (
+		boundary  string
+		reader    *multipartkit.MultipartReader
+		yamld     string
+		finding   *receptor_v1.Finding
+		documents []dryRunDocument
+		trailing  int // number of documents with their hash in the manifest part
+	)
+	println("Content-Type: " + contentType)
+	if boundary, err = multipartkit.ParseBoundary(contentType); err != nil {
+		return
+	}
+	if reader, err = multipartkit.NewMultipartReader(r, boundary, 0); err != nil {
+		return
+	}
+	if outputDir != "" {
+		if err = os.MkdirAll(outputDir, 0755); err != nil {
+			return
+		}
+	}
+
+	for {
+		part, err := reader.Next()
+		if errors.Is(err, multipartkit.ErrSizeMismatch) || errors.Is(err, multipartkit.ErrHashMismatch) {
+			// the manifest part, always last, doesn't match the documents read
+			println("Manifest: MISMATCH, " + err.Error())
+			err = io.EOF
+		} else if err == io.EOF && trailing > 0 {
+			println("Manifest: verified the Content-Hash of " + strconv.Itoa(trailing) + " document(s)")
+		}
+		if err == io.EOF {
+			if outputDir != "" && finding != nil {
+				return writeDryRunRecord(outputDir, bundle.KindStream, finding, documents)
+			}
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read multipart part: %v", err)
+		}
+
+		if part.Kind == multipartkit.PartProtobuf {
+			var msg proto.Message
+			switch part.Name {
+			case "receptor_v1.Finding":
+				msg = &receptor_v1.Finding{}
+			case "receptor_v1.Sources":
+				msg = &receptor_v1.Sources{}
+			default:
+				println("Unknown protobuf part " + part.Name)
+				continue
+			}
+
+			if err = part.Decode(msg); err != nil {
+				return err
+			}
+			if f, ok := msg.(*receptor_v1.Finding); ok {
+				finding = f
+			}
+			println(part.Name)
+			if yamld, err = toYaml(msg); err == nil {
+				println(yamld)
+			}
+			continue
+		}
+
+		document, err := printDocumentPart(part, outputDir)
+		if err != nil {
+			return err
+		}
+		documents = append(documents, document)
+		if part.Trailing {
+			trailing++
+		}
+	}
+}
+
+// printDocumentPart prints a document part.  Content-Size and Content-Hash mismatches are printed rather than
+// returned so the rest of the multipart can be inspected.
+func printDocumentPart(part *multipartkit.Part, outputDir string) (document dryRunDocument, err error) {
+	var (
+		size int64
+		dst  io.Writer = io.Discard
+		path string
+	)
+
+	if outputDir != "" {
+		var f *os.File
+		path = uniquePath(outputDir, part.FileName, part.Name)
+		if f, err = os.Create(path); err != nil {
+			return
+		}
+		defer f.Close()
+		dst = f
+	}
+
+	size, err = io.Copy(dst, part)
+	if err != nil && !errors.Is(err, multipartkit.ErrSizeMismatch) && !errors.Is(err, multipartkit.ErrHashMismatch) {
+		return document, fmt.Errorf("failed to read document %s: %v", part.Name, err)
+	}
+	err = nil
+
+	println("Document " + part.Name)
+	println("  File name:    " + part.FileName)
+	println("  MIME type:    " + part.ContentType)
+	println("  Size:         " + strconv.FormatInt(size, 10) + checkSize(part, size))
+	if part.Encoding != multipartkit.EncodingIdentity {
+		println("  Encoding:     " + part.Encoding)
+	}
+	computed := part.ComputedHash()
+	if part.Trailing {
+		println("  Content-Hash: " + computed + " (pending manifest)")
+	} else if part.Hash == "" {
+		println("  Content-Hash: " + computed + " (not provided)")
+	} else if part.Hash == computed {
+		println("  Content-Hash: " + computed + " (verified)")
+	} else {
+		println("  Content-Hash: " + computed + " (MISMATCH, expected " + part.Hash + ")")
+	}
+	keys := make([]string, 0, len(part.Metadata))
+	for key := range part.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		println("  " + key + ": " + receptor_sdk.DefaultRedactor.Redact(part.Metadata[key]))
+	}
+	if path != "" {
+		println("  Written to:   " + path)
+	}
+	document = dryRunDocument{Name: part.Name, FileName: part.FileName, Size: size, Hash: computed}
+	return
+}
+
+// checkSize compares the size of a document to the size in its part headers.
+func checkSize(part *multipartkit.Part, size int64) string {
+	if part.Size < 0 {
+		return ""
+	}
+	if part.Size == size {
+		return " (verified)"
+	}
+	return " (MISMATCH, expected " + strconv.FormatInt(part.Size, 10) + ")"
+}
+
+// uniquePath returns a path in dir for fileName, or partName if fileName is empty, that doesn't already exist.
+func uniquePath(dir, fileName, partName string) string {
+	base := filepath.Base(fileName)
+	if fileName == "" || base == "." || base == string(filepath.Separator) {
+		base = filepath.Base(partName)
+	}
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+
+	path := filepath.Join(dir, base)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", stem, i, ext))
+	}
+}
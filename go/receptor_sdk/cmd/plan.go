@@ -0,0 +1,158 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+var (
+	rowPlans  sync.Map // reflect.Type of an evidence row to its *rowPlan
+	typePlans sync.Map // reflect.Type of a row field value to its *typePlan
+)
+
+// rowPlan is the conversion plan of an evidence row type, compiled once from its 'trustero' struct tags.
+type rowPlan struct {
+	entityIdFieldName string
+	rowFieldNames     []string       // names of the row's fields, in declaration order
+	fieldIndex        map[string]int // field name to field index
+	colDisplayNames   map[string]string
+	colDisplayOrder   []string
+	colTags           map[string]string
+}
+
+// planRow returns the cached conversion plan of struct type t, compiling it on first use.
+func planRow(t reflect.Type) *rowPlan {
+	if plan, ok := rowPlans.Load(t); ok {
+		return plan.(*rowPlan)
+	}
+
+	plan := &rowPlan{
+		fieldIndex:      map[string]int{},
+		colDisplayNames: map[string]string{},
+		colTags:         map[string]string{},
+	}
+	fieldOrder := map[int]string{}
+	fieldOrderKeys := []int{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tags := expandFieldTag(field)
+		plan.rowFieldNames = append(plan.rowFieldNames, field.Name)
+		plan.fieldIndex[field.Name] = i
+
+		// Is it the id field?
+		if _, ok := tags[idField]; ok {
+			plan.entityIdFieldName = field.Name
+		}
+
+		// Get the field order
+		if val, ok := tags[orderField]; ok {
+			if i, err := strconv.Atoi(val); err == nil {
+				fieldOrder[i] = field.Name
+				fieldOrderKeys = append(fieldOrderKeys, i)
+			}
+		}
+
+		// Get display name
+		plan.colDisplayNames[field.Name] = getTagField(tags, displayField, field.Name)
+
+		// Get the check
+		if val, ok := tags[controlTestField]; ok {
+			plan.colTags[val] = field.Name
+		}
+	}
+
+	// order the display columns
+	sort.Ints(fieldOrderKeys)
+	for _, key := range fieldOrderKeys {
+		plan.colDisplayOrder = append(plan.colDisplayOrder, fieldOrder[key])
+	}
+
+	actual, _ := rowPlans.LoadOrStore(t, plan)
+	return actual.(*rowPlan)
+}
+
+// field returns the field of row value v named name, the i-th field of the row.  Fields are accessed by index,
+// falling back to a lookup by name for a name that isn't one of the plan's row field names, such as a promoted field.
+func (p *rowPlan) field(v reflect.Value, i int, name string) reflect.Value {
+	if i < len(p.rowFieldNames) && p.rowFieldNames[i] == name {
+		return v.Field(i)
+	}
+	if index, ok := p.fieldIndex[name]; ok {
+		return v.Field(index)
+	}
+	return v.FieldByName(name)
+}
+
+// entityId returns the entity instance id of row value v.
+func (p *rowPlan) entityId(v reflect.Value, entityIdFieldName string) string {
+	if index, ok := p.fieldIndex[entityIdFieldName]; ok {
+		return v.Field(index).String()
+	}
+	return v.FieldByName(entityIdFieldName).String()
+}
+
+// method tells how a type implements an interface.
+type method uint8
+
+const (
+	noMethod      method = iota // the type doesn't implement the interface
+	valueMethod                 // the type implements the interface
+	pointerMethod               // a pointer to the type implements the interface
+)
+
+// typePlan is the conversion plan of a row field value type.
+type typePlan struct {
+	valuer, textMarshaler, stringer method
+	fields                          []structFieldPlan // exported fields of a struct type
+}
+
+// structFieldPlan is a field of a nested struct, named by its 'trustero' display sub-tag or its field name.
+type structFieldPlan struct {
+	index int
+	name  string // field name, used in error messages
+	key   string // name of the field in the converted struct
+}
+
+// planType returns the cached conversion plan of a row field value type, compiling it on first use.
+func planType(t reflect.Type) *typePlan {
+	if plan, ok := typePlans.Load(t); ok {
+		return plan.(*typePlan)
+	}
+
+	plan := &typePlan{
+		valuer:        implementsMethod(t, valuerType),
+		textMarshaler: implementsMethod(t, textMarshalerType),
+		stringer:      implementsMethod(t, stringerType),
+	}
+	if t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			plan.fields = append(plan.fields, structFieldPlan{
+				index: i,
+				name:  field.Name,
+				key:   getTagField(expandFieldTag(field), displayField, field.Name),
+			})
+		}
+	}
+
+	actual, _ := typePlans.LoadOrStore(t, plan)
+	return actual.(*typePlan)
+}
+
+func implementsMethod(t, iface reflect.Type) method {
+	if t.Implements(iface) {
+		return valueMethod
+	}
+	if t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface && reflect.PointerTo(t).Implements(iface) {
+		return pointerMethod
+	}
+	return noMethod
+}
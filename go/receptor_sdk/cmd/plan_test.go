@@ -0,0 +1,175 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/trustero/api/go/receptor_v1"
+	"google.golang.org/protobuf/proto"
+)
+
+type planTestLevel int
+
+func (l planTestLevel) String() string { return "level-" + strconv.Itoa(int(l)) }
+
+type planTestGroup struct {
+	Name  string `trustero:"display:Group Name"`
+	Admin bool
+}
+
+type planTestUser struct {
+	Username       string            `trustero:"id;display:Username;order:1"`
+	Name           string            `trustero:"display:Name;order:2"`
+	IsAdmin        bool              `trustero:"display:Admin;order:3;check:admin"`
+	Logins         int64             `trustero:"display:Logins;order:4"`
+	CreatedAt      time.Time         `trustero:"display:Created On;order:5"`
+	LastActivityOn *time.Time        `trustero:"display:Last Activity On;order:6"`
+	Level          planTestLevel     `trustero:"display:Level;order:7"`
+	Group          planTestGroup     `trustero:"display:Group;order:8"`
+	Emails         []string          `trustero:"display:Emails;order:9"`
+	Labels         map[string]string `trustero:"display:Labels;order:10"`
+	Hidden         string
+}
+
+func planTestRows(n int) (rows []interface{}) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		user := planTestUser{
+			Username:  fmt.Sprintf("user%d", i),
+			Name:      fmt.Sprintf("User %d", i),
+			IsAdmin:   i%3 == 0,
+			Logins:    int64(i * 7),
+			CreatedAt: created,
+			Level:     planTestLevel(i % 4),
+			Group:     planTestGroup{Name: "group", Admin: i%2 == 0},
+			Emails:    []string{fmt.Sprintf("user%d@example.com", i)},
+			Labels:    map[string]string{"team": "a"},
+			Hidden:    "hidden",
+		}
+		if i%2 == 0 {
+			activity := created.Add(time.Duration(i) * time.Hour)
+			user.LastActivityOn = &activity
+		}
+		rows = append(rows, user)
+	}
+	return append(rows, &planTestUser{Username: "pointer"})
+}
+
+// unplannedMetaData is ExtractMetaData as it was before row types were planned, parsing the tags of every field on
+// every call.
+func unplannedMetaData(row interface{}, reportStruct *receptor_v1.Struct) (entityIdFieldName string, rowFieldNames []string) {
+	rowType := reflect.TypeOf(row)
+	fieldOrder := map[int]string{}
+	fieldOrderKeys := []int{}
+	for i := 0; i < rowType.NumField(); i++ {
+		field := rowType.Field(i)
+		tags := expandFieldTag(field)
+		rowFieldNames = append(rowFieldNames, field.Name)
+		if _, ok := tags[idField]; ok {
+			entityIdFieldName = field.Name
+		}
+		if val, ok := tags[orderField]; ok {
+			if i, err := strconv.Atoi(val); err == nil {
+				fieldOrder[i] = field.Name
+				fieldOrderKeys = append(fieldOrderKeys, i)
+			}
+		}
+		reportStruct.ColDisplayNames[field.Name] = getTagField(tags, displayField, field.Name)
+		if val, ok := tags[controlTestField]; ok {
+			reportStruct.ColTags[val] = field.Name
+		}
+	}
+	sort.Ints(fieldOrderKeys)
+	for _, key := range fieldOrderKeys {
+		reportStruct.ColDisplayOrder = append(reportStruct.ColDisplayOrder, fieldOrder[key])
+	}
+	return
+}
+
+// unplannedRow is RowToStructRow as it was before row types were planned, looking up each field by name and the
+// keys of nested struct fields by parsing their tags.  Field values are converted by the same rowConverter.
+func unplannedRow(row interface{}, entityIdFieldName string, rowFieldNames []string) *receptor_v1.Row {
+	rowValue := reflect.Indirect(reflect.ValueOf(row))
+	reportRow := &receptor_v1.Row{
+		EntityInstanceId: rowValue.FieldByName(entityIdFieldName).String(),
+		Cols:             map[string]*receptor_v1.Value{},
+	}
+	c := newRowConverter(false)
+	for _, fieldName := range rowFieldNames {
+		v := rowValue.FieldByName(fieldName)
+		var value *receptor_v1.Value
+		if v.Kind() == reflect.Struct && !converted(v.Type()) {
+			fields := map[string]*receptor_v1.Value{}
+			for i := 0; i < v.NumField(); i++ {
+				field := v.Type().Field(i)
+				if field.IsExported() {
+					fields[getTagField(expandFieldTag(field), displayField, field.Name)], _ = c.value(fieldName+"."+field.Name, v.Field(i))
+				}
+			}
+			value = structListValue(&receptor_v1.StructStruct{Fields: fields})
+		} else {
+			value, _ = c.value(fieldName, v)
+		}
+		if value != nil {
+			reportRow.Cols[fieldName] = value
+		}
+	}
+	return reportRow
+}
+
+func newPlanTestStruct() *receptor_v1.Struct {
+	return &receptor_v1.Struct{ColDisplayNames: map[string]string{}, ColTags: map[string]string{}}
+}
+
+func TestPlannedRowsMatchUnplanned(t *testing.T) {
+	for _, row := range planTestRows(8) {
+		planned, unplanned := newPlanTestStruct(), newPlanTestStruct()
+		entityId, fieldNames, err := ExtractMetaData(reflect.Indirect(reflect.ValueOf(row)).Interface(), planned)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantEntityId, wantFieldNames := unplannedMetaData(reflect.Indirect(reflect.ValueOf(row)).Interface(), unplanned)
+		if entityId != wantEntityId || !reflect.DeepEqual(fieldNames, wantFieldNames) {
+			t.Fatalf("ExtractMetaData() = %q, %q, want %q, %q", entityId, fieldNames, wantEntityId, wantFieldNames)
+		}
+		if !proto.Equal(planned, unplanned) {
+			t.Fatalf("ExtractMetaData() struct = %v, want %v", planned, unplanned)
+		}
+
+		got, want := RowToStructRow(row, entityId, fieldNames), unplannedRow(row, entityId, fieldNames)
+		if !proto.Equal(got, want) {
+			t.Errorf("RowToStructRow(%v) = %v, want %v", row, got, want)
+		}
+	}
+}
+
+func BenchmarkRowToStructRow(b *testing.B) {
+	rows := planTestRows(100)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		reportStruct := newPlanTestStruct()
+		entityId, fieldNames, _ := ExtractMetaData(rows[0], reportStruct)
+		for _, row := range rows {
+			reportStruct.Rows = append(reportStruct.Rows, RowToStructRow(row, entityId, fieldNames))
+		}
+	}
+}
+
+func BenchmarkRowToStructRowUnplanned(b *testing.B) {
+	rows := planTestRows(100)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		reportStruct := newPlanTestStruct()
+		entityId, fieldNames := unplannedMetaData(rows[0], reportStruct)
+		for _, row := range rows {
+			reportStruct.Rows = append(reportStruct.Rows, unplannedRow(row, entityId, fieldNames))
+		}
+	}
+}
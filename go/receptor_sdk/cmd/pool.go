@@ -0,0 +1,67 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"context"
+	"sync"
+)
+
+// uploadPool runs evidence uploads on a bounded number of workers.  Go blocks until a worker is free, so a
+// receptor producing evidence faster than it can be uploaded is slowed down rather than buffered in memory.
+type uploadPool struct {
+	workers chan struct{}
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	errs    []error
+}
+
+func newUploadPool(concurrency int) *uploadPool {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &uploadPool{workers: make(chan struct{}, concurrency)}
+}
+
+// Go runs upload on a free worker.  Go returns ctx's error without running upload if ctx is done before a worker
+// is free.
+func (p *uploadPool) Go(ctx context.Context, upload func() error) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case p.workers <- struct{}{}:
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			<-p.workers
+			p.wg.Done()
+		}()
+		if err := upload(); err != nil {
+			p.mu.Lock()
+			p.errs = append(p.errs, err)
+			p.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// Err returns the error of the first upload that failed so far, or nil if none failed.
+func (p *uploadPool) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.errs) == 0 {
+		return nil
+	}
+	return p.errs[0]
+}
+
+// Wait waits for all uploads to complete and returns the errors of the uploads that failed.
+func (p *uploadPool) Wait() []error {
+	p.wg.Wait()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]error(nil), p.errs...)
+}
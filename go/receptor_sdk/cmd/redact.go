@@ -0,0 +1,49 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"reflect"
+
+	"github.com/trustero/api/go/receptor_sdk"
+)
+
+// redactCredentials adds the values of the credential fields tagged as secret to the default redactor.  A field is
+// secret if its 'trustero' tag has a 'secret' sub-tag or an 'input_type:password' sub-tag.  For example:
+//
+//	type Credentials struct {
+//	    Token string `trustero:"display:Access Token;placeholder:token;secret"`
+//	}
+func redactCredentials(credentials interface{}) {
+	redactSecretFields(reflect.ValueOf(credentials))
+}
+
+func redactSecretFields(v reflect.Value) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		value := v.Field(i)
+		tags := expandFieldTag(field)
+		_, secret := tags[secretField]
+		if secret || getTagField(tags, inputTypeField, "") == "password" {
+			if value.Kind() == reflect.String {
+				receptor_sdk.DefaultRedactor.AddSecret(value.String())
+			}
+			continue
+		}
+		redactSecretFields(value) // nested credential structs
+	}
+}
@@ -7,60 +7,139 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"reflect"
-	"sort"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/trustero/api/go/receptor_sdk"
+	"github.com/trustero/api/go/receptor_sdk/client"
 	"github.com/trustero/api/go/receptor_sdk/multipartkit"
 	"github.com/trustero/api/go/receptor_v1"
-	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const mulitpartPrefix = "multipart/tr-mixed"
 
-func report(rc receptor_v1.ReceptorClient, credentials interface{}, config interface{}) (err error) {
+// defaultChunkSize is the default maximum size of a ReportChunk streamed to Trustero.
+const defaultChunkSize = 64 * 1024
+
+func report(ctx context.Context, rc receptor_v1.ReceptorClient, credentials interface{}, config interface{}) (err error) {
 
 	// Report discovered evidence to Trustero
 	var finding receptor_v1.Finding
 
 	// Discover service entities
-	if finding.Entities, err = receptorImpl.Discover(credentials, config); err != nil {
+	done := summary.phase("discover")
+	finding.Entities, err = discoverReceptor(ctx, credentials, config)
+	done(err)
+	if err != nil {
 		return
 	}
+	summary.discovered(finding.Entities)
 	finding.ReceptorType = GetParsedReceptorType()
 	finding.ServiceProviderAccount = serviceProviderAccount
 	finding.DiscoveryId = receptor_sdk.DiscoveryId
-	// report in single batch
-	var evidences []*receptor_sdk.Evidence
-	if evidences, err = receptorImpl.Report(credentials, config); err == nil && len(evidences) > 0 {
-		_ = reportEvidence(rc, &finding, evidences)
-	}
-
-	// report in multiple batches
-	evidenceChannel := make(chan []*receptor_sdk.Evidence)
 
-	go receptorImpl.ReportBatch(credentials, evidenceChannel)
-
-	for evidences := range evidenceChannel {
-		// Receive evidence and report them one batch at a time
-		err := reportEvidence(rc, &finding, evidences)
+	// stream document evidence on up to --upload-concurrency workers, waiting for them before returning.  A failed
+	// upload fails the scan.
+	done = summary.phase("report")
+	var scanState *evidenceState
+	if scanState, err = openEvidenceState(); err != nil {
+		done(err)
+		return
+	}
+	uploads := newUploadPool(receptor_sdk.UploadConcurrency)
+	defer func() {
+		for _, uploadErr := range uploads.Wait() {
+			if !errors.Is(err, uploadErr) { // already returned to the receptor by Emit
+				err = errors.Join(err, uploadErr)
+			}
+		}
+		// record the evidence reported, even if the scan failed to report some
+		err = errors.Join(err, scanState.close())
 		if err != nil {
 			log.Err(err).Msg("failed to report evidence")
-			// Continue on to next batch even after an error
-			continue
 		}
+		done(err)
+	}()
+	emitter := &evidenceEmitter{ctx: ctx, rc: rc, uploads: uploads, state: scanState, finding: &finding}
 
+	// report in single batch
+	var (
+		evidences []*receptor_sdk.Evidence
+		errs      []error
+	)
+	if evidences, err = reportReceptor(ctx, credentials, config); err != nil {
+		errs = append(errs, err)
+	} else if len(evidences) > 0 {
+		if err = emitter.Emit(evidences...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
 	}
 
-	return
+	// report in multiple batches
+	if err = reportBatches(ctx, credentials, config, emitter); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// evidenceEmitter implements [receptor_sdk.Emitter] for the scan command.  Emit reports batches one at a time, so
+// Report calls are made in the order batches are emitted.
+type evidenceEmitter struct {
+	ctx     context.Context
+	rc      receptor_v1.ReceptorClient
+	uploads *uploadPool
+	state   *evidenceState
+	mu      sync.Mutex
+	finding *receptor_v1.Finding
+}
+
+// Emit implements [receptor_sdk.Emitter.Emit].
+func (e *evidenceEmitter) Emit(evidences ...*receptor_sdk.Evidence) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.ctx.Err(); err != nil {
+		return err
+	}
+	if err := e.uploads.Err(); err != nil {
+		return err
+	}
+	return reportEvidence(e.ctx, e.rc, e.uploads, e.state, e.finding, evidences)
 }
 
-func reportEvidence(rc receptor_v1.ReceptorClient, finding *receptor_v1.Finding, evidences []*receptor_sdk.Evidence) (err error) {
+// reportEvidence reports a batch of evidence.  Structured evidence is reported with a single Report call once the
+// batch is converted, so Report calls are made in batch order.  Document evidence is streamed on the uploads pool
+// concurrently with the rest of the scan, and its failures are returned by the pool's Wait.  Evidence unchanged
+// since it was last reported, according to state, is reported with an "unchanged since" marker in the Report
+// call, or skipped.  An evidence that fails to be converted is left out of the Report call, and its error is
+// returned with those of the rest of the batch.  finding's evidences are reset before returning, so they aren't
+// reported again with the next batch.
+func reportEvidence(ctx context.Context, rc receptor_v1.ReceptorClient, uploads *uploadPool, state *evidenceState, finding *receptor_v1.Finding, evidences []*receptor_sdk.Evidence) (err error) {
+	var (
+		evidenceErrs  []error
+		structured    []*receptor_sdk.Evidence // evidence reported with the Report call below
+		structuredKey []string                 // state keys of the structured evidence
+		markers       []*receptor_sdk.Evidence // unchanged evidence reported with the Report call below
+	)
+	defer func() { finding.Evidences = []*receptor_v1.Evidence{} }()
+	reportUnchanged := func(evidence *receptor_sdk.Evidence, reportEvidence *receptor_v1.Evidence, since time.Time) {
+		if receptor_sdk.UnchangedEvidence == unchangedSkip {
+			summary.unchanged(evidence, nil)
+			return
+		}
+		finding.Evidences = append(finding.Evidences, unchangedEvidence(reportEvidence, since))
+		markers = append(markers, evidence)
+	}
+
 	for _, evidence := range evidences {
+		key := state.key(evidence)
 		reportStruct := receptor_v1.Struct{
 			Rows:            []*receptor_v1.Row{},
 			ColDisplayNames: map[string]string{},
@@ -72,7 +151,7 @@ func reportEvidence(rc receptor_v1.ReceptorClient, finding *receptor_v1.Finding,
 			Description:           evidence.Description,
 			ServiceName:           evidence.ServiceName,
 			EntityType:            evidence.EntityType,
-			Sources:               evidence.Sources,
+			Sources:               receptor_sdk.DefaultRedactor.RedactSources(evidence.Sources),
 			ServiceAccountId:      evidence.ServiceAccountId,
 			Controls:              evidence.Controls,
 			IsManual:              evidence.IsManual,
@@ -117,6 +196,9 @@ func reportEvidence(rc receptor_v1.ReceptorClient, finding *receptor_v1.Finding,
 					})
 				}
 			}
+			if !receptor_sdk.TrailingHashes || state != nil { // otherwise, files are hashed as they're streamed
+				hashStreamFiles(paths)
+			}
 			if len(evidenceDocuments.Docs) == 1 { // single document
 				reportEvidence.EvidenceType = &receptor_v1.Evidence_Doc{
 					Doc: evidenceDocuments.Docs[0],
@@ -126,9 +208,14 @@ func reportEvidence(rc receptor_v1.ReceptorClient, finding *receptor_v1.Finding,
 					Docs: &evidenceDocuments,
 				}
 			}
-			//extract sources and add to multipart and remove from finding
+			if since, ok := state.unchanged(key, &reportEvidence, evidence, paths); ok {
+				removeStreamFiles(evidence)
+				reportUnchanged(evidence, &reportEvidence, since)
+				continue
+			}
+			//extract the scrubbed sources and add to multipart and remove from finding
 			sources := []*receptor_v1.Source{}
-			for _, source := range evidence.Sources {
+			for _, source := range reportEvidence.Sources {
 				sources = append(sources, &receptor_v1.Source{
 					RawApiRequest:  source.RawApiRequest,
 					RawApiResponse: source.RawApiResponse,
@@ -138,300 +225,224 @@ func reportEvidence(rc receptor_v1.ReceptorClient, finding *receptor_v1.Finding,
 
 			reportFinding.Evidences = append(reportFinding.Evidences, &reportEvidence)
 
-			contentType, streamFile, err := multipartEvidence(&reportFinding, paths, sources)
-
-			// have the streamFile from receptor - remove the temp evidence files
-			for _, doc := range *evidence.Document {
-				os.Remove(doc.StreamFilePath)
-			}
-
-			if err != nil {
-				log.Err(err).Msg("failed to create multipart evidence")
-				err = nil
+			contentType, writeMultipart, multipartErr := multipartEvidence(&reportFinding, paths, sources)
+			if multipartErr != nil {
+				// report the rest of the batch before failing
+				log.Err(multipartErr).Msg("failed to create multipart evidence")
+				removeStreamFiles(evidence)
+				evidenceErrs = append(evidenceErrs, fmt.Errorf("failed to create multipart evidence %s: %w", evidence.Caption, multipartErr))
+				summary.reported(evidence, 0, multipartErr)
 				continue
 			}
 
-			// make a multipart file and then stream it
-			stream, err := rc.StreamReport(context.Background())
-			if err != nil {
-				log.Err(err).Msg("failed to stream report")
+			// stream the multipart as it's built, replaying the whole stream on transient failures
+			docsKey, keyErr := documentsKey(paths)
+			if keyErr != nil {
+				removeStreamFiles(evidence)
+				evidenceErrs = append(evidenceErrs, fmt.Errorf("failed to create multipart evidence %s: %w", evidence.Caption, keyErr))
+				summary.reported(evidence, 0, keyErr)
 				continue
 			}
+			idempotencyKey := client.IdempotencyKey(finding.DiscoveryId, evidenceKey(evidence), messageHash(&reportFinding),
+				messageHash(&receptor_v1.Sources{Sources: sources}), docsKey)
+			evidence := evidence
+			size := documentsSize(evidence)
+			upload := func() error {
+				err := streamEvidence(ctx, rc, contentType, idempotencyKey, func() (io.ReadCloser, error) {
+					pr, pw := io.Pipe()
+					go func() { pw.CloseWithError(writeMultipart(pw)) }()
+					return pr, nil
+				})
 
-			//send boundary of the multipart first
-			if err = stream.Send(&receptor_v1.ReportChunk{Content: []byte(contentType), IsBoundary: true}); err != nil {
-				log.Err(err).Msg("failed to send data chunk")
-				break
-			}
-
-			//read from the file path and stream in chunks
-			file, err := os.Open(streamFile)
-			defer func() {
-				file.Close()
-				os.Remove(streamFile)
-			}()
+				// have streamed the files from receptor - remove the temp evidence files
+				removeStreamFiles(evidence)
+				summary.reported(evidence, size, err)
+				if err == nil {
+					state.reported(key)
+				}
 
-			if err != nil {
-				log.Err(err).Msg("failed to open file")
-				continue
-			}
-			buf := make([]byte, 1024)
-			for {
-				n, err := file.Read(buf)
 				if err != nil {
-					break
-				}
-				if err = stream.Send(&receptor_v1.ReportChunk{Content: buf[:n]}); err != nil {
-					log.Err(err).Msg("failed to send data chunk")
-					break
+					log.Err(err).Msgf("failed to stream evidence %s", evidence.Caption)
+					return fmt.Errorf("failed to stream evidence %s: %w", evidence.Caption, err)
 				}
+				return nil
 			}
-			_, err = stream.CloseAndRecv()
-			if err != nil {
-				log.Err(err).Msg("failed to close and receive stream")
-				continue
+			if goErr := uploads.Go(ctx, upload); goErr != nil {
+				removeStreamFiles(evidence)
+				summary.reported(evidence, 0, goErr)
+				return goErr
 			}
 		} else { // evidence is structured
 			reportEvidence.EvidenceType = &receptor_v1.Evidence_Struct{Struct: &reportStruct}
+			if evidence.Struct != nil {
+				// already structured at run time
+				reportEvidence.EvidenceType = &receptor_v1.Evidence_Struct{Struct: evidence.Struct}
+				if since, ok := state.unchanged(key, &reportEvidence, evidence, nil); ok {
+					reportUnchanged(evidence, &reportEvidence, since)
+					continue
+				}
+				finding.Evidences = append(finding.Evidences, &reportEvidence)
+				structured = append(structured, evidence)
+				structuredKey = append(structuredKey, key)
+				continue
+			}
 
 			// Convert rows
-			var entityIdFieldName string
-			var rowFieldNames []string
+			var (
+				entityIdFieldName string
+				rowFieldNames     []string
+				rowsErr           error
+			)
 			for idx, row := range evidence.Rows {
 				if idx == 0 {
-					if entityIdFieldName, rowFieldNames, err = ExtractMetaData(row, &reportStruct); err != nil {
-						return // failed to extract metadata, likely an invalid row type
+					if entityIdFieldName, rowFieldNames, rowsErr = ExtractMetaData(row, &reportStruct); rowsErr != nil {
+						break // likely an invalid row type
 					}
 				}
-				reportStruct.Rows = append(reportStruct.Rows, RowToStructRow(row, entityIdFieldName, rowFieldNames))
+				if !receptor_sdk.StrictRows {
+					reportStruct.Rows = append(reportStruct.Rows, RowToStructRow(row, entityIdFieldName, rowFieldNames))
+					continue
+				}
+				reportRow, rowErr := RowToStructRowStrict(row, entityIdFieldName, rowFieldNames)
+				if rowErr != nil {
+					rowsErr = fmt.Errorf("row %d: %w", idx, rowErr)
+					break
+				}
+				reportStruct.Rows = append(reportStruct.Rows, reportRow)
+			}
+			if rowsErr != nil {
+				rowsErr = fmt.Errorf("failed to convert evidence %s: %w", evidence.Caption, rowsErr)
+				summary.reported(evidence, 0, rowsErr)
+				evidenceErrs = append(evidenceErrs, rowsErr)
+				continue
+			}
+
+			if since, ok := state.unchanged(key, &reportEvidence, evidence, nil); ok {
+				reportUnchanged(evidence, &reportEvidence, since)
+				continue
 			}
 
 			// Append to Finding
 			finding.Evidences = append(finding.Evidences, &reportEvidence)
+			structured = append(structured, evidence)
+			structuredKey = append(structuredKey, key)
 		}
 
 	}
 	// report all structured evidence at once
-	_, err = rc.Report(context.Background(), finding)
-	finding.Evidences = []*receptor_v1.Evidence{} // reset evidences
-	return
+	_, err = rc.Report(ctx, finding)
+	for i, evidence := range structured {
+		summary.reported(evidence, 0, err)
+		if err == nil {
+			state.reported(structuredKey[i])
+		}
+	}
+	for _, evidence := range markers {
+		summary.unchanged(evidence, err)
+	}
+	return errors.Join(append(evidenceErrs, err)...)
 
 }
 
-// ExtractMetaData Extracts tag information from struct
-func ExtractMetaData(row interface{}, reportStruct *receptor_v1.Struct) (entityIdFieldName string, rowFieldNames []string, err error) {
-	rowFieldNames = []string{}
-	rowType := reflect.TypeOf(row)
-	if err = assertStruct(rowType); err != nil {
-		return
-	}
-
-	fieldOrder := map[int]string{}
-	fieldOrderKeys := []int{}
-	for i := 0; i < rowType.NumField(); i++ {
-		field := rowType.Field(i)
-		tags := expandFieldTag(field)
-		rowFieldNames = append(rowFieldNames, field.Name)
+// streamEvidence uploads a multipart evidence through StreamReport.  The first chunk sent is the multipart
+// content type with its boundary, followed by the multipart read from open.  A failed upload is replayed from
+// the start, by calling open again, according to rc's retry policy with the given idempotency key.  An attempt
+// failing to read the multipart cancels its stream, so Trustero doesn't receive a truncated multipart.
+func streamEvidence(ctx context.Context, rc receptor_v1.ReceptorClient, contentType, idempotencyKey string, open func() (io.ReadCloser, error)) error {
+	ctx = client.WithIdempotencyKey(ctx, idempotencyKey)
+	return retryPolicy(rc).Do(ctx, "StreamReport", func(ctx context.Context) (err error) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var (
+			file   io.ReadCloser // the multipart
+			stream receptor_v1.Receptor_StreamReportClient
+			n      int
+		)
+		if file, err = open(); err != nil {
+			return
+		}
+		defer file.Close()
 
-		// Is it the id field?
-		if _, ok := tags[idField]; ok {
-			entityIdFieldName = field.Name
+		if stream, err = rc.StreamReport(ctx); err != nil {
+			return
 		}
 
-		// Get the field order
-		if val, ok := tags[orderField]; ok {
-			if i, err := strconv.Atoi(val); err == nil {
-				fieldOrder[i] = field.Name
-				fieldOrderKeys = append(fieldOrderKeys, i)
-			}
+		//send boundary of the multipart first
+		if err = stream.Send(&receptor_v1.ReportChunk{Content: []byte(contentType), IsBoundary: true}); err != nil {
+			return streamSendError(stream, err)
 		}
 
-		// Get display name
-		if val, ok := tags[displayField]; ok {
-			reportStruct.ColDisplayNames[field.Name] = val
-		} else {
-			reportStruct.ColDisplayNames[field.Name] = field.Name
+		//read the multipart and stream it in chunks of up to the chunk size
+		chunkSize := receptor_sdk.ChunkSize
+		if chunkSize <= 0 {
+			chunkSize = defaultChunkSize
 		}
+		buf := make([]byte, chunkSize)
+		for {
+			if n, err = io.ReadFull(file, buf); n > 0 {
+				if sendErr := stream.Send(&receptor_v1.ReportChunk{Content: buf[:n]}); sendErr != nil {
+					return streamSendError(stream, sendErr)
+				}
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			} else if err != nil {
+				return
+			}
+		}
+		_, err = stream.CloseAndRecv()
+		return
+	})
+}
 
-		// Get the check
-		if val, ok := tags[controlTestField]; ok {
-			reportStruct.ColTags[val] = field.Name
+// streamSendError returns the stream's status when Send fails with io.EOF, which signals the server ended the
+// stream, and err otherwise.
+func streamSendError(stream receptor_v1.Receptor_StreamReportClient, err error) error {
+	if err == io.EOF {
+		if _, err = stream.CloseAndRecv(); err == nil {
+			err = io.ErrUnexpectedEOF
 		}
 	}
+	return err
+}
 
-	// order the display columns
-	sort.Ints(fieldOrderKeys)
-	for _, key := range fieldOrderKeys {
-		reportStruct.ColDisplayOrder = append(reportStruct.ColDisplayOrder, fieldOrder[key])
+// evidenceKey returns the key identifying an evidence: its EvidenceKey if set, otherwise its caption.
+func evidenceKey(evidence *receptor_sdk.Evidence) string {
+	if evidence.EvidenceKey != "" {
+		return evidence.EvidenceKey
 	}
-	return
+	return evidence.Caption
 }
 
-// RowToStructRow Builds structured row of evidence
-func RowToStructRow(row interface{}, entityIdFieldName string, rowFieldNames []string) (reportRow *receptor_v1.Row) {
-	reportRow = &receptor_v1.Row{
-		EntityInstanceId: getField(row, entityIdFieldName),
-		Cols:             map[string]*receptor_v1.Value{},
+// ExtractMetaData Extracts tag information from struct.  The tags of a row type are parsed once and cached.
+func ExtractMetaData(row interface{}, reportStruct *receptor_v1.Struct) (entityIdFieldName string, rowFieldNames []string, err error) {
+	rowType := reflect.TypeOf(row)
+	if err = assertStruct(rowType); err != nil {
+		return "", []string{}, err
 	}
 
-	rowValue := reflect.Indirect(reflect.ValueOf(row))
-	for _, fieldName := range rowFieldNames {
-		v := rowValue.FieldByName(fieldName)
-
-		if dateTime, ok := v.Interface().(time.Time); ok {
-			reportRow.Cols[fieldName] = &receptor_v1.Value{
-				ValueType: &receptor_v1.Value_TimestampValue{
-					TimestampValue: timestamppb.New(dateTime),
-				},
-			}
-			continue
-		}
-
-		dateTime, ok := v.Interface().(*time.Time)
-		if ok {
-			reportRow.Cols[fieldName] = &receptor_v1.Value{}
-			if dateTime != nil {
-				reportRow.Cols[fieldName].ValueType = &receptor_v1.Value_TimestampValue{
-					TimestampValue: timestamppb.New(*dateTime),
-				}
-			}
-			continue
-		}
-
-		switch v.Kind() {
-		case reflect.Bool:
-			reportRow.Cols[fieldName] = &receptor_v1.Value{
-				ValueType: &receptor_v1.Value_BoolValue{
-					BoolValue: v.Bool(),
-				},
-			}
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
-			reportRow.Cols[fieldName] = &receptor_v1.Value{
-				ValueType: &receptor_v1.Value_Int32Value{
-					Int32Value: int32(v.Int()),
-				},
-			}
-		case reflect.Int64:
-			reportRow.Cols[fieldName] = &receptor_v1.Value{
-				ValueType: &receptor_v1.Value_Int64Value{
-					Int64Value: v.Int(),
-				},
-			}
-		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
-			reportRow.Cols[fieldName] = &receptor_v1.Value{
-				ValueType: &receptor_v1.Value_Uint32Value{
-					Uint32Value: uint32(v.Uint()),
-				},
-			}
-		case reflect.Uint64:
-			reportRow.Cols[fieldName] = &receptor_v1.Value{
-				ValueType: &receptor_v1.Value_Uint64Value{
-					Uint64Value: v.Uint(),
-				},
-			}
-		case reflect.Float32, reflect.Float64:
-			reportRow.Cols[fieldName] = &receptor_v1.Value{
-				ValueType: &receptor_v1.Value_DoubleValue{
-					DoubleValue: v.Float(),
-				},
-			}
-		case reflect.String:
-			reportRow.Cols[fieldName] = &receptor_v1.Value{
-				ValueType: &receptor_v1.Value_StringValue{
-					StringValue: v.String(),
-				},
-			}
-		case reflect.Slice:
-			elemType := v.Type().Elem()
-			if elemType.Kind() == reflect.String {
-				var stringArray receptor_v1.StringList
-				for i := 0; i < v.Len(); i++ {
-					stringArray.Values = append(stringArray.Values, v.Index(i).String())
-				}
-				reportRow.Cols[fieldName] = &receptor_v1.Value{
-					ValueType: &receptor_v1.Value_StringListValue{
-						StringListValue: &stringArray,
-					},
-				}
-			} else if elemType.Kind() == reflect.Struct {
-				var structList receptor_v1.StructList
-				for i := 0; i < v.Len(); i++ {
-					structElem := v.Index(i)
-					structMap := make(map[string]*receptor_v1.Value)
-					for j := 0; j < structElem.NumField(); j++ {
-						structField := structElem.Type().Field(j)
-						fieldVal := structElem.Field(j).Interface()
-						structMap[structField.Name] = mapToValue(fieldVal)
-					}
-					structList.Values = append(structList.Values, &receptor_v1.StructStruct{Fields: structMap})
-				}
-				reportRow.Cols[fieldName] = &receptor_v1.Value{
-					ValueType: &receptor_v1.Value_StructListValue{
-						StructListValue: &structList,
-					},
-				}
-			} else {
-				log.Warn().Msg("unsupported evidence row field (" + fieldName + ") type")
-			}
-		default:
-			log.Warn().Msg("unsupported evidence row field (" + fieldName + ") type")
-		}
+	plan := planRow(rowType)
+	for name, display := range plan.colDisplayNames {
+		reportStruct.ColDisplayNames[name] = display
 	}
-
-	return
+	for tag, name := range plan.colTags {
+		reportStruct.ColTags[tag] = name
+	}
+	reportStruct.ColDisplayOrder = append(reportStruct.ColDisplayOrder, plan.colDisplayOrder...)
+	return plan.entityIdFieldName, append([]string{}, plan.rowFieldNames...), nil
 }
 
-func mapToValue(val interface{}) *receptor_v1.Value {
-	switch v := val.(type) {
-	case bool:
-		return &receptor_v1.Value{
-			ValueType: &receptor_v1.Value_BoolValue{BoolValue: v},
-		}
-	case int, int8, int16, int32:
-		return &receptor_v1.Value{
-			ValueType: &receptor_v1.Value_Int32Value{Int32Value: int32(reflect.ValueOf(v).Int())},
-		}
-	case int64:
-		return &receptor_v1.Value{
-			ValueType: &receptor_v1.Value_Int64Value{Int64Value: v},
-		}
-	case uint, uint8, uint16, uint32:
-		return &receptor_v1.Value{
-			ValueType: &receptor_v1.Value_Uint32Value{Uint32Value: uint32(reflect.ValueOf(v).Uint())},
-		}
-	case uint64:
-		return &receptor_v1.Value{
-			ValueType: &receptor_v1.Value_Uint64Value{Uint64Value: v},
-		}
-	case float32, float64:
-		return &receptor_v1.Value{
-			ValueType: &receptor_v1.Value_DoubleValue{DoubleValue: reflect.ValueOf(v).Float()},
-		}
-	case string:
-		return &receptor_v1.Value{
-			ValueType: &receptor_v1.Value_StringValue{StringValue: v},
-		}
-	case time.Time:
-		return &receptor_v1.Value{
-			ValueType: &receptor_v1.Value_TimestampValue{TimestampValue: timestamppb.New(v)},
-		}
-	case *time.Time:
-		if v != nil {
-			return &receptor_v1.Value{
-				ValueType: &receptor_v1.Value_TimestampValue{TimestampValue: timestamppb.New(*v)},
-			}
-		}
-		return &receptor_v1.Value{} // Return empty value for nil *time.Time
-	default:
-		// Handle unsupported types as needed
-		return &receptor_v1.Value{} // Return a default value if needed
-	}
+// RowToStructRow Builds structured row of evidence.  Fields that can't be converted, as described in
+// [receptor_sdk.Evidence], are skipped with a warning.
+func RowToStructRow(row interface{}, entityIdFieldName string, rowFieldNames []string) (reportRow *receptor_v1.Row) {
+	reportRow, _ = newRowConverter(false).row(row, entityIdFieldName, rowFieldNames)
+	return
 }
 
-func getField(v interface{}, field string) string {
-	r := reflect.ValueOf(v)
-	f := reflect.Indirect(r).FieldByName(field)
-	return f.String()
+// RowToStructRowStrict builds a structured row of evidence like [RowToStructRow], but returns an error instead of
+// skipping a field that can't be converted.
+func RowToStructRowStrict(row interface{}, entityIdFieldName string, rowFieldNames []string) (reportRow *receptor_v1.Row, err error) {
+	return newRowConverter(true).row(row, entityIdFieldName, rowFieldNames)
 }
 
 func assertStruct(rowType reflect.Type) (err error) {
@@ -447,9 +458,16 @@ type FilePathsInfo struct {
 	Path     string
 	Metadata map[string]string
 	Mime     string
+	Hash     string // Content hash of the file, as returned by multipartkit.ComputeHash, empty if not computed.
 }
 
-func multipartEvidence(finding *receptor_v1.Finding, streamFilePathsInfo []FilePathsInfo, sources []*receptor_v1.Source) (contentType string, evidencePath string, err error) {
+// multipartEvidence returns the content type of the multipart holding a document evidence with its boundary, and a
+// function writing the multipart.  The multipart holds the Finding, the documents and the Sources of the evidence.
+// Unless '--trailing-hashes=false' is set, files are hashed while they're written, with their hashes sent in a trailing manifest
+// part, so each file is read once per write.  write may be called again to replay the multipart and uses the same
+// boundary each time.  write returns the first error adding a part, such as [multipartkit.ErrBoundaryCollision],
+// which fails the upload instead of sending a corrupt multipart.
+func multipartEvidence(finding *receptor_v1.Finding, streamFilePathsInfo []FilePathsInfo, sources []*receptor_v1.Source) (contentType string, write func(w io.Writer) error, err error) {
 	if len(finding.Evidences) == 0 {
 		err = errors.New("no evidence found")
 		log.Error().Msg("no evidence found")
@@ -461,89 +479,130 @@ func multipartEvidence(finding *receptor_v1.Finding, streamFilePathsInfo []FileP
 	if evidence.EvidenceType == nil {
 		err = errors.New("evidence doc(s) is nil")
 		log.Error().Msg("evidence doc(s) is nil")
-	} else {
+		return
+	}
 
-		// evidence should be protobuf of evidence + blob in a multipart/mixed
-		// the mime of the part should be the mime from the evidence.doc.Mime
-		dstFile, err := os.CreateTemp("", "multipart-evidence_*.tmp")
-		if err != nil {
-			log.Err(err).Msg("failed to create multipart file")
-			return "", "", err
-		}
-		// TOD: Adjust mime for multiple document evidences
-		mime := evidence.GetDoc().GetMime()
-		bufferSize := multipartkit.DefaultBufferSize
+	// evidence should be protobuf of evidence + blob in a multipart/mixed
+	// the mime of the part should be the mime from the evidence.doc.Mime
+	var mime string
+	docs := []*receptor_v1.Document{}
+	switch evidenceDocType := evidence.EvidenceType.(type) {
+	case *receptor_v1.Evidence_Doc:
+		docs = append(docs, evidenceDocType.Doc)
+		mime = evidence.GetDoc().GetMime()
+	case *receptor_v1.Evidence_Docs:
+		docs = evidence.GetDocs().Docs
+		mime = "application/tr-archive"
+	}
 
-		// Initialize the multipart builder
-		builder, err := multipartkit.NewMultipartBuilder(dstFile, bufferSize)
-		defer func() {
-			err = builder.Finalize()
-			if err != nil {
-				log.Err(err).Msg("failed to finalize multipart builder")
-			}
-		}()
+	// the boundary is random per multipart, so the content type must carry the builder's boundary
+	var boundary string
+	if boundary, err = multipartkit.NewBoundary(); err != nil {
+		return
+	}
+	contentType = fmt.Sprintf("%s; %s; boundary=%s", mulitpartPrefix, mime, boundary)
 
+	write = func(w io.Writer) (err error) {
+		// Initialize the multipart builder
+		builder, err := multipartkit.NewMultipartBuilder(w, multipartkit.DefaultBufferSize)
 		if err != nil {
-			log.Error().Msgf("failed to create multipart builder: %v", err)
-			return "", "", err
+			return fmt.Errorf("failed to create multipart builder: %w", err)
 		}
+		if err = builder.SetBoundary(boundary); err != nil {
+			return
+		}
+		if err = builder.SetEncoding(receptor_sdk.Compression); err != nil {
+			return
+		}
+		builder.SetTrailingHashes(receptor_sdk.TrailingHashes)
 
-		boundary := builder.GetBoundary()
-		contentType = fmt.Sprintf("%s; %s; boundary=%s", mulitpartPrefix, mime, boundary)
-
-		// 1. Part1 : protobuf of Finding without evidence
-
-		err = builder.AddProtobuf("receptor_v1.Finding", finding) // need to remove evidences from this finding ...
-		if err != nil {
-			log.Error().Msgf("failed to add protobuf message: %v", err)
+		// 1. Part1 : protobuf of Finding
+		if err = builder.AddProtobuf("receptor_v1.Finding", finding); err != nil {
+			return fmt.Errorf("failed to add protobuf message: %w", err)
 		}
 
 		// 2. Part2 : evidence blob
-		docs := []*receptor_v1.Document{}
-
-		switch evidenceDocType := evidence.EvidenceType.(type) {
-		case *receptor_v1.Evidence_Doc:
-			docs = append(docs, evidenceDocType.Doc)
-			mime = evidence.GetDoc().GetMime()
-		case *receptor_v1.Evidence_Docs:
-			docs = evidence.GetDocs().Docs
-			mime = "application/tr-archive"
-		}
-		contentType = fmt.Sprintf("%s; %s; boundary=%s", mulitpartPrefix, mime, boundary)
 		for _, doc := range docs {
 			if len(doc.Body) > 0 {
 				name := evidence.Caption
 				if doc.FileName != "" {
 					name = doc.FileName
 				}
-				err = builder.AddBytes(name, name, doc.GetMime(), doc.GetBody(), doc.GetMetadata())
-				if err != nil {
-					log.Err(err).Msgf("failed to add blob part: %s", evidence.Caption)
+				if err = builder.AddBytes(name, name, doc.GetMime(), doc.GetBody(), doc.GetMetadata()); err != nil {
+					return fmt.Errorf("failed to add blob part %s: %w", name, err)
 				}
 			}
-
 		}
+
 		// 3. Part3 : evidence paths
 		for _, streamFilePathInfo := range streamFilePathsInfo {
 			if streamFilePathInfo.Path != "" {
-				err = builder.AddFile(streamFilePathInfo.PartName, streamFilePathInfo.FileName, streamFilePathInfo.Path, streamFilePathInfo.Mime, streamFilePathInfo.Metadata)
+				err = builder.AddFileWithHash(streamFilePathInfo.PartName, streamFilePathInfo.FileName, streamFilePathInfo.Path, streamFilePathInfo.Mime, streamFilePathInfo.Hash, streamFilePathInfo.Metadata)
 				if err != nil {
-					log.Err(err).Msgf("failed to add stream file: %s", streamFilePathInfo.Path)
+					return fmt.Errorf("failed to add stream file %s: %w", streamFilePathInfo.Path, err)
 				}
 			}
 		}
 
 		// 4. Part4 : Sources
-		err = builder.AddProtobuf("receptor_v1.Sources", &receptor_v1.Sources{
+		if err = builder.AddProtobuf("receptor_v1.Sources", &receptor_v1.Sources{
 			Sources: sources,
-		})
-		if err != nil {
-			log.Error().Msgf("failed to add sources part: %v", err)
+		}); err != nil {
+			return fmt.Errorf("failed to add sources part: %w", err)
 		}
-		// clear evidences from the temp finding
-		finding.Evidences = []*receptor_v1.Evidence{}
 
-		return contentType, dstFile.Name(), nil
+		// 5. Part5 : manifest of part hashes, with trailing hashes
+		if err = builder.Finalize(); err != nil {
+			return fmt.Errorf("failed to finalize multipart builder: %w", err)
+		}
+		return
 	}
 	return
 }
+
+// removeStreamFiles removes the temporary files of an evidence's documents.
+func removeStreamFiles(evidence *receptor_sdk.Evidence) {
+	for _, doc := range *evidence.Document {
+		if doc.StreamFilePath != "" {
+			os.Remove(doc.StreamFilePath)
+		}
+	}
+}
+
+// hashStreamFiles computes the content hash of each document file before it's streamed, for the idempotency key,
+// the Content-Hash header of its part and the evidence state.  A file that can't be read is left without a hash,
+// and fails to be added to the multipart.
+func hashStreamFiles(paths []FilePathsInfo) {
+	for i := range paths {
+		file, err := os.Open(paths[i].Path)
+		if err == nil {
+			paths[i].Hash, err = multipartkit.ComputeHash(file, multipartkit.DefaultBufferSize)
+			file.Close()
+		}
+		if err != nil {
+			log.Err(err).Msgf("failed to hash stream file %s", paths[i].Path)
+		}
+	}
+}
+
+// documentsKey identifies the content of document files by their content hashes.  Files streamed with trailing
+// hashes aren't hashed before they're sent, so they're identified by their path, size and modification time
+// instead, which a rerun streaming the same files shares.
+func documentsKey(paths []FilePathsInfo) (string, error) {
+	var key strings.Builder
+	for _, path := range paths {
+		id := path.Hash
+		if id == "" {
+			info, err := os.Stat(path.Path)
+			if err != nil {
+				return "", err
+			}
+			id = fmt.Sprintf("%s:%d:%d", path.Path, info.Size(), info.ModTime().UnixNano())
+		}
+		key.WriteString(path.PartName)
+		key.WriteByte(':')
+		key.WriteString(id)
+		key.WriteByte(0)
+	}
+	return key.String(), nil
+}
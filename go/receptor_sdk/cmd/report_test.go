@@ -0,0 +1,194 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/trustero/api/go/receptor_sdk"
+	"github.com/trustero/api/go/receptor_sdk/multipartkit"
+	"github.com/trustero/api/go/receptor_v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// reportClient records the captions of the evidence of each Report call.
+type reportClient struct {
+	receptor_v1.ReceptorClient
+	reports [][]string
+}
+
+func (c *reportClient) Report(_ context.Context, finding *receptor_v1.Finding, _ ...grpc.CallOption) (*wrapperspb.StringValue, error) {
+	var captions []string
+	for _, evidence := range finding.GetEvidences() {
+		captions = append(captions, evidence.GetCaption())
+	}
+	c.reports = append(c.reports, captions)
+	return wrapperspb.String(""), nil
+}
+
+type reportTestUser struct {
+	Username string `trustero:"id;display:Username;order:1"`
+}
+
+// multipartBoundary returns the boundary of a multipart content type.
+func multipartBoundary(t *testing.T, contentType string) string {
+	t.Helper()
+	_, boundary, found := strings.Cut(contentType, "boundary=")
+	if !found {
+		t.Fatalf("content type %q has no boundary", contentType)
+	}
+	return boundary
+}
+
+func TestMultipartEvidenceBoundaryCollision(t *testing.T) {
+	for _, test := range []string{"body", "file"} {
+		doc := &receptor_v1.Document{FileName: "policy.txt", Mime: "text/plain"}
+		finding := &receptor_v1.Finding{Evidences: []*receptor_v1.Evidence{{
+			Caption:      "Policy",
+			EvidenceType: &receptor_v1.Evidence_Doc{Doc: doc},
+		}}}
+		path := filepath.Join(t.TempDir(), "policy.txt")
+		var paths []FilePathsInfo
+		if test == "file" {
+			paths = []FilePathsInfo{{FileName: "policy.txt", PartName: "policy.txt", Path: path, Mime: "text/plain"}}
+		}
+
+		contentType, write, err := multipartEvidence(finding, paths, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the boundary is random, so the colliding content is only known once the multipart is created
+		content := []byte("before\r\n--" + multipartBoundary(t, contentType) + "\r\nafter")
+		if test == "body" {
+			doc.Body = content
+		} else if err = os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// the error reaches the reader of the multipart, failing the upload
+		pr, pw := io.Pipe()
+		go func() { pw.CloseWithError(write(pw)) }()
+		if _, err = io.ReadAll(pr); !errors.Is(err, multipartkit.ErrBoundaryCollision) {
+			t.Errorf("%s: reading the multipart = %v, want ErrBoundaryCollision", test, err)
+		}
+	}
+}
+
+func TestReportEvidenceFailedConversion(t *testing.T) {
+	rc := &reportClient{}
+	finding := &receptor_v1.Finding{}
+	users := &receptor_sdk.Evidence{Caption: "Users", Rows: []interface{}{reportTestUser{Username: "alice"}}}
+	invalid := &receptor_sdk.Evidence{Caption: "Invalid", Rows: []interface{}{"not a struct"}}
+	admins := &receptor_sdk.Evidence{Caption: "Admins", Rows: []interface{}{reportTestUser{Username: "bob"}}}
+
+	// the invalid evidence fails alone, with the rest of its batch reported
+	if err := reportEvidence(context.Background(), rc, nil, nil, finding, []*receptor_sdk.Evidence{users, invalid}); err == nil {
+		t.Error("reporting a batch with an invalid row type succeeded, want an error")
+	}
+	if err := reportEvidence(context.Background(), rc, nil, nil, finding, []*receptor_sdk.Evidence{admins}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rc.reports) != 2 || strings.Join(rc.reports[0], ",") != "Users" || strings.Join(rc.reports[1], ",") != "Admins" {
+		t.Errorf("Report calls = %q, want Users then Admins", rc.reports)
+	}
+	if len(finding.Evidences) != 0 {
+		t.Errorf("finding evidences = %v after the batches, want none", finding.Evidences)
+	}
+}
+
+type reportTestChannel struct {
+	Name    string `trustero:"id;display:Name;order:1"`
+	Updates chan int
+}
+
+func TestReportEvidenceStrictRows(t *testing.T) {
+	defer func(strict bool) { receptor_sdk.StrictRows = strict }(receptor_sdk.StrictRows)
+	receptor_sdk.StrictRows = true
+
+	rc := &reportClient{}
+	users := &receptor_sdk.Evidence{Caption: "Users", Rows: []interface{}{reportTestUser{Username: "alice"}}}
+	channels := &receptor_sdk.Evidence{Caption: "Channels", Rows: []interface{}{reportTestChannel{Name: "updates"}}}
+	admins := &receptor_sdk.Evidence{Caption: "Admins", Rows: []interface{}{reportTestUser{Username: "bob"}}}
+
+	// a row that fails to convert fails its evidence, and the rest of the batch is still reported
+	err := reportEvidence(context.Background(), rc, nil, nil, &receptor_v1.Finding{}, []*receptor_sdk.Evidence{users, channels, admins})
+	if err == nil || !strings.Contains(err.Error(), "Channels: row 0") {
+		t.Errorf("reporting a batch with an unsupported field = %v, want the error of Channels row 0", err)
+	}
+	if len(rc.reports) != 1 || strings.Join(rc.reports[0], ",") != "Users,Admins" {
+		t.Errorf("Report calls = %q, want one with Users and Admins", rc.reports)
+	}
+}
+
+type reportTestNode struct {
+	Name string
+	Next *reportTestNode
+}
+
+type reportTestCycle struct {
+	Id    string `trustero:"id;display:Id;order:1"`
+	Node  *reportTestNode
+	Items []interface{}
+}
+
+func TestRowToStructRowCycles(t *testing.T) {
+	node := &reportTestNode{Name: "self"}
+	node.Next = node
+	items := []interface{}{"item", nil}
+	items[1] = items
+	row := reportTestCycle{Id: "cycle", Node: node, Items: items}
+	fieldNames := []string{"Id", "Node", "Items"}
+
+	// a lenient conversion reports the values referring back as empty values
+	reportRow := RowToStructRow(row, "Id", fieldNames)
+	fields := reportRow.GetCols()["Node"].GetStructListValue().GetValues()
+	if len(fields) != 1 || fields[0].GetFields()["Name"].GetStringValue() != "self" || fields[0].GetFields()["Next"].GetValueType() != nil {
+		t.Errorf("Node = %v, want self with an empty Next", reportRow.GetCols()["Node"])
+	}
+	if list := reportRow.GetCols()["Items"].GetStringListValue().GetValues(); strings.Join(list, ",") != "item," {
+		t.Errorf("Items = %q, want item and an empty value", list)
+	}
+
+	if _, err := RowToStructRowStrict(row, "Id", fieldNames); err == nil || !strings.Contains(err.Error(), "Node.Next") {
+		t.Errorf("strict conversion = %v, want an error for Node.Next", err)
+	}
+}
+
+func TestDocumentsKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.txt")
+	if err := os.WriteFile(path, []byte("all users must use MFA\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	paths := []FilePathsInfo{{PartName: "policy.txt", Path: path}}
+
+	// files hashed as they're streamed are keyed by their path, size and modification time
+	first, err := documentsKey(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second, _ := documentsKey(paths); second != first {
+		t.Errorf("documentsKey = %q then %q, want the same key for the same file", first, second)
+	}
+	if err = os.WriteFile(path, []byte("all users must use SMS or MFA\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := documentsKey(paths); changed == first {
+		t.Errorf("documentsKey = %q after the file changed, want a new key", changed)
+	}
+
+	hashed, err := documentsKey([]FilePathsInfo{{PartName: "policy.txt", Path: path, Hash: "hash"}})
+	if err != nil || hashed != "policy.txt:hash\x00" {
+		t.Errorf("documentsKey of a hashed file = %q, %v, want its hash", hashed, err)
+	}
+	if _, err = documentsKey([]FilePathsInfo{{PartName: "missing.txt", Path: path + ".missing"}}); err == nil {
+		t.Error("documentsKey of a missing file succeeded, want an error")
+	}
+}
@@ -0,0 +1,110 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/trustero/api/go/receptor_sdk"
+	"github.com/trustero/api/go/receptor_sdk/client"
+	"github.com/trustero/api/go/receptor_v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// retryReceptorClient wraps a [receptor_v1.ReceptorClient] and retries its unary calls according to a
+// [client.RetryPolicy].  Discovered and Report calls carry an idempotency key so Trustero can deduplicate
+// replayed requests, derived from the request unless the call's context already carries one.  StreamReport is passed through since a stream can only be replayed by its sender, see
+// streamEvidence.
+type retryReceptorClient struct {
+	receptor_v1.ReceptorClient
+	policy client.RetryPolicy
+}
+
+// withDefaultIdempotencyKey sends key as the idempotency key of a call unless ctx already carries one, such as the
+// key a bundle entry was recorded with.
+func withDefaultIdempotencyKey(ctx context.Context, key string) context.Context {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(client.IdempotencyKeyHeader)) > 0 {
+		return ctx
+	}
+	return client.WithIdempotencyKey(ctx, key)
+}
+
+func newRetryReceptorClient(rc receptor_v1.ReceptorClient) *retryReceptorClient {
+	policy := client.DefaultRetryPolicy
+	if receptor_sdk.RetryAttempts > 0 {
+		policy.MaxAttempts = receptor_sdk.RetryAttempts
+	}
+	return &retryReceptorClient{ReceptorClient: rc, policy: policy}
+}
+
+func (rc *retryReceptorClient) Verified(ctx context.Context, in *receptor_v1.Credential, opts ...grpc.CallOption) (res *emptypb.Empty, err error) {
+	err = rc.policy.Do(ctx, "Verified", func(ctx context.Context) (err error) {
+		res, err = rc.ReceptorClient.Verified(ctx, in, opts...)
+		return
+	})
+	return
+}
+
+func (rc *retryReceptorClient) GetConfiguration(ctx context.Context, in *receptor_v1.ReceptorOID, opts ...grpc.CallOption) (res *receptor_v1.ReceptorConfiguration, err error) {
+	err = rc.policy.Do(ctx, "GetConfiguration", func(ctx context.Context) (err error) {
+		res, err = rc.ReceptorClient.GetConfiguration(ctx, in, opts...)
+		return
+	})
+	return
+}
+
+func (rc *retryReceptorClient) Discovered(ctx context.Context, in *receptor_v1.ServiceEntities, opts ...grpc.CallOption) (res *wrapperspb.StringValue, err error) {
+	ctx = withDefaultIdempotencyKey(ctx, client.IdempotencyKey(receptor_sdk.DiscoveryId, "Discovered", messageHash(in)))
+	err = rc.policy.Do(ctx, "Discovered", func(ctx context.Context) (err error) {
+		res, err = rc.ReceptorClient.Discovered(ctx, in, opts...)
+		return
+	})
+	return
+}
+
+func (rc *retryReceptorClient) Report(ctx context.Context, in *receptor_v1.Finding, opts ...grpc.CallOption) (res *wrapperspb.StringValue, err error) {
+	ctx = withDefaultIdempotencyKey(ctx, client.IdempotencyKey(in.GetDiscoveryId(), "Report", messageHash(in)))
+	err = rc.policy.Do(ctx, "Report", func(ctx context.Context) (err error) {
+		res, err = rc.ReceptorClient.Report(ctx, in, opts...)
+		return
+	})
+	return
+}
+
+func (rc *retryReceptorClient) Notify(ctx context.Context, in *receptor_v1.JobResult, opts ...grpc.CallOption) (res *emptypb.Empty, err error) {
+	err = rc.policy.Do(ctx, "Notify", func(ctx context.Context) (err error) {
+		res, err = rc.ReceptorClient.Notify(ctx, in, opts...)
+		return
+	})
+	return
+}
+
+func (rc *retryReceptorClient) SetConfiguration(ctx context.Context, in *receptor_v1.ReceptorConfiguration, opts ...grpc.CallOption) (res *emptypb.Empty, err error) {
+	err = rc.policy.Do(ctx, "SetConfiguration", func(ctx context.Context) (err error) {
+		res, err = rc.ReceptorClient.SetConfiguration(ctx, in, opts...)
+		return
+	})
+	return
+}
+
+// retryPolicy returns the retry policy of rc, or a single attempt policy if rc doesn't retry.
+func retryPolicy(rc receptor_v1.ReceptorClient) client.RetryPolicy {
+	if r, ok := rc.(*retryReceptorClient); ok {
+		return r.policy
+	}
+	return client.RetryPolicy{MaxAttempts: 1}
+}
+
+// messageHash returns the hex encoded SHA-256 hash of the deterministic encoding of a protobuf message.
+func messageHash(m proto.Message) string {
+	bytes, _ := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	hash := sha256.Sum256(bytes)
+	return hex.EncodeToString(hash[:])
+}
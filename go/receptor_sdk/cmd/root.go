@@ -7,13 +7,19 @@
 package cmd
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
-	"golang.org/x/net/context"
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
@@ -27,6 +33,7 @@ var rootCmd command
 var cfgFile string                     // Configuration file as an alternative to command line flags
 var serviceProviderAccount string      // Receptor's configured service provider account
 var receptorImpl receptor_sdk.Receptor // Receptor implementation
+var initOnce sync.Once
 
 const (
 	rootShortDesc = "Run a receptor in one of 2 modes: verify or scan."
@@ -48,12 +55,23 @@ var cmds = map[string]command{
 	"logo":         &logor{},
 	"instructions": &instruct{},
 	"configure":    &confi{},
+	"upload":       &uploader{},
+	"lint":         &linter{},
+	"schema":       &schemer{},
+	"diff":         &differ{},
 }
 
 // Execute is the entry point into the CLI framework.  Receptor author implements the [receptor_sdk.Receptor]
 // interface and the CLI framework takes care of the rest.
 func Execute(r receptor_sdk.Receptor) {
-	cobra.OnInitialize(initConfig)
+	cobra.CheckErr(ExecuteArgs(r, os.Args[1:]))
+}
+
+// ExecuteArgs runs the CLI framework with the given command line arguments instead of the process arguments and
+// returns the command's error instead of exiting.  ExecuteArgs is intended for tests driving a receptor through
+// the CLI, see the receptortest package.
+func ExecuteArgs(r receptor_sdk.Receptor, args []string) error {
+	initOnce.Do(func() { cobra.OnInitialize(initConfig) })
 
 	// initialize cobra commands
 	rootCmd = &root{}
@@ -68,7 +86,8 @@ func Execute(r receptor_sdk.Receptor) {
 	rootCmd.getCommand().Use = receptor_sdk.ModelID
 	_ = addCredentialFlags(r.GetCredentialObj())
 
-	cobra.CheckErr(rootCmd.getCommand().Execute())
+	rootCmd.getCommand().SetArgs(args)
+	return rootCmd.getCommand().Execute()
 }
 
 type command interface {
@@ -95,6 +114,8 @@ func (r *root) setup() {
 	addStrFlag(r.cmd, &cfgFile, "config-file", "", "", "Config file, defaults to $HOME/.receptor.yaml")
 	addStrFlag(r.cmd, &receptor_sdk.LogLevel, "level", "l", "error", "trace, debug, info, warn, error, fatal, or panic")
 	addStrFlag(r.cmd, &receptor_sdk.LogFile, "log-file", "", "", "Log file path")
+	addStrSliceFlag(r.cmd, &receptor_sdk.RedactPatterns, "redact-pattern", "", nil,
+		"Regular expression of secrets to scrub from evidence sources, logs and dry-run output, may be repeated")
 }
 
 func addGrpcFlags(cmd *cobra.Command) {
@@ -106,9 +127,13 @@ func addGrpcFlags(cmd *cobra.Command) {
 	addBoolFlag(cmd, &receptor_sdk.NoSave, "nosave", "n", false, "Send results to console instead of Trustero")
 	addStrFlag(cmd, &receptor_sdk.Notify, "notify", "", "", "Notify Trustero with Tracer ID on command completion")
 	addStrFlag(cmd, &receptor_sdk.CredentialsBase64URL, "credentials", "", "", "Base64 URL encoded service provider credential")
+	addStrFlag(cmd, &receptor_sdk.CredentialsFrom, "credentials-from", "", "",
+		"Read service provider credentials from env[:NAME], file:PATH, fd:N, stdin or exec:COMMAND")
 	addStrFlag(cmd, &receptor_sdk.ConfigBase64URL, "config", "", "", "Base64 URL encoded receptor configuration")
 	addStrFlag(cmd, &receptor_sdk.DiscoveryId, "discovery-id", "", "", "Trustero discovery identifier")
 	addIntFlag(cmd, &receptor_sdk.ConnectTimeout, "connect-timeout", "", 10, "Timeout in seconds to wait for GRPC connection readiness")
+	addIntFlag(cmd, &receptor_sdk.Timeout, "timeout", "", 0, "Timeout in seconds for the command to complete, 0 for no timeout")
+	addIntFlag(cmd, &receptor_sdk.RetryAttempts, "retry-attempts", "", client.DefaultRetryPolicy.MaxAttempts, "Maximum number of attempts of a Trustero GRPC call")
 
 }
 
@@ -124,6 +149,12 @@ func addStrFlag(cmd *cobra.Command, p *string, name, shorthand, value, usage str
 	})
 }
 
+func addStrSliceFlag(cmd *cobra.Command, p *[]string, name, shorthand string, value []string, usage string) {
+	addFlag(cmd, name, value, func() {
+		cmd.PersistentFlags().StringSliceVarP(p, name, shorthand, value, usage)
+	})
+}
+
 func addIntFlag(cmd *cobra.Command, p *int, name, shorthand string, value int, usage string) {
 	addFlag(cmd, name, value, func() {
 		cmd.PersistentFlags().IntVarP(p, name, shorthand, value, usage)
@@ -147,7 +178,8 @@ func addFlag(cmd *cobra.Command, name string, value interface{}, addFlag func())
 func grpcPreRun(_ *cobra.Command, args []string) {
 	// If the first argument is 'dryrun' then do not report the command
 	// results to Trustero.  Instead, display the results to console.
-	if len(args) > 0 && args[0] != "dryrun" {
+	// A scan recorded to an offline bundle doesn't contact Trustero either.
+	if len(args) > 0 && args[0] != "dryrun" && len(receptor_sdk.BundlePath) == 0 {
 		client.InitGRPCClient(receptor_sdk.Cert, receptor_sdk.CertServerOverride)
 	} else {
 		receptor_sdk.NoSave = true
@@ -184,7 +216,10 @@ func initConfig() {
 		log.Debug().Msgf("Using config file: %s", viper.ConfigFileUsed())
 	}
 
-	// Initialize zerolog
+	// Scrub secrets matching the --redact-pattern flags, then initialize zerolog
+	for _, pattern := range receptor_sdk.RedactPatterns {
+		cobra.CheckErr(receptor_sdk.DefaultRedactor.AddPattern(pattern))
+	}
 	initLog(receptor_sdk.LogLevel, receptor_sdk.LogFile)
 
 	// Set GRPC host related flags if we see Host set to api.infra.trustero.com
@@ -195,9 +230,24 @@ func initConfig() {
 	}
 }
 
-type commandInContext func(rc receptor.ReceptorClient, credentials interface{}, config interface{}) error
+type commandInContext func(ctx context.Context, rc receptor.ReceptorClient, credentials interface{}, config interface{}) error
+
+func invokeWithContext(command, token string, run commandInContext) (err error) {
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	// Summarize the command, writing the summary to the --summary-file once the command completes
+	summary = newRunSummary(command)
+	defer func() {
+		if summaryErr := summary.writeFile(); summaryErr != nil {
+			if err == nil {
+				err = summaryErr
+			} else {
+				log.Err(summaryErr).Msg("failed to write run summary")
+			}
+		}
+	}()
 
-func invokeWithContext(token string, run commandInContext) (err error) {
 	var (
 		rc            receptor.ReceptorClient
 		credentialStr string
@@ -210,16 +260,28 @@ func invokeWithContext(token string, run commandInContext) (err error) {
 	if rc, err = getReceptorClient(token); err != nil {
 		return
 	}
+	// Flush clients recording the command, such as an offline bundle, once the command completes
+	if closer, ok := rc.(io.Closer); ok {
+		defer func() {
+			if closeErr := closer.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+	}
 
 	// Get service provider account credentialStr from --credentials CLI flag
-	credentialStr, err = getCredentialStringFromCLI()
+	if credentialStr, err = getCredentialStringFromCLI(ctx); err != nil {
+		return
+	}
 	// Get receptor configuration from --config CLI flag
-	configStr, err = getConfigStringFromCLI()
+	if configStr, err = getConfigStringFromCLI(); err != nil {
+		return fmt.Errorf("invalid --config flag: %w", err)
+	}
 	// If credentialStr not provided on CLI, get it from Trustero server
 	if !receptor_sdk.NoSave {
 		// Get service provider account credentialStr and config from Trustero.
 		var receptorInfo *receptor.ReceptorConfiguration
-		if receptorInfo, err = getReceptorConfig(rc); err != nil {
+		if receptorInfo, err = getReceptorConfig(ctx, rc); err != nil {
 			return err
 		}
 		if len(credentialStr) == 0 {
@@ -234,23 +296,30 @@ func invokeWithContext(token string, run commandInContext) (err error) {
 
 	// Unmarshal json string credential
 	if len(credentialStr) > 0 {
-		credentialObj, err = unmarshalCredentials(credentialStr, receptorImpl.GetCredentialObj())
+		if credentialObj, err = unmarshalCredentials(credentialStr, receptorImpl.GetCredentialObj()); err != nil {
+			return fmt.Errorf("failed to decode credentials: %w", err)
+		}
 	} else {
 		// If there is no credential json string provided, assume the credentials are set through
 		// credential-specific CLI flags
 		credentialObj = receptorImpl.GetCredentialObj()
 	}
+	// Scrub the credentials' secrets from sources, logs and dry-run output
+	redactCredentials(credentialObj)
+	receptor_sdk.DefaultRedactor.AddSecret(receptor_sdk.CredentialsBase64URL)
 
 	// Unmarshal json string config
 	if len(configStr) > 0 && configStr != "{}" {
-		configObj, err = unmarshalConfig(configStr, receptorImpl.GetConfigObj(credentialObj))
+		if configObj, err = unmarshalConfig(configStr, receptorImpl.GetConfigObj(credentialObj)); err != nil {
+			return fmt.Errorf("failed to decode config: %w", err)
+		}
 	} else {
 		configObj = receptorImpl.GetConfigObj(credentialObj)
 	}
 
-	// Invoke receptor's method
-	if err == nil {
-		err = run(rc, credentialObj, configObj)
+	// Validate decoded credentials and config, then invoke receptor's method
+	if err = validate(credentialObj, configObj); err == nil {
+		err = run(ctx, rc, credentialObj, configObj)
 	}
 
 	// Log error
@@ -262,7 +331,10 @@ func invokeWithContext(token string, run commandInContext) (err error) {
 }
 
 func getReceptorClient(token string) (rc receptor.ReceptorClient, err error) {
-	if receptor_sdk.NoSave {
+	if len(receptor_sdk.BundlePath) > 0 {
+		// Record the command's requests to an offline bundle
+		rc, err = newBundleReceptorClient(receptor_sdk.BundlePath)
+	} else if receptor_sdk.NoSave {
 		// Mock client
 		rc = &mockReceptorClient{}
 	} else {
@@ -277,42 +349,65 @@ func getReceptorClient(token string) (rc receptor.ReceptorClient, err error) {
 		if err = client.ServerConn.DialAndWait(token, receptor_sdk.Host, receptor_sdk.Port, timeout); err != nil {
 			return
 		}
-		// Get grpc client
-		rc = client.ServerConn.GetReceptorClient()
+		// Get grpc client retrying transient failures
+		rc = newRetryReceptorClient(client.ServerConn.GetReceptorClient())
 	}
 	return
 }
 
-func getReceptorConfig(rc receptor.ReceptorClient) (config *receptor.ReceptorConfiguration, err error) {
-	config, err = rc.GetConfiguration(context.Background(), &receptor.ReceptorOID{ReceptorObjectId: receptor_sdk.ReceptorId})
+func getReceptorConfig(ctx context.Context, rc receptor.ReceptorClient) (config *receptor.ReceptorConfiguration, err error) {
+	config, err = rc.GetConfiguration(ctx, &receptor.ReceptorOID{ReceptorObjectId: receptor_sdk.ReceptorId})
 	return
 }
 
-func notify(rc receptor.ReceptorClient, command, result string, exceptions string, e error) (err error) {
+func notify(ctx context.Context, rc receptor.ReceptorClient, command, result string, exceptions string, e error) (err error) {
 	if e != nil {
 		result = "error"
 	}
 
+	// Notify Trustero even if the command was canceled or timed out.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyTimeout)
+	defer cancel()
+
 	res := receptor.JobResult{
 		TracerId:         receptor_sdk.Notify,
 		ReceptorObjectId: receptor_sdk.ReceptorId,
 		Command:          command,
 		Result:           result,
 		Exceptions:       exceptions,
+		Summary:          summary.proto(),
 	}
 
-	_, err = rc.Notify(context.Background(), &res)
+	_, err = rc.Notify(ctx, &res)
 
 	return
 }
 
-func getCredentialStringFromCLI() (credentials string, err error) {
+func getCredentialStringFromCLI(ctx context.Context) (credentials string, err error) {
+	// Get credentials from the provider selected with the --credentials-from CLI flag
+	if len(receptor_sdk.CredentialsFrom) > 0 {
+		if len(receptor_sdk.CredentialsBase64URL) > 0 {
+			return "", errors.New("--credentials and --credentials-from can't be used together")
+		}
+		var (
+			provider receptor_sdk.CredentialProvider
+			creds    []byte
+		)
+		if provider, err = receptor_sdk.NewCredentialProvider(receptor_sdk.CredentialsFrom); err != nil {
+			return "", fmt.Errorf("invalid --credentials-from flag: %w", err)
+		}
+		if creds, err = provider.Credentials(ctx); err != nil {
+			return "", fmt.Errorf("failed to get credentials from %s: %w", receptor_sdk.CredentialsFrom, err)
+		}
+		return string(creds), nil
+	}
+
 	// Extract credentials from --credentials CLI flag
 	if len(receptor_sdk.CredentialsBase64URL) > 0 {
 		// Get credentials from the --credentials flag
 		var creds []byte
 		if creds, err = base64.URLEncoding.DecodeString(receptor_sdk.CredentialsBase64URL); err != nil {
-			return
+			return "", fmt.Errorf("invalid --credentials flag: %w", err)
 		}
 		credentials = string(creds)
 	}
@@ -339,8 +434,29 @@ func unmarshalCredentials(credentials string, credentialsObj interface{}) (obj i
 	return
 }
 
+// unmarshalConfig decodes a json string config into configObj if it's a pointer, typically to the receptor's
+// config struct.  Otherwise, the config is decoded into a generic map[string]interface{}.
 func unmarshalConfig(config string, configObj interface{}) (obj interface{}, err error) {
-	err = json.Unmarshal([]byte(config), &configObj)
+	if configObj != nil && reflect.ValueOf(configObj).Kind() == reflect.Pointer {
+		err = json.Unmarshal([]byte(config), configObj)
+	} else {
+		err = json.Unmarshal([]byte(config), &configObj)
+	}
 	obj = configObj
 	return
 }
+
+// validate calls Validate on credentials and config implementing [receptor_sdk.Validator].
+func validate(credentialObj interface{}, configObj interface{}) (err error) {
+	if v, ok := credentialObj.(receptor_sdk.Validator); ok {
+		if err = v.Validate(); err != nil {
+			return fmt.Errorf("invalid credentials: %w", err)
+		}
+	}
+	if v, ok := configObj.(receptor_sdk.Validator); ok {
+		if err = v.Validate(); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	return
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/trustero/api/go/receptor_sdk"
+	"github.com/trustero/api/go/receptor_sdk/multipartkit"
 	"github.com/trustero/api/go/receptor_v1"
 )
 
@@ -21,7 +22,12 @@ Scan for services and evidences in-use in a service provider account.  Scan
 command decodes the base64 URL encoded credentials from the '--credentials'
 command line flag and check it's validity.  If 'dryrun' is specified instead
 of a Trustero access token, the scan command will not report the results to
-Trustero and instead print the results to console.`
+Trustero and instead print the results to console.  Documents found in a
+dryrun scan are verified and, if '--output-dir' is specified, written to
+that directory along with a record of each finding, which the diff command
+compares.  If '--bundle' is specified, the scan results are recorded to an
+offline bundle file instead, and the access token may be omitted.  Use the
+upload command to send the bundle to Trustero.`
 )
 
 type scann struct {
@@ -37,7 +43,7 @@ func (s *scann) setup() {
 		Use:          scanUse,
 		Short:        scanShort,
 		Long:         scanLong,
-		Args:         cobra.MinimumNArgs(1),
+		Args:         scanArgs,
 		PreRun:       grpcPreRun,
 		RunE:         scan,
 		PostRun:      grpcPostRun,
@@ -45,38 +51,92 @@ func (s *scann) setup() {
 	}
 	s.cmd.FParseErrWhitelist.UnknownFlags = true
 	addGrpcFlags(s.cmd)
+	addStrFlag(s.cmd, &receptor_sdk.SummaryFile, "summary-file", "", "", "Path of a JSON file to write the run summary of the command to")
 	addBoolFlag(s.cmd, &receptor_sdk.FindEvidence, "find-evidence", "", false,
 		"Scan for evidences in a service provider account")
+	addStrFlag(s.cmd, &receptor_sdk.OutputDir, "output-dir", "", "",
+		"Directory to write documents and findings to in a dryrun scan")
+	addStrFlag(s.cmd, &receptor_sdk.BundlePath, "bundle", "", "",
+		"Offline bundle file to record the scan to instead of reporting to Trustero")
+	addStrFlag(s.cmd, &receptor_sdk.Compression, "compression", "", "",
+		"Content encoding of streamed documents: gzip, zstd, or empty for none")
+	addIntFlag(s.cmd, &receptor_sdk.ChunkSize, "chunk-size", "", defaultChunkSize,
+		"Maximum size in bytes of a chunk of a streamed document upload")
+	addIntFlag(s.cmd, &receptor_sdk.UploadConcurrency, "upload-concurrency", "", 1,
+		"Maximum number of document evidence uploads in progress at once")
+	addBoolFlag(s.cmd, &receptor_sdk.TrailingHashes, "trailing-hashes", "", true,
+		"Hash streamed document files as they're sent, in a trailing manifest part, instead of reading them twice")
+	addBoolFlag(s.cmd, &receptor_sdk.StrictRows, "strict-rows", "", false,
+		"Fail the scan on an evidence row field that can't be converted instead of skipping it")
+	addStrFlag(s.cmd, &receptor_sdk.TableFormat, "table-format", "", "",
+		"Format of the evidence tables printed by a dryrun scan: text, markdown, csv, json or html (default legacy layout)")
+	addStrFlag(s.cmd, &receptor_sdk.TableTimezone, "table-timezone", "", "UTC",
+		"IANA time zone of the timestamps in the evidence tables printed by a dryrun scan")
+	addStrFlag(s.cmd, &receptor_sdk.StateDir, "state-dir", "", "",
+		"Directory of the evidence state store, to not report again evidence unchanged since it was last reported")
+	addStrFlag(s.cmd, &receptor_sdk.UnchangedEvidence, "unchanged-evidence", "", unchangedMarker,
+		"How evidence found unchanged in the --state-dir store is reported: marker, as an unchanged since marker, or skip")
+}
+
+// scanArgs requires a Trustero access token or 'dryrun' unless the scan is recorded to an offline bundle.
+func scanArgs(cmd *cobra.Command, args []string) error {
+	if len(receptor_sdk.BundlePath) > 0 {
+		return nil
+	}
+	return cobra.MinimumNArgs(1)(cmd, args)
 }
 
 // Cobra executes this function on verify command.
 func scan(_ *cobra.Command, args []string) (err error) {
+	if err = multipartkit.ValidEncoding(receptor_sdk.Compression); err != nil {
+		return
+	}
+	if _, _, err = tableRenderer(); err != nil {
+		return
+	}
+	if err = validUnchangedEvidence(); err != nil {
+		return
+	}
+	if receptor_sdk.NoSave && len(receptor_sdk.BundlePath) == 0 && len(receptor_sdk.OutputDir) > 0 {
+		if err = startDryRun(receptor_sdk.OutputDir); err != nil {
+			return
+		}
+	}
+
+	token := "dryrun"
+	if len(args) > 0 {
+		token = args[0]
+	}
+
 	// Run receptor's Verify function and report results to Trustero
-	err = invokeWithContext(args[0],
-		func(rc receptor_v1.ReceptorClient, credentials interface{}, config interface{}) (err error) {
+	command := "discover"
+	if receptor_sdk.FindEvidence {
+		command = "scan"
+	}
+	err = invokeWithContext(command, token,
+		func(ctx context.Context, rc receptor_v1.ReceptorClient, credentials interface{}, config interface{}) (err error) {
 			defer func() {
 				if len(receptor_sdk.Notify) == 0 {
 					return
 				}
-				if receptor_sdk.FindEvidence {
-					notify(rc, "scan", "successful", "", err)
-				} else {
-					notify(rc, "discover", "successful", "", err)
-				}
+				notify(ctx, rc, command, "successful", "", err)
 			}()
 
 			// Verify credentials.
 			var ok bool
-			if ok, err = receptorImpl.Verify(credentials, config); err != nil {
+			done := summary.phase("verify")
+			ok, err = verifyReceptor(ctx, credentials, config)
+			done(err)
+			if err != nil {
 				log.Err(err).Msg("error verifying credentials")
 				if !ok {
-					_, err = rc.Verified(context.Background(), toVerifyResult(ok, err))
+					_, err = rc.Verified(ctx, toVerifyResult(ok, err))
 				}
 				return
 			}
 
 			// Let Trustero know the credentials have been verified.
-			_, err = rc.Verified(context.Background(), toVerifyResult(ok, err))
+			_, err = rc.Verified(ctx, toVerifyResult(ok, err))
 			if !ok {
 				return
 			}
@@ -86,19 +146,21 @@ func scan(_ *cobra.Command, args []string) (err error) {
 				if err != nil {
 					return err
 				}
-				_, err = rc.SetConfiguration(context.Background(), &receptor_v1.ReceptorConfiguration{
+				done := summary.phase("configure")
+				_, err = rc.SetConfiguration(ctx, &receptor_v1.ReceptorConfiguration{
 					ReceptorObjectId: receptor_sdk.ReceptorId,
 					Config:           string(jsonBytes),
 					ModelId:          receptorImpl.GetReceptorType(),
 				})
+				done(err)
 			}
 
 			// Report evidence discovered in the service provider account
 			if receptor_sdk.FindEvidence {
-				err = report(rc, credentials, config)
+				err = report(ctx, rc, credentials, config)
 			} else {
 				// Discover services in-use in the service provider account only run if --find-evidence is not run since discover runs in report
-				if err = discover(rc, credentials, config); err != nil {
+				if err = discover(ctx, rc, credentials, config); err != nil {
 					return
 				}
 			}
@@ -0,0 +1,430 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/trustero/api/go/receptor_sdk"
+	"github.com/trustero/api/go/receptor_v1"
+)
+
+const (
+	schemaUse   = "schema [credentials|config|evidences]"
+	schemaShort = "Print JSON Schemas of the receptor's credentials, config and evidence rows"
+	schemaLong  = `
+Print JSON Schema documents describing the receptor's credential struct, its
+config descriptor and the row types of the evidence returned by GetEvidenceInfo,
+so setup forms can be rendered and validated without knowing the receptor.
+Trustero specific annotations, such as a row field's display order, are
+properties prefixed with "x-trustero-".  Schema command prints all the schemas,
+or only the schemas of the given kind.`
+
+	jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+)
+
+type schemer struct {
+	cmd *cobra.Command
+}
+
+func (s *schemer) getCommand() *cobra.Command {
+	return s.cmd
+}
+
+func (s *schemer) setup() {
+	s.cmd = &cobra.Command{
+		Use:          schemaUse,
+		Short:        schemaShort,
+		Long:         schemaLong,
+		Args:         cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+		ValidArgs:    []string{"credentials", "config", "evidences"},
+		RunE:         printSchemas,
+		SilenceUsage: true,
+	}
+	s.cmd.FParseErrWhitelist.UnknownFlags = true
+}
+
+// jsonSchema is a JSON Schema, along with the "x-trustero-" annotations of the receptor's tags and descriptors.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	WriteOnly            bool                   `json:"writeOnly,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+
+	Placeholder     string      `json:"x-trustero-placeholder,omitempty"`
+	Secret          bool        `json:"x-trustero-secret,omitempty"`
+	Method          string      `json:"x-trustero-method,omitempty"`
+	InputType       string      `json:"x-trustero-input-type,omitempty"`
+	Options         interface{} `json:"x-trustero-options,omitempty"`
+	EvidenceCaption string      `json:"x-trustero-evidence-caption,omitempty"`
+	ServiceModelID  string      `json:"x-trustero-service-model-id,omitempty"`
+	Id              bool        `json:"x-trustero-id,omitempty"`
+	Order           int         `json:"x-trustero-order,omitempty"`
+	Check           string      `json:"x-trustero-check,omitempty"`
+	DisplayOrder    []string    `json:"x-trustero-display-order,omitempty"`
+}
+
+type evidenceSchema struct {
+	Caption     string      `json:"caption"`
+	Description string      `json:"description,omitempty"`
+	ServiceName string      `json:"serviceName,omitempty"`
+	EntityType  string      `json:"entityType,omitempty"`
+	Schema      *jsonSchema `json:"schema"`
+}
+
+type receptorSchemas struct {
+	ReceptorType string            `json:"receptorType"`
+	Credentials  *jsonSchema       `json:"credentials,omitempty"`
+	Config       []*jsonSchema     `json:"config,omitempty"`
+	Evidences    []*evidenceSchema `json:"evidences,omitempty"`
+}
+
+// Cobra executes this function on schema command.
+func printSchemas(_ *cobra.Command, args []string) (err error) {
+	credentials := receptorImpl.GetCredentialObj()
+	schemas := receptorSchemas{ReceptorType: GetParsedReceptorType()}
+	kind := ""
+	if len(args) > 0 {
+		kind = args[0]
+	}
+
+	if kind == "" || kind == "credentials" {
+		if schemas.Credentials, err = credentialsSchema(schemas.ReceptorType, credentials); err != nil {
+			return
+		}
+	}
+	if kind == "" || kind == "config" {
+		schemas.Config = configSchemas(receptorImpl.GetConfigObjDesc())
+	}
+	if kind == "" || kind == "evidences" {
+		schemas.Evidences = evidenceSchemas(receptorImpl.GetEvidenceInfo(credentials))
+	}
+
+	var bytes []byte
+	if bytes, err = json.MarshalIndent(schemas, "", "  "); err == nil {
+		fmt.Println(string(bytes))
+	}
+	return
+}
+
+// credentialsSchema returns the schema of the credential struct.  Fields tagged 'required' are required, or, if
+// they belong to an auth method, required along with the other required fields of the method.  Fields tagged
+// 'secret' or with a password input type are write-only.
+func credentialsSchema(receptorType string, credentials interface{}) (schema *jsonSchema, err error) {
+	t := reflect.TypeOf(credentials)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("GetCredentialObj must return a pointer to a struct, got %v", t)
+	}
+	t = t.Elem()
+
+	schema = &jsonSchema{
+		Schema:               jsonSchemaDialect,
+		Title:                receptorType + " credentials",
+		Type:                 "object",
+		Properties:           map[string]*jsonSchema{},
+		AdditionalProperties: false,
+	}
+	methodRequired := map[string][]string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tags := expandFieldTag(field)
+		name := jsonFieldName(field)
+		property := &jsonSchema{
+			Type:        "string",
+			Title:       getTagField(tags, displayField, ""),
+			Pattern:     getTagField(tags, patternField, ""),
+			Placeholder: getTagField(tags, placeholderField, ""),
+			Method:      getTagField(tags, methodField, ""),
+			InputType:   getTagField(tags, inputTypeField, ""),
+		}
+		if _, ok := tags[secretField]; ok || strings.EqualFold(property.InputType, "password") {
+			property.Secret, property.WriteOnly = true, true
+		}
+		schema.Properties[name] = property
+
+		if _, ok := tags[requiredField]; ok {
+			if len(property.Method) > 0 {
+				methodRequired[property.Method] = append(methodRequired[property.Method], name)
+			} else {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	}
+
+	// the required fields of one of the auth methods must be present
+	methods := make([]string, 0, len(methodRequired))
+	for method := range methodRequired {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		schema.AnyOf = append(schema.AnyOf, &jsonSchema{Method: method, Required: methodRequired[method]})
+	}
+	return
+}
+
+// jsonFieldName returns the name of a credential field in the JSON object credentials are decoded from.
+func jsonFieldName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); len(name) > 0 && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+// configSchemas returns the schemas of the config modals of the config descriptor.
+func configSchemas(desc interface{}) (schemas []*jsonSchema) {
+	var configs []receptor_sdk.Config
+	switch c := desc.(type) {
+	case nil:
+		return nil
+	case receptor_sdk.Config:
+		configs = []receptor_sdk.Config{c}
+	case *receptor_sdk.Config:
+		configs = []receptor_sdk.Config{*c}
+	case []receptor_sdk.Config:
+		configs = c
+	default:
+		// a custom descriptor, passed through as is
+		return []*jsonSchema{{Schema: jsonSchemaDialect, Type: "object", Options: desc}}
+	}
+
+	for _, config := range configs {
+		schema := &jsonSchema{
+			Schema:      jsonSchemaDialect,
+			Title:       config.Title,
+			Description: config.Description,
+			Type:        "object",
+			Properties:  map[string]*jsonSchema{},
+		}
+		for _, field := range config.Fields {
+			schema.Properties[field.Field] = &jsonSchema{
+				Title:           field.Display,
+				Placeholder:     field.Placeholder,
+				InputType:       field.InputType,
+				Options:         field.Options,
+				EvidenceCaption: field.EvidenceCaption,
+				ServiceModelID:  field.ServiceModelID,
+			}
+		}
+		schemas = append(schemas, schema)
+	}
+	return
+}
+
+// evidenceSchemas returns the row schemas of the evidence, from their runtime struct or from their row type, see
+// [evidenceRowTypes].  Evidence without either has no row schema.
+func evidenceSchemas(evidences []*receptor_sdk.Evidence) (schemas []*evidenceSchema) {
+	for _, evidence := range evidences {
+		if evidence == nil {
+			continue
+		}
+		var schema *jsonSchema
+		if evidence.Struct != nil {
+			schema = structSchema(evidence.Struct, evidence.Columns)
+		} else if types := evidenceRowTypes(evidence); len(types) > 0 && types[0].Kind() == reflect.Struct {
+			schema = rowSchema(types[0])
+		}
+		if schema == nil {
+			continue
+		}
+		schema.Schema = jsonSchemaDialect
+		schema.Title = evidence.Caption
+		schema.Description = evidence.Description
+		schemas = append(schemas, &evidenceSchema{
+			Caption:     evidence.Caption,
+			Description: evidence.Description,
+			ServiceName: evidence.ServiceName,
+			EntityType:  evidence.EntityType,
+			Schema:      schema,
+		})
+	}
+	return
+}
+
+// rowSchema returns the schema of an evidence row type as converted by [RowToStructRow].
+func rowSchema(t reflect.Type) *jsonSchema {
+	plan := planRow(t)
+	schema := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}, DisplayOrder: plan.colDisplayOrder}
+	checks := map[string]string{}
+	for check, name := range plan.colTags {
+		checks[name] = check
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		property := valueSchema(field.Type, map[reflect.Type]bool{})
+		property.Title = plan.colDisplayNames[field.Name]
+		property.Id = field.Name == plan.entityIdFieldName
+		property.Check = checks[field.Name]
+		if order, err := strconv.Atoi(expandFieldTag(field)[orderField]); err == nil {
+			property.Order = order
+		}
+		schema.Properties[field.Name] = property
+	}
+	return schema
+}
+
+// structSchema returns the schema of the rows of a runtime struct, typed by the columns of the [receptor_sdk.StructBuilder] that
+// built it, if any, or else by the values of its first row.
+func structSchema(s *receptor_v1.Struct, columns []receptor_sdk.Column) *jsonSchema {
+	schema := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}, DisplayOrder: s.GetColDisplayOrder()}
+	var first *receptor_v1.Row
+	if len(s.GetRows()) > 0 {
+		first = s.GetRows()[0]
+	}
+	typed := map[string]receptor_sdk.Column{}
+	for _, column := range columns {
+		typed[column.Name] = column
+	}
+	for name, display := range s.GetColDisplayNames() {
+		var property *jsonSchema
+		if column, ok := typed[name]; ok {
+			property = columnSchema(column.Type)
+			property.Id = column.Id
+		} else {
+			property = protoValueSchema(first.GetCols()[name])
+		}
+		property.Title = display
+		for i, ordered := range s.GetColDisplayOrder() {
+			if ordered == name {
+				property.Order = i + 1
+				break
+			}
+		}
+		schema.Properties[name] = property
+	}
+	for check, name := range s.GetColTags() {
+		if property, ok := schema.Properties[name]; ok {
+			property.Check = check
+		}
+	}
+	return schema
+}
+
+// columnSchema returns the schema of the values of a [receptor_sdk.StructBuilder] column of type t.
+func columnSchema(t receptor_sdk.ColumnType) *jsonSchema {
+	switch t {
+	case receptor_sdk.StringColumn:
+		return &jsonSchema{Type: "string"}
+	case receptor_sdk.IntColumn:
+		return &jsonSchema{Type: "integer"}
+	case receptor_sdk.FloatColumn:
+		return &jsonSchema{Type: "number"}
+	case receptor_sdk.BoolColumn:
+		return &jsonSchema{Type: "boolean"}
+	case receptor_sdk.TimestampColumn:
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case receptor_sdk.StringListColumn:
+		return &jsonSchema{Type: "array", Items: &jsonSchema{Type: "string"}}
+	}
+	return &jsonSchema{}
+}
+
+// valueSchema returns the schema of the values a [rowConverter] converts values of type t to.
+func valueSchema(t reflect.Type, seen map[reflect.Type]bool) *jsonSchema {
+	if t != timestampType && t.Kind() == reflect.Pointer {
+		return valueSchema(t.Elem(), seen) // pointers are converted as what they point to
+	}
+	plan := planType(t)
+	switch {
+	case plan.valuer != noMethod:
+		return &jsonSchema{} // any value
+	case t == timeType || t == timestampType:
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case plan.textMarshaler != noMethod || plan.stringer != noMethod:
+		return &jsonSchema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Struct, reflect.Map:
+		return &jsonSchema{Type: "array", Items: objectSchema(t, seen)}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &jsonSchema{Type: "string"}
+		}
+		if structLike(t.Elem()) {
+			elem := t.Elem()
+			for elem.Kind() == reflect.Pointer {
+				elem = elem.Elem()
+			}
+			return &jsonSchema{Type: "array", Items: objectSchema(elem, seen)}
+		}
+		return &jsonSchema{Type: "array", Items: &jsonSchema{Type: "string"}}
+	}
+	return &jsonSchema{} // an interface, or a type that isn't converted
+}
+
+// objectSchema returns the schema of a struct or a map in a struct list.
+func objectSchema(t reflect.Type, seen map[reflect.Type]bool) *jsonSchema {
+	if seen[t] {
+		return &jsonSchema{Type: "object"} // a recursive type
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	if t.Kind() == reflect.Map {
+		return &jsonSchema{Type: "object", AdditionalProperties: valueSchema(t.Elem(), seen)}
+	}
+	schema := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}}
+	for _, field := range planType(t).fields {
+		schema.Properties[field.key] = valueSchema(t.Field(field.index).Type, seen)
+	}
+	return schema
+}
+
+// protoValueSchema returns the schema of a converted value.
+func protoValueSchema(value *receptor_v1.Value) *jsonSchema {
+	switch v := value.GetValueType().(type) {
+	case *receptor_v1.Value_BoolValue:
+		return &jsonSchema{Type: "boolean"}
+	case *receptor_v1.Value_Int32Value, *receptor_v1.Value_Int64Value,
+		*receptor_v1.Value_Uint32Value, *receptor_v1.Value_Uint64Value:
+		return &jsonSchema{Type: "integer"}
+	case *receptor_v1.Value_DoubleValue, *receptor_v1.Value_FloatValue:
+		return &jsonSchema{Type: "number"}
+	case *receptor_v1.Value_StringValue:
+		return &jsonSchema{Type: "string"}
+	case *receptor_v1.Value_TimestampValue:
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case *receptor_v1.Value_StringListValue:
+		return &jsonSchema{Type: "array", Items: &jsonSchema{Type: "string"}}
+	case *receptor_v1.Value_StructListValue:
+		items := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}}
+		if structs := v.StructListValue.GetValues(); len(structs) > 0 {
+			for name, field := range structs[0].GetFields() {
+				items.Properties[name] = protoValueSchema(field)
+			}
+		}
+		return &jsonSchema{Type: "array", Items: items}
+	}
+	return &jsonSchema{}
+}
@@ -0,0 +1,52 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/trustero/api/go/receptor_sdk"
+)
+
+func TestEvidenceSchemasStructBuilder(t *testing.T) {
+	builder, err := receptor_sdk.NewStructBuilder(
+		receptor_sdk.Column{Name: "user", Display: "User Name", Order: 1, Id: true},
+		receptor_sdk.Column{Name: "logins", Order: 2, Type: receptor_sdk.IntColumn},
+		receptor_sdk.Column{Name: "score", Type: receptor_sdk.FloatColumn},
+		receptor_sdk.Column{Name: "admin", Type: receptor_sdk.BoolColumn, Check: "isAdmin"},
+		receptor_sdk.Column{Name: "created", Type: receptor_sdk.TimestampColumn},
+		receptor_sdk.Column{Name: "groups", Type: receptor_sdk.StringListColumn},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// GetEvidenceInfo declares the evidence without rows
+	schemas := evidenceSchemas([]*receptor_sdk.Evidence{builder.Evidence("GitLab", "user", "GitLab Users", "")})
+	if len(schemas) != 1 {
+		t.Fatalf("schemas = %d, want 1", len(schemas))
+	}
+	properties := schemas[0].Schema.Properties
+	for name, want := range map[string]jsonSchema{
+		"user":    {Type: "string", Title: "User Name", Order: 1, Id: true},
+		"logins":  {Type: "integer", Title: "logins", Order: 2},
+		"score":   {Type: "number", Title: "score"},
+		"admin":   {Type: "boolean", Title: "admin", Check: "isAdmin"},
+		"created": {Type: "string", Format: "date-time", Title: "created"},
+		"groups":  {Type: "array", Title: "groups"},
+	} {
+		got := properties[name]
+		if got == nil {
+			t.Errorf("no schema of column %s", name)
+			continue
+		}
+		if got.Type != want.Type || got.Format != want.Format || got.Title != want.Title || got.Order != want.Order ||
+			got.Id != want.Id || got.Check != want.Check {
+			t.Errorf("column %s schema = %+v, want %+v", name, *got, want)
+		}
+	}
+	if items := properties["groups"].Items; items == nil || items.Type != "string" {
+		t.Errorf("string list column items = %+v, want strings", items)
+	}
+}
@@ -0,0 +1,199 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/trustero/api/go/receptor_sdk"
+	"github.com/trustero/api/go/receptor_sdk/multipartkit"
+	"github.com/trustero/api/go/receptor_sdk/state"
+	"github.com/trustero/api/go/receptor_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// '--unchanged-evidence' flag values
+const (
+	unchangedMarker = "marker" // report unchanged evidence as an "unchanged since" marker
+	unchangedSkip   = "skip"   // don't report unchanged evidence
+)
+
+// evidenceState tells which evidence is unchanged since it was last reported, according to the state store in the
+// '--state-dir' directory, and records the evidence reported by the scan.  A nil evidenceState finds no evidence
+// unchanged.
+type evidenceState struct {
+	store   *state.Store
+	save    bool // a dryrun scan, or a scan writing a bundle, reads the state but doesn't update it
+	mu      sync.Mutex
+	seen    map[string]int          // number of evidences of the scan with an evidence key
+	pending map[string]state.Record // records of changed evidence, stored once the evidence is reported
+}
+
+// openEvidenceState opens the state store of the receptor if the '--state-dir' flag is set.  The store is keyed
+// by the receptor's record ID, or by its receptor type if it has none.
+func openEvidenceState() (s *evidenceState, err error) {
+	if len(receptor_sdk.StateDir) == 0 {
+		return
+	}
+	receptorId := receptor_sdk.ReceptorId
+	if len(receptorId) == 0 {
+		receptorId = GetParsedReceptorType()
+	}
+	var store *state.Store
+	if store, err = state.Open(receptor_sdk.StateDir, receptorId); err != nil {
+		return nil, fmt.Errorf("failed to open evidence state: %w", err)
+	}
+	return &evidenceState{
+		store:   store,
+		save:    !receptor_sdk.NoSave && len(receptor_sdk.BundlePath) == 0,
+		seen:    map[string]int{},
+		pending: map[string]state.Record{},
+	}, nil
+}
+
+// validUnchangedEvidence checks the '--unchanged-evidence' flag.
+func validUnchangedEvidence() error {
+	if receptor_sdk.UnchangedEvidence != unchangedMarker && receptor_sdk.UnchangedEvidence != unchangedSkip {
+		return fmt.Errorf("invalid --unchanged-evidence flag %q, expected %s or %s", receptor_sdk.UnchangedEvidence,
+			unchangedMarker, unchangedSkip)
+	}
+	return nil
+}
+
+// key returns the state key of an evidence: its EvidenceKey if set, otherwise its caption, followed by its
+// occurrence if the scan reports more than one evidence with the key.
+func (s *evidenceState) key(evidence *receptor_sdk.Evidence) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := evidenceKey(evidence)
+	s.seen[key]++
+	if n := s.seen[key]; n > 1 {
+		key = fmt.Sprintf("%s#%d", key, n)
+	}
+	return key
+}
+
+// unchanged tells if an evidence is unchanged since it was last reported, and when it was last reported.  The
+// state of a changed evidence is recorded once the evidence is reported, see reported.  reportEvidence is the
+// evidence to report, evidence the receptor's evidence, and paths the hashed files of its streamed documents.
+func (s *evidenceState) unchanged(key string, reportEvidence *receptor_v1.Evidence, evidence *receptor_sdk.Evidence, paths []FilePathsInfo) (since time.Time, ok bool) {
+	if s == nil {
+		return
+	}
+	documents, err := documentHashes(evidence, paths)
+	if err != nil {
+		log.Warn().Err(err).Msgf("failed to hash documents of evidence %s, reporting it in full", evidence.Caption)
+		return
+	}
+	record := state.Record{Hash: evidenceHash(reportEvidence, documents), Documents: documents}
+
+	if last, found := s.store.Get(key); found && last.Hash == record.Hash {
+		return last.ReportedAt, true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[key] = record
+	return
+}
+
+// reported records the state of a changed evidence once it's reported.
+func (s *evidenceState) reported(key string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	record, ok := s.pending[key]
+	delete(s.pending, key)
+	s.mu.Unlock()
+	if ok && s.save {
+		record.ReportedAt = time.Now().UTC()
+		s.store.Put(key, record)
+	}
+}
+
+// close saves the state of the evidence reported by the scan.
+func (s *evidenceState) close() error {
+	if s == nil || !s.save {
+		return nil
+	}
+	if err := s.store.Save(); err != nil {
+		return fmt.Errorf("failed to save evidence state %s: %w", s.store.Path(), err)
+	}
+	return nil
+}
+
+// unchangedEvidence returns an "unchanged since" marker of an evidence, without its content and sources.
+func unchangedEvidence(reportEvidence *receptor_v1.Evidence, since time.Time) *receptor_v1.Evidence {
+	marker := proto.Clone(reportEvidence).(*receptor_v1.Evidence)
+	marker.EvidenceType = nil
+	marker.Sources = nil
+	marker.UnchangedSince = timestamppb.New(since)
+	return marker
+}
+
+// evidenceHash returns the hash of the content of an evidence and its documents' hashes.  The evidence's relevant
+// date and sources aren't hashed since they usually change with each scan, even when the content doesn't.
+func evidenceHash(reportEvidence *receptor_v1.Evidence, documents map[string]string) string {
+	content := proto.Clone(reportEvidence).(*receptor_v1.Evidence)
+	content.RelevantDate = nil
+	content.Sources = nil
+
+	hash := sha256.New()
+	hash.Write([]byte(messageHash(content)))
+	names := make([]string, 0, len(documents))
+	for name := range documents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(hash, "\x00%s\x00%s", name, documents[name])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// documentHashes returns the hashes of an evidence's documents by name, as the documents are named in a streamed
+// report.  The hashes of document files are those computed for their upload, in paths, so the files aren't read
+// again.
+func documentHashes(evidence *receptor_sdk.Evidence, paths []FilePathsInfo) (hashes map[string]string, err error) {
+	if evidence.Document == nil || len(*evidence.Document) == 0 {
+		return
+	}
+	hashes = map[string]string{}
+	for _, doc := range *evidence.Document {
+		name := evidence.Caption
+		if doc.FileName != "" {
+			name = doc.FileName
+		}
+		if len(doc.Body) > 0 {
+			if hashes[name], err = multipartkit.ComputeHash(bytes.NewReader(doc.Body), multipartkit.DefaultBufferSize); err != nil {
+				return
+			}
+			continue
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("document %s has no stream file", name)
+		}
+		path := paths[0]
+		paths = paths[1:]
+		if path.Path == "" {
+			continue
+		}
+		if path.Hash == "" {
+			return nil, fmt.Errorf("document file %s wasn't hashed", path.Path)
+		}
+		hashes[name] = path.Hash
+	}
+	return
+}
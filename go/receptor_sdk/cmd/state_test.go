@@ -0,0 +1,39 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/trustero/api/go/receptor_sdk"
+)
+
+func TestOpenEvidenceStateSave(t *testing.T) {
+	defer func(stateDir, receptorId, bundlePath string, noSave bool) {
+		receptor_sdk.StateDir, receptor_sdk.ReceptorId = stateDir, receptorId
+		receptor_sdk.BundlePath, receptor_sdk.NoSave = bundlePath, noSave
+	}(receptor_sdk.StateDir, receptor_sdk.ReceptorId, receptor_sdk.BundlePath, receptor_sdk.NoSave)
+	receptor_sdk.StateDir = t.TempDir()
+	receptor_sdk.ReceptorId = "receptor"
+
+	for _, test := range []struct {
+		name       string
+		noSave     bool
+		bundlePath string
+		save       bool
+	}{
+		{"scan", false, "", true},
+		{"dryrun", true, "", false},
+		{"bundle", false, "evidence.trb", false}, // the bundle may never be uploaded
+	} {
+		receptor_sdk.NoSave, receptor_sdk.BundlePath = test.noSave, test.bundlePath
+		s, err := openEvidenceState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.save != test.save {
+			t.Errorf("%s: evidence state save = %v, want %v", test.name, s.save, test.save)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/trustero/api/go/receptor_sdk"
+	"github.com/trustero/api/go/receptor_v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// summary collects the run summary of the command being executed.  It's reset by invokeWithContext.
+var summary = newRunSummary("")
+
+// runSummary collects a [receptor_v1.RunSummary] as a command runs.  Its methods are safe for concurrent use since
+// document evidence is uploaded on several workers.
+type runSummary struct {
+	mu        sync.Mutex
+	started   time.Time
+	summary   *receptor_v1.RunSummary
+	services  map[string]*receptor_v1.ServiceSummary
+	evidences map[string]*receptor_v1.EvidenceSummary
+}
+
+func newRunSummary(command string) *runSummary {
+	started := time.Now()
+	return &runSummary{
+		started: started,
+		summary: &receptor_v1.RunSummary{
+			Command:   command,
+			StartedAt: timestamppb.New(started),
+		},
+		services:  map[string]*receptor_v1.ServiceSummary{},
+		evidences: map[string]*receptor_v1.EvidenceSummary{},
+	}
+}
+
+// phase records the start of a phase of the command.  The returned function records the end of the phase and the
+// error the phase failed with, if any.
+func (s *runSummary) phase(name string) (done func(err error)) {
+	started := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	phase := &receptor_v1.PhaseSummary{Name: name}
+	s.summary.Phases = append(s.summary.Phases, phase)
+
+	return func(err error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		phase.Duration = durationpb.New(time.Since(started))
+		if err != nil {
+			phase.Error = err.Error()
+		}
+	}
+}
+
+// discovered records discovered service entities.
+func (s *runSummary) discovered(entities []*receptor_v1.ServiceEntity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entity := range entities {
+		service, ok := s.services[entity.ServiceName]
+		if !ok {
+			service = &receptor_v1.ServiceSummary{ServiceName: entity.ServiceName}
+			s.services[entity.ServiceName] = service
+			s.summary.Services = append(s.summary.Services, service)
+			sort.Slice(s.summary.Services, func(i, j int) bool {
+				return s.summary.Services[i].ServiceName < s.summary.Services[j].ServiceName
+			})
+		}
+		service.Entities++
+	}
+}
+
+// reported records an evidence reported to Trustero, or the error it failed to be reported with.  documentBytes is
+// the size of the evidence's documents.
+func (s *runSummary) reported(evidence *receptor_sdk.Evidence, documentBytes int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	caption := s.evidenceSummary(evidence)
+	if err != nil {
+		caption.Errors = append(caption.Errors, err.Error())
+		return
+	}
+	caption.Evidences++
+	if evidence.Struct != nil {
+		caption.Rows += int32(len(evidence.Struct.GetRows()))
+	} else {
+		caption.Rows += int32(len(evidence.Rows))
+	}
+	if evidence.Document != nil {
+		caption.Documents += int32(len(*evidence.Document))
+		caption.DocumentBytes += documentBytes
+	}
+}
+
+// evidenceSummary returns the summary of the evidence with the caption of evidence.  Callers hold s.mu.
+func (s *runSummary) evidenceSummary(evidence *receptor_sdk.Evidence) *receptor_v1.EvidenceSummary {
+	caption, ok := s.evidences[evidence.Caption]
+	if !ok {
+		caption = &receptor_v1.EvidenceSummary{Caption: evidence.Caption, ServiceName: evidence.ServiceName}
+		s.evidences[evidence.Caption] = caption
+		s.summary.Evidences = append(s.summary.Evidences, caption)
+	}
+	return caption
+}
+
+// unchanged records an evidence found unchanged since it was last reported, or the error its "unchanged since"
+// marker failed to be reported with.
+func (s *runSummary) unchanged(evidence *receptor_sdk.Evidence, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	caption := s.evidenceSummary(evidence)
+	if err != nil {
+		caption.Errors = append(caption.Errors, err.Error())
+		return
+	}
+	caption.Unchanged++
+}
+
+// proto returns a copy of the summary collected so far.
+func (s *runSummary) proto() *receptor_v1.RunSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.summary.Duration = durationpb.New(time.Since(s.started))
+	return proto.Clone(s.summary).(*receptor_v1.RunSummary)
+}
+
+// writeFile writes the summary collected so far as JSON to the --summary-file, if set.
+func (s *runSummary) writeFile() error {
+	if len(receptor_sdk.SummaryFile) == 0 {
+		return nil
+	}
+	data, err := protojson.MarshalOptions{Multiline: true, UseProtoNames: true, EmitUnpopulated: true}.Marshal(s.proto())
+	if err != nil {
+		return fmt.Errorf("failed to encode run summary: %w", err)
+	}
+	if err = os.WriteFile(receptor_sdk.SummaryFile, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write run summary: %w", err)
+	}
+	return nil
+}
+
+// documentsSize returns the size in bytes of an evidence's documents.
+func documentsSize(evidence *receptor_sdk.Evidence) (size int64) {
+	if evidence.Document == nil {
+		return
+	}
+	for _, doc := range *evidence.Document {
+		if len(doc.Body) > 0 {
+			size += int64(len(doc.Body))
+		} else if info, err := os.Stat(doc.StreamFilePath); err == nil {
+			size += info.Size()
+		}
+	}
+	return
+}
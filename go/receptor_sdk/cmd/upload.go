@@ -0,0 +1,165 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/trustero/api/go/receptor_sdk"
+	"github.com/trustero/api/go/receptor_sdk/bundle"
+	"github.com/trustero/api/go/receptor_sdk/client"
+	"github.com/trustero/api/go/receptor_v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	uploadUse   = "upload <trustero_access_token>|dryrun <bundle_file>"
+	uploadShort = "Upload an offline bundle recorded by a scan to Trustero"
+	uploadLong  = `
+Upload an offline bundle recorded by 'scan --bundle' to Trustero.  Upload
+command verifies the size and hash of every entry of the bundle against the
+bundle's manifest before replaying the recorded requests to Trustero in the
+order they were recorded.  If 'dryrun' is specified instead of a Trustero
+access token, the upload command will print the recorded requests to console.
+If '--verify-only' is specified, the bundle is verified and its manifest is
+printed without uploading.`
+)
+
+var verifyOnly bool
+
+type uploader struct {
+	cmd *cobra.Command
+}
+
+func (u *uploader) getCommand() *cobra.Command {
+	return u.cmd
+}
+
+func (u *uploader) setup() {
+	u.cmd = &cobra.Command{
+		Use:          uploadUse,
+		Short:        uploadShort,
+		Long:         uploadLong,
+		Args:         cobra.ExactArgs(2),
+		PreRun:       grpcPreRun,
+		RunE:         upload,
+		PostRun:      grpcPostRun,
+		SilenceUsage: true,
+	}
+	u.cmd.FParseErrWhitelist.UnknownFlags = true
+	addGrpcFlags(u.cmd)
+	addBoolFlag(u.cmd, &verifyOnly, "verify-only", "", false,
+		"Verify the bundle and print its manifest without uploading")
+	addIntFlag(u.cmd, &receptor_sdk.ChunkSize, "chunk-size", "", defaultChunkSize,
+		"Maximum size in bytes of a chunk of a streamed document upload")
+}
+
+// Cobra executes this function on upload command.
+func upload(_ *cobra.Command, args []string) (err error) {
+	var reader *bundle.Reader
+	if reader, err = bundle.Open(args[1]); err != nil {
+		return
+	}
+	defer reader.Close()
+
+	if err = reader.Verify(); err != nil {
+		return fmt.Errorf("bundle %s failed verification: %w", args[1], err)
+	}
+	manifest := reader.Manifest()
+	if verifyOnly {
+		printManifest(args[1], manifest)
+		return
+	}
+	if manifest.ReceptorType != GetParsedReceptorType() {
+		return fmt.Errorf("bundle %s was recorded by receptor %s", args[1], manifest.ReceptorType)
+	}
+
+	// Recorded requests are replayed with the recorded discovery identifier unless another one is given.
+	if len(receptor_sdk.DiscoveryId) == 0 {
+		receptor_sdk.DiscoveryId = manifest.DiscoveryId
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	summary = newRunSummary("upload")
+
+	var rc receptor_v1.ReceptorClient
+	if rc, err = getReceptorClient(args[0]); err != nil {
+		return
+	}
+
+	defer func() {
+		if len(receptor_sdk.Notify) > 0 {
+			notify(ctx, rc, "upload", "successful", "", err)
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("failed to upload bundle")
+		}
+	}()
+
+	for _, entry := range manifest.Entries {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if err = uploadEntry(ctx, rc, reader, entry); err != nil {
+			return fmt.Errorf("failed to upload bundle entry %s: %w", entry.Name, err)
+		}
+	}
+	return
+}
+
+// uploadEntry replays a recorded request to Trustero with the idempotency key it was recorded with, so Trustero
+// deduplicates uploads of the same bundle.  The key is derived again if the request is replayed under another
+// discovery identifier.
+func uploadEntry(ctx context.Context, rc receptor_v1.ReceptorClient, reader *bundle.Reader, entry *bundle.Entry) (err error) {
+	key := entry.IdempotencyKey
+	if len(key) > 0 && receptor_sdk.DiscoveryId != reader.Manifest().DiscoveryId {
+		key = client.IdempotencyKey(key, receptor_sdk.DiscoveryId)
+	}
+	if entry.Kind == bundle.KindStream {
+		return streamEvidence(ctx, rc, entry.ContentType, key, func() (io.ReadCloser, error) {
+			content, err := reader.Open(entry)
+			return io.NopCloser(content), err
+		})
+	}
+
+	var data []byte
+	if data, err = reader.ReadAll(entry); err != nil {
+		return
+	}
+	if len(key) > 0 {
+		ctx = client.WithIdempotencyKey(ctx, key)
+	}
+	switch entry.Kind {
+	case bundle.KindDiscovered:
+		services := &receptor_v1.ServiceEntities{}
+		if err = proto.Unmarshal(data, services); err == nil {
+			_, err = rc.Discovered(ctx, services)
+		}
+	case bundle.KindReport:
+		finding := &receptor_v1.Finding{}
+		if err = proto.Unmarshal(data, finding); err == nil {
+			finding.DiscoveryId = receptor_sdk.DiscoveryId
+			_, err = rc.Report(ctx, finding)
+		}
+	default:
+		err = fmt.Errorf("unknown bundle entry kind %s", entry.Kind)
+	}
+	return
+}
+
+func printManifest(path string, manifest bundle.Manifest) {
+	println("Bundle " + path + " verified")
+	println(fmt.Sprintf("  Version:       %d", manifest.Version))
+	println("  Receptor type: " + manifest.ReceptorType)
+	println("  Discovery ID:  " + manifest.DiscoveryId)
+	println("  Created at:    " + manifest.CreatedAt.String())
+	for _, entry := range manifest.Entries {
+		println(fmt.Sprintf("  %s %d bytes %s", entry.Name, entry.Size, entry.Hash))
+	}
+}
@@ -0,0 +1,381 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package cmd
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/trustero/api/go/receptor_sdk"
+	"github.com/trustero/api/go/receptor_v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	timestampType     = reflect.TypeOf(&timestamppb.Timestamp{})
+	valuerType        = reflect.TypeOf((*receptor_sdk.TrusteroValuer)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// rowConverter converts evidence row fields to [receptor_v1.Value]s.  A field of a type that can't be converted,
+// or whose TrusteroValue or MarshalText method fails, fails the conversion of a strict converter.  Otherwise, it's
+// logged as a warning and left out of the row, or reported as an empty value when nested in another field.  A
+// pointer, map or slice referring back to a value it's nested in also fails a strict converter, and is otherwise
+// reported as an empty value.
+type rowConverter struct {
+	strict   bool
+	visiting map[visit]bool // pointers, maps and slices being converted, to detect cycles
+}
+
+// visit identifies a pointer, map or slice value by its address and type.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+func newRowConverter(strict bool) rowConverter {
+	return rowConverter{strict: strict, visiting: map[visit]bool{}}
+}
+
+// row converts an evidence row to a [receptor_v1.Row] of the given fields.
+func (c rowConverter) row(row interface{}, entityIdFieldName string, rowFieldNames []string) (reportRow *receptor_v1.Row, err error) {
+	rowValue := reflect.Indirect(reflect.ValueOf(row))
+	plan := planRow(rowValue.Type())
+	reportRow = &receptor_v1.Row{
+		EntityInstanceId: plan.entityId(rowValue, entityIdFieldName),
+		Cols:             make(map[string]*receptor_v1.Value, len(rowFieldNames)),
+	}
+
+	for i, fieldName := range rowFieldNames {
+		var value *receptor_v1.Value
+		if value, err = c.value(fieldName, plan.field(rowValue, i, fieldName)); err != nil {
+			return nil, err
+		}
+		if value != nil {
+			reportRow.Cols[fieldName] = value
+		}
+	}
+	return
+}
+
+// value converts the value of a field.  name is the path of the field in the row, such as "Users[2].Name".  A nil
+// value with a nil error means the field was skipped by a lenient converter.
+func (c rowConverter) value(name string, v reflect.Value) (*receptor_v1.Value, error) {
+	if !v.IsValid() {
+		return &receptor_v1.Value{}, nil
+	}
+	if !v.CanInterface() {
+		return c.fail(fmt.Errorf("evidence row field (%s) isn't exported", name))
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return &receptor_v1.Value{}, nil
+		}
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		key := visit{v.Pointer(), v.Type()}
+		if c.visiting[key] {
+			if _, err := c.fail(fmt.Errorf("evidence row field (%s) refers back to a value it's nested in", name)); err != nil {
+				return nil, err
+			}
+			return &receptor_v1.Value{}, nil
+		}
+		c.visiting[key] = true
+		defer delete(c.visiting, key)
+	}
+
+	if v.Type() == timestampType {
+		return &receptor_v1.Value{
+			ValueType: &receptor_v1.Value_TimestampValue{TimestampValue: v.Interface().(*timestamppb.Timestamp)},
+		}, nil
+	}
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		// convert what a pointer points to, so a *time.Time is a timestamp rather than a TextMarshaler.  Methods of
+		// the pointer still apply as the value it points to is addressable.
+		return c.value(name, v.Elem())
+	}
+
+	plan := planType(v.Type())
+	if valuer, ok := implementation(v, plan.valuer); ok {
+		value, err := valuer.Interface().(receptor_sdk.TrusteroValuer).TrusteroValue()
+		if err != nil {
+			return c.fail(fmt.Errorf("evidence row field (%s): %w", name, err))
+		}
+		if value == nil {
+			value = &receptor_v1.Value{}
+		}
+		return value, nil
+	}
+	if v.Type() == timeType {
+		return timestampValue(v.Interface().(time.Time)), nil
+	}
+	if marshaler, ok := implementation(v, plan.textMarshaler); ok {
+		text, err := marshaler.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return c.fail(fmt.Errorf("evidence row field (%s): %w", name, err))
+		}
+		return stringValue(string(text)), nil
+	}
+	if stringer, ok := implementation(v, plan.stringer); ok {
+		return stringValue(stringer.Interface().(fmt.Stringer).String()), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return &receptor_v1.Value{ValueType: &receptor_v1.Value_BoolValue{BoolValue: v.Bool()}}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &receptor_v1.Value{ValueType: &receptor_v1.Value_Int32Value{Int32Value: int32(v.Int())}}, nil
+	case reflect.Int64:
+		return &receptor_v1.Value{ValueType: &receptor_v1.Value_Int64Value{Int64Value: v.Int()}}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &receptor_v1.Value{ValueType: &receptor_v1.Value_Uint32Value{Uint32Value: uint32(v.Uint())}}, nil
+	case reflect.Uint64:
+		return &receptor_v1.Value{ValueType: &receptor_v1.Value_Uint64Value{Uint64Value: v.Uint()}}, nil
+	case reflect.Float32, reflect.Float64:
+		return &receptor_v1.Value{ValueType: &receptor_v1.Value_DoubleValue{DoubleValue: v.Float()}}, nil
+	case reflect.String:
+		return stringValue(v.String()), nil
+	case reflect.Struct:
+		fields, err := c.structFields(name, v, plan)
+		if err != nil {
+			return nil, err
+		}
+		return structListValue(fields), nil
+	case reflect.Map:
+		fields, err := c.mapFields(name, v)
+		if err != nil {
+			return nil, err
+		}
+		return structListValue(fields), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return stringValue(string(v.Bytes())), nil // raw bytes, such as a json.RawMessage
+		}
+		return c.list(name, v)
+	}
+	return c.fail(fmt.Errorf("unsupported evidence row field (%s) type %v", name, v.Type()))
+}
+
+// list converts a slice or an array to a struct list if its elements are structs or maps, and to a string list
+// otherwise.
+func (c rowConverter) list(name string, v reflect.Value) (*receptor_v1.Value, error) {
+	structs := structLike(v.Type().Elem())
+	var (
+		structList receptor_v1.StructList
+		stringList receptor_v1.StringList
+	)
+	for i := 0; i < v.Len(); i++ {
+		elemName := name + "[" + strconv.Itoa(i) + "]"
+		value, err := c.value(elemName, v.Index(i))
+		if err != nil || value == nil {
+			if value, err = c.skipped(err); err != nil {
+				return nil, err
+			}
+		}
+		if structs {
+			if list := value.GetStructListValue(); list != nil {
+				structList.Values = append(structList.Values, list.Values...)
+			} else {
+				structList.Values = append(structList.Values, &receptor_v1.StructStruct{Fields: map[string]*receptor_v1.Value{}})
+			}
+			continue
+		}
+		s, ok := scalarString(value)
+		if !ok {
+			if _, err = c.fail(fmt.Errorf("unsupported evidence row field (%s) type %v, lists can't be nested", elemName, v.Index(i).Type())); err != nil {
+				return nil, err
+			}
+		}
+		stringList.Values = append(stringList.Values, s)
+	}
+
+	if structs {
+		return &receptor_v1.Value{ValueType: &receptor_v1.Value_StructListValue{StructListValue: &structList}}, nil
+	}
+	return &receptor_v1.Value{ValueType: &receptor_v1.Value_StringListValue{StringListValue: &stringList}}, nil
+}
+
+// structFields converts the exported fields of a nested struct, named by their 'trustero' display sub-tag if they
+// have one.
+func (c rowConverter) structFields(name string, v reflect.Value, plan *typePlan) (*receptor_v1.StructStruct, error) {
+	fields := make(map[string]*receptor_v1.Value, len(plan.fields))
+	for _, field := range plan.fields {
+		value, err := c.value(name+"."+field.name, v.Field(field.index))
+		if err != nil || value == nil {
+			if value, err = c.skipped(err); err != nil {
+				return nil, err
+			}
+		}
+		fields[field.key] = value
+	}
+	return &receptor_v1.StructStruct{Fields: fields}, nil
+}
+
+// mapFields converts the entries of a map, named by their keys.
+func (c rowConverter) mapFields(name string, v reflect.Value) (*receptor_v1.StructStruct, error) {
+	fields := map[string]*receptor_v1.Value{}
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := c.value(name+" key", iter.Key())
+		if err != nil || key == nil {
+			if key, err = c.skipped(err); err != nil {
+				return nil, err
+			}
+		}
+		keyString, _ := scalarString(key)
+		elemName := fmt.Sprintf("%s[%q]", name, keyString)
+		value, err := c.value(elemName, iter.Value())
+		if err != nil || value == nil {
+			if value, err = c.skipped(err); err != nil {
+				return nil, err
+			}
+		}
+		fields[keyString] = value
+	}
+	return &receptor_v1.StructStruct{Fields: fields}, nil
+}
+
+// fail returns err if the converter is strict, and otherwise logs it as a warning and skips the field.
+func (c rowConverter) fail(err error) (*receptor_v1.Value, error) {
+	if c.strict {
+		return nil, err
+	}
+	log.Warn().Msg(err.Error())
+	return nil, nil
+}
+
+// skipped returns the empty value standing for a nested field skipped by a lenient converter, or err if the
+// conversion failed.
+func (c rowConverter) skipped(err error) (*receptor_v1.Value, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &receptor_v1.Value{}, nil
+}
+
+// implementation returns v, or the address of a copy of v, as a value implementing an interface the way m tells.
+func implementation(v reflect.Value, m method) (reflect.Value, bool) {
+	switch m {
+	case valueMethod:
+		return v, true
+	case pointerMethod:
+		if !v.CanAddr() {
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			return p, true
+		}
+		return v.Addr(), true
+	}
+	return v, false
+}
+
+// converted returns whether values of type t convert to a value other than a struct list or a string list.
+func converted(t reflect.Type) bool {
+	plan := planType(t)
+	return plan.valuer != noMethod || plan.textMarshaler != noMethod || plan.stringer != noMethod ||
+		t == timeType || t == timestampType
+}
+
+// structLike returns whether values of type t convert to struct lists.
+func structLike(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer && !converted(t) {
+		t = t.Elem()
+	}
+	return !converted(t) && (t.Kind() == reflect.Struct || t.Kind() == reflect.Map)
+}
+
+// supportedValueType returns whether a [rowConverter] converts values of type t.  A TrusteroValuer may still fail
+// at run time.
+func supportedValueType(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if converted(t) || seen[t] {
+		return true
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Interface:
+		return true
+	case reflect.Pointer:
+		return supportedValueType(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if field := t.Field(i); field.IsExported() && !supportedValueType(field.Type, seen) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		return supportedValueType(t.Key(), seen) && !listLike(t.Key()) && supportedValueType(t.Elem(), seen)
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return true
+		}
+		return supportedValueType(t.Elem(), seen) && !listLike(t.Elem())
+	}
+	return false
+}
+
+// listLike returns whether values of type t convert to string lists.
+func listLike(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer && !converted(t) {
+		t = t.Elem()
+	}
+	if converted(t) {
+		return false
+	}
+	return t.Kind() == reflect.Array || (t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8)
+}
+
+// scalarString formats a value that isn't a list as a string.
+func scalarString(value *receptor_v1.Value) (string, bool) {
+	switch v := value.GetValueType().(type) {
+	case nil:
+		return "", true
+	case *receptor_v1.Value_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64), true
+	case *receptor_v1.Value_FloatValue:
+		return strconv.FormatFloat(float64(v.FloatValue), 'g', -1, 32), true
+	case *receptor_v1.Value_Int32Value:
+		return strconv.FormatInt(int64(v.Int32Value), 10), true
+	case *receptor_v1.Value_Int64Value:
+		return strconv.FormatInt(v.Int64Value, 10), true
+	case *receptor_v1.Value_Uint32Value:
+		return strconv.FormatUint(uint64(v.Uint32Value), 10), true
+	case *receptor_v1.Value_Uint64Value:
+		return strconv.FormatUint(v.Uint64Value, 10), true
+	case *receptor_v1.Value_BoolValue:
+		return strconv.FormatBool(v.BoolValue), true
+	case *receptor_v1.Value_StringValue:
+		return v.StringValue, true
+	case *receptor_v1.Value_TimestampValue:
+		return v.TimestampValue.AsTime().Format(time.RFC3339Nano), true
+	}
+	return "", false
+}
+
+func stringValue(s string) *receptor_v1.Value {
+	return &receptor_v1.Value{ValueType: &receptor_v1.Value_StringValue{StringValue: s}}
+}
+
+func timestampValue(t time.Time) *receptor_v1.Value {
+	return &receptor_v1.Value{ValueType: &receptor_v1.Value_TimestampValue{TimestampValue: timestamppb.New(t)}}
+}
+
+func structListValue(fields *receptor_v1.StructStruct) *receptor_v1.Value {
+	return &receptor_v1.Value{
+		ValueType: &receptor_v1.Value_StructListValue{
+			StructListValue: &receptor_v1.StructList{Values: []*receptor_v1.StructStruct{fields}},
+		},
+	}
+}
@@ -45,25 +45,29 @@ func (v *verifi) setup() {
 	v.cmd.FParseErrWhitelist.UnknownFlags = true
 
 	addGrpcFlags(v.cmd)
+	addStrFlag(v.cmd, &receptor_sdk.SummaryFile, "summary-file", "", "", "Path of a JSON file to write the run summary of the command to")
 }
 
 // Cobra executes this function on verify command.
 func verify(_ *cobra.Command, args []string) (err error) {
 	// Run receptor's Verify function and report results to Trustero
-	err = invokeWithContext(args[0],
-		func(rc receptor_v1.ReceptorClient, credentials interface{}, config interface{}) (err error) {
+	err = invokeWithContext("verify", args[0],
+		func(ctx context.Context, rc receptor_v1.ReceptorClient, credentials interface{}, config interface{}) (err error) {
 			// Call receptor's Verify method
-			verifyResult := toVerifyResult(receptorImpl.Verify(credentials, config))
+			done := summary.phase("verify")
+			ok, verifyErr := verifyReceptor(ctx, credentials, config)
+			done(verifyErr)
+			verifyResult := toVerifyResult(ok, verifyErr)
 
 			// Notify behavior is different for the verify command.  When the '--notify' command line
 			// flag is provided on a verify command, verify only notify Trustero of the command
 			// status and does NOT invoke the Verified Trustero RPC method to save the credential
 			// in the receptor record.
 			if len(receptor_sdk.Notify) > 0 {
-				_ = notify(rc, "verify", verifyResult.Message, verifyResult.Exceptions, err)
+				_ = notify(ctx, rc, "verify", verifyResult.Message, verifyResult.Exceptions, err)
 			} else {
 				// Let Trustero know if the service provider account credentials are valid.
-				_, err = rc.Verified(context.Background(), verifyResult)
+				_, err = rc.Verified(ctx, verifyResult)
 			}
 
 			// Send the config back to Trustero if there is additional config
@@ -72,11 +76,13 @@ func verify(_ *cobra.Command, args []string) (err error) {
 				if err != nil {
 					return err
 				}
-				_, err = rc.SetConfiguration(context.Background(), &receptor_v1.ReceptorConfiguration{
+				done := summary.phase("configure")
+				_, err = rc.SetConfiguration(ctx, &receptor_v1.ReceptorConfiguration{
 					ReceptorObjectId: receptor_sdk.ReceptorId,
 					Config:           string(jsonBytes),
 					ModelId:          receptorImpl.GetReceptorType(),
 				})
+				done(err)
 			}
 			return
 		})
@@ -0,0 +1,183 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package receptor_sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCredentialsEnv is the environment variable the "env" credential provider reads credentials from when no
+// variable is named.
+const DefaultCredentialsEnv = "RECEPTOR_CREDENTIALS"
+
+// CredentialProvider provides a receptor's service provider credentials.  Credentials are returned as the JSON
+// object the credential struct returned by [Receptor.GetCredentialObj] is decoded from.  Providers are selected
+// with the '--credentials-from' flag, which keeps credentials off the command line.
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (credentials []byte, err error)
+}
+
+// CredentialProviderFunc is a function implementing [CredentialProvider].
+type CredentialProviderFunc func(ctx context.Context) (credentials []byte, err error)
+
+// Credentials implements [CredentialProvider.Credentials].
+func (f CredentialProviderFunc) Credentials(ctx context.Context) ([]byte, error) {
+	return f(ctx)
+}
+
+// CredentialProviderFactory returns the [CredentialProvider] of a '--credentials-from' source.  arg is the part
+// of the source after the provider's scheme and ':', or empty if the source is just the scheme.
+type CredentialProviderFactory func(arg string) (CredentialProvider, error)
+
+var (
+	credentialProvidersMu sync.RWMutex
+	credentialProviders   = map[string]CredentialProviderFactory{
+		"env":   envCredentialProvider,
+		"file":  fileCredentialProvider,
+		"fd":    fdCredentialProvider,
+		"stdin": stdinCredentialProvider,
+		"exec":  execCredentialProvider,
+	}
+)
+
+// RegisterCredentialProvider registers a credential provider under a scheme, so '--credentials-from scheme:arg'
+// selects it.  The built-in schemes are:
+//   - env[:NAME] reads credentials from environment variable NAME, RECEPTOR_CREDENTIALS by default.
+//   - file:PATH reads credentials from a file.
+//   - fd:N reads credentials from inherited file descriptor N.
+//   - stdin reads credentials from the standard input.
+//   - exec:COMMAND runs COMMAND, split into arguments on spaces, and reads credentials from its standard output.
+//     Like a git credential helper, the command is given the receptor type and Trustero receptor identifier in the
+//     TRUSTERO_RECEPTOR_TYPE and TRUSTERO_RECEPTOR_ID environment variables.
+//
+// Credentials read by the built-in providers are either a JSON object or, like the '--credentials' flag, a base64
+// URL encoded JSON object.  Registering a built-in scheme replaces it.
+func RegisterCredentialProvider(scheme string, factory CredentialProviderFactory) {
+	credentialProvidersMu.Lock()
+	defer credentialProvidersMu.Unlock()
+	credentialProviders[scheme] = factory
+}
+
+// NewCredentialProvider returns the [CredentialProvider] of a '--credentials-from' source of the form scheme or
+// scheme:arg.
+func NewCredentialProvider(source string) (CredentialProvider, error) {
+	scheme, arg, _ := strings.Cut(source, ":")
+	credentialProvidersMu.RLock()
+	factory, ok := credentialProviders[scheme]
+	credentialProvidersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown credential provider %q, expected one of %s", scheme, credentialSchemes())
+	}
+	return factory(arg)
+}
+
+func credentialSchemes() string {
+	credentialProvidersMu.RLock()
+	defer credentialProvidersMu.RUnlock()
+	schemes := make([]string, 0, len(credentialProviders))
+	for scheme := range credentialProviders {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return strings.Join(schemes, ", ")
+}
+
+func envCredentialProvider(name string) (CredentialProvider, error) {
+	if len(name) == 0 {
+		name = DefaultCredentialsEnv
+	}
+	return CredentialProviderFunc(func(context.Context) ([]byte, error) {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s isn't set", name)
+		}
+		return decodeCredentials([]byte(value))
+	}), nil
+}
+
+func fileCredentialProvider(path string) (CredentialProvider, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("file credential provider expects a path, as in file:PATH")
+	}
+	return CredentialProviderFunc(func(context.Context) ([]byte, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return decodeCredentials(data)
+	}), nil
+}
+
+func fdCredentialProvider(arg string) (CredentialProvider, error) {
+	fd, err := strconv.ParseUint(arg, 10, 0)
+	if err != nil {
+		return nil, fmt.Errorf("fd credential provider expects a file descriptor number, as in fd:3")
+	}
+	return CredentialProviderFunc(func(context.Context) ([]byte, error) {
+		file := os.NewFile(uintptr(fd), "fd:"+arg)
+		if file == nil {
+			return nil, fmt.Errorf("invalid file descriptor %s", arg)
+		}
+		defer file.Close()
+		return readCredentials(file)
+	}), nil
+}
+
+func stdinCredentialProvider(string) (CredentialProvider, error) {
+	return CredentialProviderFunc(func(context.Context) ([]byte, error) {
+		return readCredentials(os.Stdin)
+	}), nil
+}
+
+func execCredentialProvider(command string) (CredentialProvider, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("exec credential provider expects a command, as in exec:COMMAND")
+	}
+	return CredentialProviderFunc(func(ctx context.Context) ([]byte, error) {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Env = append(os.Environ(), "TRUSTERO_RECEPTOR_TYPE="+ModelID, "TRUSTERO_RECEPTOR_ID="+ReceptorId)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("credential helper %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return decodeCredentials(out)
+	}), nil
+}
+
+func readCredentials(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeCredentials(data)
+}
+
+// decodeCredentials returns data if it's a JSON object, or decodes it as a base64 URL encoded JSON object.
+func decodeCredentials(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no credentials provided")
+	}
+	if data[0] == '{' {
+		return data, nil
+	}
+	decoded, err := base64.URLEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("credentials are neither a JSON object nor base64 URL encoded: %w", err)
+	}
+	return decoded, nil
+}
@@ -4,25 +4,45 @@ package multipartkit
 
 import (
 	"bytes"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"os"
 	"reflect"
 
+	"google.golang.org/protobuf/encoding/protodelim"
 	"google.golang.org/protobuf/proto"
 )
 
 // DefaultBufferSize defines a default buffer size (5 MB) for writing parts.
 const DefaultBufferSize = 5 * 1024 * 1024 // 5 MB
-const boundary = "trustero_boundary_v1_7f94c1b2c3"
+
+// BoundaryPrefix prefixes the random boundary generated for each builder.
+const BoundaryPrefix = "trustero_boundary_v1_"
+
+// ErrBoundaryCollision is returned when the content of a part contains the multipart boundary.
+var ErrBoundaryCollision = errors.New("part content contains the multipart boundary")
+
+// FramingHeader is the part header describing how the messages of a protobuf part are framed.  A protobuf part
+// without the header holds a single message.
+const FramingHeader = "Content-Framing"
+
+// FramingVarintDelimited frames each message of a protobuf part with its varint encoded length.
+const FramingVarintDelimited = "varint-delimited"
 
 // MultipartBuilder is responsible for building multipart data with writers and buffer size.
 type MultipartBuilder struct {
 	writer     *multipart.Writer
 	bufferSize int
+	encoding   string
+
+	trailingHashes bool
+	manifest       Manifest
 }
 
 // NewMultipartBuilder initializes a new MultipartBuilder using an io.Writer and buffer size.
@@ -31,8 +51,14 @@ func NewMultipartBuilder(w io.Writer, bufferSize int) (*MultipartBuilder, error)
 		bufferSize = DefaultBufferSize
 	}
 	writer := multipart.NewWriter(w)
-	// Set the boundary for the multipart writer
-	writer.SetBoundary(boundary)
+	// Set a random boundary for the multipart writer so document content can't be mistaken for it
+	boundary, err := NewBoundary()
+	if err != nil {
+		return nil, err
+	}
+	if err = writer.SetBoundary(boundary); err != nil {
+		return nil, fmt.Errorf("failed to set boundary: %v", err)
+	}
 	return &MultipartBuilder{
 		writer:     writer,
 		bufferSize: bufferSize,
@@ -44,6 +70,72 @@ func (mb *MultipartBuilder) GetBoundary() string {
 	return mb.writer.Boundary()
 }
 
+// SetBoundary overrides the builder's random boundary, for example to build reproducible multipart content in
+// tests.  SetBoundary must be called before any part is added.
+func (mb *MultipartBuilder) SetBoundary(boundary string) error {
+	return mb.writer.SetBoundary(boundary)
+}
+
+// SetEncoding sets the content encoding, one of the Encoding constants, of the document parts added after the
+// call.  Documents with an already compressed MIME type, see [IsCompressedMIMEType], are never encoded.
+func (mb *MultipartBuilder) SetEncoding(encoding string) error {
+	if err := ValidEncoding(encoding); err != nil {
+		return err
+	}
+	mb.encoding = encoding
+	return nil
+}
+
+// SetTrailingHashes sets whether file parts are hashed while they're written, in a single pass over the file,
+// rather than before.  The hashes are then sent in a manifest part written by [MultipartBuilder.Finalize].
+func (mb *MultipartBuilder) SetTrailingHashes(trailing bool) {
+	mb.trailingHashes = trailing
+}
+
+// NewBoundary returns a boundary made of [BoundaryPrefix] followed by 128 cryptographically random bits.
+func NewBoundary() (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate boundary: %v", err)
+	}
+	return BoundaryPrefix + hex.EncodeToString(random), nil
+}
+
+// guard wraps a part writer to fail with [ErrBoundaryCollision] if the part content contains the boundary.
+func (mb *MultipartBuilder) guard(partWriter io.Writer) io.Writer {
+	return &boundaryGuard{writer: partWriter, delimiter: []byte("--" + mb.writer.Boundary())}
+}
+
+// boundaryGuard scans content written to a part for the boundary delimiter, including delimiters split across
+// writes, before passing it on.
+type boundaryGuard struct {
+	writer    io.Writer
+	delimiter []byte
+	tail      []byte // last len(delimiter)-1 bytes written
+}
+
+func (g *boundaryGuard) Write(p []byte) (n int, err error) {
+	overlap := len(g.delimiter) - 1
+	head := p
+	if len(head) > overlap {
+		head = head[:overlap]
+	}
+	if bytes.Contains(append(g.tail, head...), g.delimiter) || bytes.Contains(p, g.delimiter) {
+		return 0, ErrBoundaryCollision
+	}
+
+	g.tail = append(g.tail, p...)
+	if len(g.tail) > overlap {
+		g.tail = append(g.tail[:0], g.tail[len(g.tail)-overlap:]...)
+	}
+	return g.writer.Write(p)
+}
+
+// addPart adds a part to the builder's manifest.
+func (mb *MultipartBuilder) addPart(partName string, size int64, contentHash string) {
+	mb.manifest.Parts = append(mb.manifest.Parts, ManifestPart{Name: partName, Size: size, Hash: contentHash})
+}
+
 // ComputeHash streams data from the provided `io.Reader` and calculates the SHA-256 hash.
 // The hash is returned as UrlSafe base64 encoded string.
 func ComputeHash(reader io.Reader, bufferSize int) (string, error) {
@@ -59,14 +151,20 @@ func ComputeHash(reader io.Reader, bufferSize int) (string, error) {
 }
 
 // AddProtobuf writes a single or slice of Protobuf messages as a part of the multipart stream,
-// including Content-Size and Content-Hash headers.
+// including Content-Size and Content-Hash headers.  A slice is written with each message prefixed by its varint
+// encoded length, as [protodelim.MarshalTo] does, and the part's Content-Framing header set to
+// [FramingVarintDelimited] so the messages can be read back individually with [Part.NextMessage].
 func (mb *MultipartBuilder) AddProtobuf(partName string, pb interface{}) error {
 	switch v := pb.(type) {
 	case proto.Message:
-		return mb.addSingleOrMultipleProtobuf(partName, []proto.Message{v})
+		marshaledData, err := proto.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to marshal protobuf message: %v", err)
+		}
+		return mb.addProtobufPart(partName, marshaledData, "")
 
 	case []proto.Message:
-		return mb.addSingleOrMultipleProtobuf(partName, v)
+		return mb.addDelimitedProtobuf(partName, v)
 
 	default:
 		// Check for the case where pb is a slice of pointers to protobuf messages.
@@ -82,7 +180,7 @@ func (mb *MultipartBuilder) AddProtobuf(partName string, pb interface{}) error {
 					return fmt.Errorf("unsupported element in slice: expected proto.Message at index %d, got %T", i, elem)
 				}
 			}
-			return mb.addSingleOrMultipleProtobuf(partName, protoMessages)
+			return mb.addDelimitedProtobuf(partName, protoMessages)
 		}
 
 		return fmt.Errorf("unsupported type: expected proto.Message or []proto.Message, got %T", pb)
@@ -90,45 +188,60 @@ func (mb *MultipartBuilder) AddProtobuf(partName string, pb interface{}) error {
 
 }
 
-func (mb *MultipartBuilder) addSingleOrMultipleProtobuf(partName string, pbs []proto.Message) error {
-	var concatenatedData []byte
+func (mb *MultipartBuilder) addDelimitedProtobuf(partName string, pbs []proto.Message) error {
+	var delimitedData bytes.Buffer
 	for _, pb := range pbs {
-		marshaledData, err := proto.Marshal(pb)
-		if err != nil {
+		if _, err := protodelim.MarshalTo(&delimitedData, pb); err != nil {
 			return fmt.Errorf("failed to marshal protobuf message: %v", err)
 		}
-		concatenatedData = append(concatenatedData, marshaledData...)
 	}
+	return mb.addProtobufPart(partName, delimitedData.Bytes(), FramingVarintDelimited)
+}
 
-	reader := bytes.NewReader(concatenatedData)
+func (mb *MultipartBuilder) addProtobufPart(partName string, data []byte, framing string) error {
+	reader := bytes.NewReader(data)
 
 	// Compute the hash before creating the part
-	contentHash, err := ComputeHash(bytes.NewReader(concatenatedData), mb.bufferSize)
+	contentHash, err := ComputeHash(bytes.NewReader(data), mb.bufferSize)
 	if err != nil {
 		return fmt.Errorf("failed to compute hash for protobuf part: %v", err)
 	}
+	mb.addPart(partName, int64(len(data)), contentHash)
 
 	// Create the part with all headers, including Content-Hash
-	partWriter, err := mb.writer.CreatePart(map[string][]string{
+	headers := map[string][]string{
 		"Content-Disposition": {fmt.Sprintf(`protobuf; name="%s"`, partName)},
 		"Content-Type":        {"application/protobuf"},
-		"Content-Size":        {fmt.Sprintf("%d", len(concatenatedData))},
+		"Content-Size":        {fmt.Sprintf("%d", len(data))},
 		"Content-Hash":        {contentHash},
-	})
+	}
+	if framing != "" {
+		headers[FramingHeader] = []string{framing}
+	}
+	partWriter, err := mb.writer.CreatePart(headers)
 	if err != nil {
 		return fmt.Errorf("failed to create multipart part: %v", err)
 	}
 
-	_, err = io.Copy(partWriter, reader)
+	_, err = io.Copy(mb.guard(partWriter), reader)
 	if err != nil {
-		return fmt.Errorf("failed to write protobuf data: %v", err)
+		return fmt.Errorf("failed to write protobuf data: %w", err)
 	}
 
 	return nil
 }
 
 // AddFile writes the content of a file as a part of the multipart stream with Content-Size and Content-Hash headers.
+// With trailing hashes, see [MultipartBuilder.SetTrailingHashes], the file is read once and its Content-Hash is
+// sent in the manifest part instead.
 func (mb *MultipartBuilder) AddFile(partName, fileName, filePath, contentType string, contentMeta map[string]string) error {
+	return mb.AddFileWithHash(partName, fileName, filePath, contentType, "", contentMeta)
+}
+
+// AddFileWithHash writes the content of a file like [MultipartBuilder.AddFile], with the file's Content-Hash, as
+// returned by [ComputeHash], already known so the file isn't read to compute it.  An empty contentHash is computed
+// as AddFile does.
+func (mb *MultipartBuilder) AddFileWithHash(partName, fileName, filePath, contentType, contentHash string, contentMeta map[string]string) error {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %v", err)
@@ -140,27 +253,29 @@ func (mb *MultipartBuilder) AddFile(partName, fileName, filePath, contentType st
 		return fmt.Errorf("failed to get file info: %v", err)
 	}
 
-	// Compute the file hash before creating the part
-	_, err = file.Seek(0, io.SeekStart) // Ensure we are at the start of the file
-	if err != nil {
-		return fmt.Errorf("failed to seek file: %v", err)
-	}
-	contentHash, err := ComputeHash(file, mb.bufferSize)
-	if err != nil {
-		return fmt.Errorf("failed to compute hash for file: %v", err)
-	}
-
-	_, err = file.Seek(0, io.SeekStart) // Rewind the file to the beginning before writing
-	if err != nil {
-		return fmt.Errorf("failed to seek file: %v", err)
-	}
-
 	// common headers
 	headers := map[string][]string{
 		"Content-Disposition": {fmt.Sprintf(`file; name="%s"; filename="%s"`, partName, fileName)},
 		"Content-Type":        {contentType},
 		"Content-Size":        {fmt.Sprintf("%d", fileInfo.Size())},
-		"Content-Hash":        {contentHash},
+	}
+
+	if mb.trailingHashes {
+		headers[TrailerHeader] = []string{"Content-Hash"}
+	} else {
+		if contentHash == "" {
+			// Compute the file hash before creating the part
+			contentHash, err = ComputeHash(file, mb.bufferSize)
+			if err != nil {
+				return fmt.Errorf("failed to compute hash for file: %v", err)
+			}
+
+			_, err = file.Seek(0, io.SeekStart) // Rewind the file to the beginning before writing
+			if err != nil {
+				return fmt.Errorf("failed to seek file: %v", err)
+			}
+		}
+		headers["Content-Hash"] = []string{contentHash}
 	}
 
 	// Inject key-value pairs from contentMeta into headers
@@ -168,15 +283,17 @@ func (mb *MultipartBuilder) AddFile(partName, fileName, filePath, contentType st
 		headers[key] = []string{value}
 	}
 
-	partWriter, err := mb.writer.CreatePart(headers)
+	// Hash the file while it's written
+	hash := sha256.New()
+	counter := &countingWriter{}
+	err = mb.writeDocument(headers, contentType, io.TeeReader(file, io.MultiWriter(hash, counter)))
 	if err != nil {
-		return fmt.Errorf("failed to create multipart part: %v", err)
+		return fmt.Errorf("failed to write file part: %w", err)
 	}
-
-	_, err = io.CopyBuffer(partWriter, file, make([]byte, mb.bufferSize))
-	if err != nil {
-		return fmt.Errorf("failed to write file part: %v", err)
+	if mb.trailingHashes {
+		contentHash = base64.URLEncoding.EncodeToString(hash.Sum(nil))
 	}
+	mb.addPart(partName, counter.n, contentHash)
 
 	return nil
 }
@@ -190,6 +307,7 @@ func (mb *MultipartBuilder) AddBytes(partName, fileName, contentType string, dat
 	if err != nil {
 		return fmt.Errorf("failed to compute hash for data: %v", err)
 	}
+	mb.addPart(partName, int64(len(data)), contentHash)
 
 	// Rewind the reader after calculating the hash
 	reader.Seek(0, io.SeekStart)
@@ -206,20 +324,63 @@ func (mb *MultipartBuilder) AddBytes(partName, fileName, contentType string, dat
 	}
 
 	// Create the part with all headers, including Content-Hash
+	err = mb.writeDocument(headers, contentType, reader)
+	if err != nil {
+		return fmt.Errorf("failed to write data part: %w", err)
+	}
+
+	return nil
+}
+
+// writeDocument creates a document part and copies src into it, compressed with the builder's encoding unless the
+// document is already compressed or its metadata already sets a Content-Encoding.
+func (mb *MultipartBuilder) writeDocument(headers map[string][]string, contentType string, src io.Reader) error {
+	encoding := mb.encoding
+	if _, ok := headers["Content-Encoding"]; ok || IsCompressedMIMEType(contentType) {
+		encoding = EncodingIdentity
+	}
+	if encoding != EncodingIdentity {
+		headers["Content-Encoding"] = []string{encoding}
+	}
+
 	partWriter, err := mb.writer.CreatePart(headers)
 	if err != nil {
 		return fmt.Errorf("failed to create multipart part: %v", err)
 	}
 
-	_, err = io.Copy(partWriter, reader)
-	if err != nil {
-		return fmt.Errorf("failed to write data part: %v", err)
+	dst := mb.guard(partWriter)
+	if encoding == EncodingIdentity {
+		_, err = io.CopyBuffer(dst, src, make([]byte, mb.bufferSize))
+		return err
 	}
 
-	return nil
+	encoder, err := newEncoder(dst, encoding)
+	if err != nil {
+		return err
+	}
+	if _, err = io.CopyBuffer(encoder, src, make([]byte, mb.bufferSize)); err != nil {
+		encoder.Close()
+		return err
+	}
+	return encoder.Close()
 }
 
-// Finalize writes the ending boundary and closes the multipart writer.
+// Finalize writes the manifest part, if the builder has trailing hashes, and the ending boundary and closes the
+// multipart writer.
 func (mb *MultipartBuilder) Finalize() error {
+	if mb.trailingHashes {
+		if err := mb.writeManifest(); err != nil {
+			return err
+		}
+	}
 	return mb.writer.Close()
 }
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,95 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+package multipartkit
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content encodings of document parts.  The Content-Size, Content-Length and Content-Hash headers of an encoded
+// part describe its uncompressed content so they stay comparable whatever the encoding.
+const (
+	EncodingIdentity = ""     // Document content is sent as is.
+	EncodingGzip     = "gzip" // Document content is gzip compressed.
+	EncodingZstd     = "zstd" // Document content is zstd compressed.
+)
+
+// compressedMIMETypes are MIME types whose content is already compressed and gains nothing from an encoding.
+var compressedMIMETypes = map[string]bool{
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/zstd":             true,
+	"application/zip":              true,
+	"application/x-7z-compressed":  true,
+	"application/x-bzip2":          true,
+	"application/x-rar-compressed": true,
+	"application/x-xz":             true,
+	"image/jpeg":                   true,
+	"image/png":                    true,
+	"image/gif":                    true,
+	"image/webp":                   true,
+	"image/avif":                   true,
+	"image/heic":                   true,
+	"audio/mpeg":                   true,
+	"audio/aac":                    true,
+	"audio/mp4":                    true,
+	"audio/ogg":                    true,
+	"audio/opus":                   true,
+	"video/mp4":                    true,
+	"video/mpeg":                   true,
+	"video/webm":                   true,
+	"video/quicktime":              true,
+	"video/x-matroska":             true,
+}
+
+// IsCompressedMIMEType returns true if content of the MIME type is already compressed, such as archives, JPEG and
+// PNG images, most audio and video formats and Office Open XML documents.
+func IsCompressedMIMEType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return compressedMIMETypes[mediaType] ||
+		strings.HasPrefix(mediaType, "application/vnd.openxmlformats-officedocument.")
+}
+
+// ValidEncoding returns an error if encoding isn't one of the supported encodings.
+func ValidEncoding(encoding string) error {
+	switch encoding {
+	case EncodingIdentity, EncodingGzip, EncodingZstd:
+		return nil
+	}
+	return fmt.Errorf("unsupported content encoding %q, expected %q or %q", encoding, EncodingGzip, EncodingZstd)
+}
+
+// newEncoder returns a writer compressing to w with the given encoding.
+func newEncoder(w io.Writer, encoding string) (io.WriteCloser, error) {
+	switch encoding {
+	case EncodingGzip:
+		return gzip.NewWriter(w), nil
+	case EncodingZstd:
+		return zstd.NewWriter(w)
+	}
+	return nil, ValidEncoding(encoding)
+}
+
+// newDecoder returns a reader decompressing r with the given encoding.
+func newDecoder(r io.Reader, encoding string) (io.ReadCloser, error) {
+	switch encoding {
+	case EncodingGzip:
+		return gzip.NewReader(r)
+	case EncodingZstd:
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return nil, ValidEncoding(encoding)
+}
@@ -0,0 +1,74 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+package multipartkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ManifestPartName is the name of the manifest part written last by a builder with trailing hashes.
+const ManifestPartName = "multipartkit.Manifest"
+
+// TrailerHeader lists the headers of a part that are sent in the manifest part instead of the part's headers.  A
+// builder with trailing hashes sets it to "Content-Hash" on file parts, which are hashed while they're written.
+const TrailerHeader = "Trailer"
+
+// Manifest lists the size and hash of every part of a multipart stream.  The manifest lets a builder hash a file
+// in the same pass that writes it.  [MultipartReader.Next] verifies the parts read to their end against the
+// manifest when it reaches the manifest part.
+type Manifest struct {
+	Parts []ManifestPart `json:"parts"`
+}
+
+// ManifestPart describes a part of a multipart stream.
+type ManifestPart struct {
+	Name string `json:"name"` // Name of the part.
+	Size int64  `json:"size"` // Size of the part's uncompressed content in bytes.
+	Hash string `json:"hash"` // Hash is the UrlSafe base64 encoded SHA-256 of the part's uncompressed content.
+}
+
+// writeManifest writes the builder's manifest as the last part of the multipart stream.
+func (mb *MultipartBuilder) writeManifest() error {
+	data, err := json.Marshal(&mb.manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %v", err)
+	}
+	partWriter, err := mb.writer.CreatePart(map[string][]string{
+		"Content-Disposition": {fmt.Sprintf(`manifest; name="%s"`, ManifestPartName)},
+		"Content-Type":        {"application/json"},
+		"Content-Size":        {fmt.Sprintf("%d", len(data))},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create manifest part: %v", err)
+	}
+	if _, err = mb.guard(partWriter).Write(data); err != nil {
+		return fmt.Errorf("failed to write manifest part: %w", err)
+	}
+	return nil
+}
+
+// verifyManifest verifies the parts read to their end against the manifest part.
+func (mr *MultipartReader) verifyManifest(r io.Reader) error {
+	var manifest Manifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return fmt.Errorf("failed to decode manifest: %v", err)
+	}
+	if len(manifest.Parts) != mr.count {
+		return fmt.Errorf("manifest lists %d parts, multipart holds %d", len(manifest.Parts), mr.count)
+	}
+	for i, part := range manifest.Parts {
+		if i >= len(mr.computed) || mr.computed[i] == nil {
+			continue // part wasn't read to its end
+		}
+		computed := mr.computed[i]
+		if computed.Size != part.Size {
+			return fmt.Errorf("%w: part %s is %d bytes, manifest lists %d", ErrSizeMismatch, part.Name, computed.Size, part.Size)
+		}
+		if computed.Hash != part.Hash {
+			return fmt.Errorf("%w: part %s hash is %s, manifest lists %s", ErrHashMismatch, part.Name, computed.Hash, part.Hash)
+		}
+	}
+	return nil
+}
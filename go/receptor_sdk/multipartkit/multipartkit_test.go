@@ -0,0 +1,316 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package multipartkit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const testBoundary = BoundaryPrefix + "test"
+
+var testDocument = []byte(strings.Repeat("all users must use MFA\n", 100))
+
+// buildTestMultipart builds a multipart with a protobuf part, a part of length-delimited protobuf messages, a
+// document file, a document added as bytes and an already compressed document.
+func buildTestMultipart(t *testing.T, encoding string, trailing bool) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.txt")
+	if err := os.WriteFile(path, testDocument, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	mb, err := NewMultipartBuilder(&buf, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mb.SetBoundary(testBoundary); err != nil {
+		t.Fatal(err)
+	}
+	if err = mb.SetEncoding(encoding); err != nil {
+		t.Fatal(err)
+	}
+	mb.SetTrailingHashes(trailing)
+
+	if err = mb.AddProtobuf("finding", wrapperspb.String("finding")); err != nil {
+		t.Fatal(err)
+	}
+	if err = mb.AddProtobuf("sources", []*wrapperspb.StringValue{wrapperspb.String("a"), wrapperspb.String("b")}); err != nil {
+		t.Fatal(err)
+	}
+	if err = mb.AddFile("policy.txt", "policy.txt", path, "text/plain", map[string]string{"Owner": "security"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = mb.AddBytes("notes.txt", "notes.txt", "text/plain", testDocument, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = mb.AddBytes("logo.png", "logo.png", "image/png", testDocument, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = mb.Finalize(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newTestReader(t *testing.T, data []byte) *MultipartReader {
+	t.Helper()
+	mr, err := NewMultipartReader(bytes.NewReader(data), testBoundary, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mr
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, encoding := range []string{EncodingIdentity, EncodingGzip, EncodingZstd} {
+		for _, trailing := range []bool{false, true} {
+			data := buildTestMultipart(t, encoding, trailing)
+			mr := newTestReader(t, data)
+
+			part, err := mr.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			finding := &wrapperspb.StringValue{}
+			if err = part.Decode(finding); err != nil || finding.GetValue() != "finding" {
+				t.Errorf("encoding %q, trailing %v: finding part = %v, %v", encoding, trailing, finding, err)
+			}
+
+			if part, err = mr.Next(); err != nil {
+				t.Fatal(err)
+			}
+			if !part.Delimited {
+				t.Errorf("encoding %q, trailing %v: sources part isn't delimited", encoding, trailing)
+			}
+			var sources []string
+			for {
+				source := &wrapperspb.StringValue{}
+				if err = part.NextMessage(source); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				sources = append(sources, source.GetValue())
+			}
+			if strings.Join(sources, ",") != "a,b" {
+				t.Errorf("encoding %q, trailing %v: sources = %q, want a and b", encoding, trailing, sources)
+			}
+
+			for _, want := range []struct {
+				name     string
+				encoding string
+				trailing bool
+			}{
+				{"policy.txt", encoding, trailing},
+				{"notes.txt", encoding, false},
+				{"logo.png", EncodingIdentity, false},
+			} {
+				if part, err = mr.Next(); err != nil {
+					t.Fatal(err)
+				}
+				content, readErr := io.ReadAll(part)
+				if readErr != nil {
+					t.Fatalf("encoding %q, trailing %v: reading %s: %v", encoding, trailing, want.name, readErr)
+				}
+				if part.Kind != PartFile || part.Name != want.name || !bytes.Equal(content, testDocument) {
+					t.Errorf("encoding %q, trailing %v: part %s = %s of %d bytes, want %s", encoding, trailing,
+						part.Name, part.FileName, len(content), want.name)
+				}
+				if part.Encoding != want.encoding || part.Trailing != want.trailing {
+					t.Errorf("encoding %q, trailing %v: part %s encoding %q, trailing %v, want %q, %v", encoding,
+						trailing, part.Name, part.Encoding, part.Trailing, want.encoding, want.trailing)
+				}
+				if part.Size != int64(len(testDocument)) {
+					t.Errorf("encoding %q, trailing %v: part %s size %d, want the uncompressed size %d", encoding,
+						trailing, part.Name, part.Size, len(testDocument))
+				}
+				if want.name == "policy.txt" && part.Metadata["Owner"] != "security" {
+					t.Errorf("encoding %q, trailing %v: part metadata = %v, want Owner", encoding, trailing, part.Metadata)
+				}
+			}
+
+			if _, err = mr.Next(); err != io.EOF {
+				t.Errorf("encoding %q, trailing %v: Next after the last part = %v, want io.EOF", encoding, trailing, err)
+			}
+		}
+	}
+}
+
+func TestComputeHash(t *testing.T) {
+	hash, err := ComputeHash(bytes.NewReader(testDocument), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buildTestMultipart(t, EncodingIdentity, false)
+	mr := newTestReader(t, data)
+	for {
+		part, err := mr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part.Name == "policy.txt" {
+			if part.Hash != hash {
+				t.Errorf("Content-Hash = %s, want %s", part.Hash, hash)
+			}
+			return
+		}
+	}
+}
+
+func TestVerification(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		trailing bool
+		tamper   func([]byte) []byte
+		want     error
+	}{
+		{"content", false, func(data []byte) []byte {
+			return bytes.Replace(data, []byte("MFA"), []byte("SMS"), 1)
+		}, ErrHashMismatch},
+		{"size", false, func(data []byte) []byte {
+			return bytes.Replace(data, []byte("MFA\n"), []byte("MFA"), 1)
+		}, ErrSizeMismatch},
+		{"trailing content", true, func(data []byte) []byte {
+			return bytes.Replace(data, []byte("MFA"), []byte("SMS"), 1)
+		}, ErrHashMismatch},
+	} {
+		data := test.tamper(buildTestMultipart(t, EncodingIdentity, test.trailing))
+		mr := newTestReader(t, data)
+		var err error
+		for err == nil {
+			var part *Part
+			if part, err = mr.Next(); err == nil {
+				_, err = io.Copy(io.Discard, part)
+			}
+		}
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: error = %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestProtobufTypes(t *testing.T) {
+	var buf bytes.Buffer
+	mb, err := NewMultipartBuilder(&buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mb.AddProtobuf("messages", []proto.Message{wrapperspb.String("a")}); err != nil {
+		t.Error(err)
+	}
+	if err = mb.AddProtobuf("invalid", "not a message"); err == nil {
+		t.Error("AddProtobuf of a string succeeded, want an error")
+	}
+}
+
+func TestBoundaryCollision(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		bufferSize int
+	}{{"single write", 0}, {"split across writes", 3}} {
+		var buf bytes.Buffer
+		mb, err := NewMultipartBuilder(&buf, test.bufferSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mb.SetBoundary("collide"); err != nil {
+			t.Fatal(err)
+		}
+		err = mb.AddBytes("doc", "doc.txt", "text/plain", []byte("before\r\n--collide\r\nafter"), nil)
+		if !errors.Is(err, ErrBoundaryCollision) {
+			t.Errorf("%s: AddBytes = %v, want ErrBoundaryCollision", test.name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	mb, err := NewMultipartBuilder(&buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(mb.GetBoundary(), BoundaryPrefix) || len(mb.GetBoundary()) != len(BoundaryPrefix)+32 {
+		t.Errorf("boundary %s isn't %s followed by 128 random bits", mb.GetBoundary(), BoundaryPrefix)
+	}
+}
+
+func TestIsCompressedMIMEType(t *testing.T) {
+	for contentType, want := range map[string]bool{
+		"image/png":        true,
+		"application/zip":  true,
+		"text/plain":       false,
+		"application/pdf":  false,
+		"application/json": false,
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document": true,
+	} {
+		if got := IsCompressedMIMEType(contentType); got != want {
+			t.Errorf("IsCompressedMIMEType(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
+
+func TestAddFileWithHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.txt")
+	if err := os.WriteFile(path, testDocument, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	mb, err := NewMultipartBuilder(&buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the given hash is sent as is, without hashing the file again
+	if err = mb.AddFileWithHash("policy.txt", "policy.txt", path, "text/plain", "precomputed", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = mb.Finalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	mr, err := NewMultipartReader(&buf, mb.GetBoundary(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := mr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if part.Hash != "precomputed" {
+		t.Errorf("Content-Hash = %s, want precomputed", part.Hash)
+	}
+	if _, err = io.ReadAll(part); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("reading a part with the wrong hash = %v, want ErrHashMismatch", err)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	data := buildTestMultipart(t, EncodingGzip, true)
+	if !bytes.Contains(data, []byte(ManifestPartName)) {
+		t.Fatal("multipart with trailing hashes has no manifest part")
+	}
+	if plain := buildTestMultipart(t, EncodingGzip, false); bytes.Contains(plain, []byte(ManifestPartName)) {
+		t.Error("multipart without trailing hashes has a manifest part")
+	}
+
+	// a corrupt manifest fails verification
+	mr := newTestReader(t, bytes.Replace(data, []byte(`{"name":"logo.png"`), []byte(`{"name":"logo.png","x":[`), 1))
+	var err error
+	for err == nil {
+		var part *Part
+		if part, err = mr.Next(); err == nil {
+			_, err = io.Copy(io.Discard, part)
+		}
+	}
+	if err == nil || err == io.EOF {
+		t.Errorf("reading a multipart with a corrupt manifest = %v, want an error", err)
+	}
+}
@@ -0,0 +1,215 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+package multipartkit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// ErrHashMismatch is returned at the end of a part whose content doesn't match its Content-Hash header.
+	ErrHashMismatch = errors.New("multipart part content hash mismatch")
+	// ErrSizeMismatch is returned at the end of a part whose content doesn't match its Content-Size or
+	// Content-Length header.
+	ErrSizeMismatch = errors.New("multipart part content size mismatch")
+)
+
+// PartKind is the kind of content a part holds.
+type PartKind int
+
+const (
+	PartFile     PartKind = iota // A document added with AddFile or AddBytes.
+	PartProtobuf                 // Protobuf messages added with AddProtobuf.
+)
+
+// Headers written by the builder.  All other part headers are document metadata.
+var standardHeaders = map[string]bool{
+	"Content-Disposition": true,
+	"Content-Type":        true,
+	"Content-Size":        true,
+	"Content-Length":      true,
+	"Content-Hash":        true,
+	"Content-Encoding":    true,
+	TrailerHeader:         true,
+	FramingHeader:         true,
+}
+
+// Part is a typed part of a multipart stream built by [MultipartBuilder].  Reading a Part streams its content,
+// decompressed if the part has a Content-Encoding, through a verifier.  At the end of the content, Read returns [ErrHashMismatch] or [ErrSizeMismatch] instead of
+// io.EOF if the content doesn't match the part's Content-Hash or Content-Size headers.
+type Part struct {
+	Kind        PartKind             // Kind of the part, from its Content-Disposition.
+	Name        string               // Name of the part.
+	FileName    string               // FileName of a document part.
+	ContentType string               // ContentType is the MIME type of the part.
+	Size        int64                // Size of the content from the part headers, -1 if not provided.
+	Hash        string               // Hash of the content from the Content-Hash header, empty if not provided.
+	Encoding    string               // Encoding the content was sent with, one of the Encoding constants.
+	Trailing    bool                 // Trailing is true if the part's hash is sent in the manifest part.
+	Delimited   bool                 // Delimited is true if a protobuf part holds varint length-delimited messages.
+	Metadata    map[string]string    // Metadata holds the document metadata headers.
+	Header      textproto.MIMEHeader // Header holds all part headers.
+
+	reader   *MultipartReader
+	index    int
+	body     io.Reader
+	decoder  io.ReadCloser
+	hash     hash.Hash
+	read     int64
+	verified bool
+	messages *bufio.Reader
+	decoded  bool
+}
+
+// Next returns the next typed part of the multipart stream, or io.EOF when there are no more parts.  The content
+// of the previous part is skipped, without verification, if it wasn't read to its end.  The manifest part, see
+// [Manifest], isn't returned.  Instead, Next verifies the parts read so far against it and returns
+// [ErrHashMismatch] or [ErrSizeMismatch] if they don't match.
+func (mr *MultipartReader) Next() (p *Part, err error) {
+	var part *multipart.Part
+	if part, err = mr.reader.NextPart(); err != nil {
+		return
+	}
+
+	disposition, params, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+	if disposition == "manifest" && params["name"] == ManifestPartName {
+		if err = mr.verifyManifest(part); err != nil {
+			return
+		}
+		return mr.Next()
+	}
+
+	p = &Part{
+		reader:      mr,
+		index:       mr.count,
+		Kind:        PartFile,
+		Name:        params["name"],
+		FileName:    params["filename"],
+		ContentType: part.Header.Get("Content-Type"),
+		Size:        -1,
+		Hash:        part.Header.Get("Content-Hash"),
+		Metadata:    map[string]string{},
+		Encoding:    part.Header.Get("Content-Encoding"),
+		Trailing:    strings.Contains(part.Header.Get(TrailerHeader), "Content-Hash"),
+		Header:      part.Header,
+		body:        part,
+		hash:        sha256.New(),
+	}
+	if p.Encoding != EncodingIdentity {
+		if p.decoder, err = newDecoder(part, p.Encoding); err != nil {
+			return nil, fmt.Errorf("failed to decode part %s: %w", p.Name, err)
+		}
+		p.body = p.decoder
+	}
+	mr.count++
+	mr.computed = append(mr.computed, nil)
+	if disposition == "protobuf" {
+		p.Kind = PartProtobuf
+		p.Delimited = part.Header.Get(FramingHeader) == FramingVarintDelimited
+	}
+
+	size := part.Header.Get("Content-Size")
+	if size == "" {
+		size = part.Header.Get("Content-Length")
+	}
+	if size != "" {
+		if p.Size, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid size %q of part %s: %v", size, p.Name, err)
+		}
+	}
+
+	for key := range part.Header {
+		if !standardHeaders[key] {
+			p.Metadata[key] = part.Header.Get(key)
+		}
+	}
+	return
+}
+
+// Read reads the content of the part and verifies it once the end of the content is reached.
+func (p *Part) Read(b []byte) (n int, err error) {
+	n, err = p.body.Read(b)
+	p.hash.Write(b[:n])
+	p.read += int64(n)
+	if err == io.EOF && !p.verified {
+		p.verified = true
+		if p.decoder != nil {
+			p.decoder.Close()
+		}
+		p.reader.computed[p.index] = &ManifestPart{Name: p.Name, Size: p.read, Hash: p.ComputedHash()}
+		if verifyErr := p.verify(); verifyErr != nil {
+			err = verifyErr
+		}
+	}
+	return
+}
+
+// Decode reads and verifies the content of a protobuf part holding a single message and unmarshals it into m.
+// Use [Part.NextMessage] to read a part holding length-delimited messages.
+func (p *Part) Decode(m proto.Message) (err error) {
+	if p.Kind != PartProtobuf {
+		return fmt.Errorf("part %s is not a protobuf part", p.Name)
+	}
+	if p.Delimited {
+		return fmt.Errorf("part %s holds length-delimited messages", p.Name)
+	}
+	var data []byte
+	if data, err = io.ReadAll(p); err != nil {
+		return
+	}
+	if err = proto.Unmarshal(data, m); err != nil {
+		return fmt.Errorf("failed to decode part %s: %v", p.Name, err)
+	}
+	return
+}
+
+// NextMessage unmarshals the next message of a protobuf part into m and returns io.EOF after the last message.
+// A part holding length-delimited messages, added to the builder as a slice, yields each message in turn.  A part
+// holding a single message yields it once.  Verification errors are returned once the content is read to its
+// end.
+func (p *Part) NextMessage(m proto.Message) (err error) {
+	if !p.Delimited {
+		if p.decoded {
+			return io.EOF
+		}
+		p.decoded = true
+		return p.Decode(m)
+	}
+
+	if p.messages == nil {
+		p.messages = bufio.NewReader(p)
+	}
+	if err = (protodelim.UnmarshalOptions{MaxSize: -1}).UnmarshalFrom(p.messages, m); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to decode message of part %s: %w", p.Name, err)
+	}
+	return
+}
+
+// ComputedHash returns the SHA-256 hash, as a UrlSafe base64 encoded string, of the content read so far.
+func (p *Part) ComputedHash() string {
+	return base64.URLEncoding.EncodeToString(p.hash.Sum(nil))
+}
+
+func (p *Part) verify() error {
+	if p.Size >= 0 && p.read != p.Size {
+		return fmt.Errorf("%w: part %s is %d bytes, expected %d", ErrSizeMismatch, p.Name, p.read, p.Size)
+	}
+	if computed := p.ComputedHash(); p.Hash != "" && computed != p.Hash {
+		return fmt.Errorf("%w: part %s hash is %s, expected %s", ErrHashMismatch, p.Name, computed, p.Hash)
+	}
+	return nil
+}
@@ -14,7 +14,9 @@ import (
 
 // MultipartReader reads multipart data from a stream and supports configurable buffer size.
 type MultipartReader struct {
-	reader *multipart.Reader
+	reader   *multipart.Reader
+	count    int             // number of parts returned by Next
+	computed []*ManifestPart // size and hash of the parts Next returned that were read to their end
 }
 
 // PartMetadata describes metadata for each part of the multipart message.
@@ -37,6 +39,21 @@ func NewMultipartReader(r io.Reader, boundary string, bufferSize int) (*Multipar
 	return mr, nil
 }
 
+// ParseBoundary extracts the boundary parameter from a multipart content type such as
+// "multipart/tr-mixed; text/csv; boundary=abc".  Unlike [mime.ParseMediaType], ParseBoundary tolerates
+// parameters without a value, such as the document MIME type in the example.
+func ParseBoundary(contentType string) (boundary string, err error) {
+	for _, param := range strings.Split(contentType, ";") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.EqualFold(k, "boundary") {
+			boundary = strings.Trim(v, `"`)
+		}
+	}
+	if boundary == "" {
+		err = fmt.Errorf("missing boundary in content type: %s", contentType)
+	}
+	return
+}
+
 // NextPart returns the next part of the multipart stream using the current reader.
 func (mr *MultipartReader) NextPart() (*multipart.Part, error) {
 	return mr.reader.NextPart()
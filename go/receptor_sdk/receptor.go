@@ -7,6 +7,8 @@
 package receptor_sdk
 
 import (
+	"context"
+
 	"github.com/trustero/api/go/receptor_v1"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
@@ -24,10 +26,28 @@ var (
 	Notify               string // Trustero will provide a string tracer ID when it's tracing a receptor execution path.
 	FindEvidence         bool   // If true as part of a scan command, scan for evidence in a service provider account.
 	CredentialsBase64URL string // Service provider credentials as a base64 URL encoded json string.
+	CredentialsFrom      string // Source of service provider credentials, such as env, file:PATH or exec:COMMAND.
 	ReceptorId           string // Trustero's persistent record ID of a record holding a receptor's service provider credentials.
 	ConfigBase64URL      string // Receptor configuration as a base64 URL encoded json string.
 	DiscoveryId          string // Trustero discovery identifier
 	ConnectTimeout       int    // Timeout in seconds to wait for GRPC connection readiness
+	Timeout              int    // Timeout in seconds for a command to complete.  Zero means no timeout.
+	OutputDir            string // Directory to write documents streamed during a dryrun scan to.
+	RetryAttempts        int    // Maximum number of attempts of a Trustero GRPC call.  Zero means the default.
+	BundlePath           string // Path of an offline bundle to record a scan to instead of reporting to Trustero.
+	Compression          string // Content encoding, gzip or zstd, of streamed documents.  Empty means uncompressed.
+	ChunkSize            int    // Maximum size in bytes of a chunk of a streamed report.
+	UploadConcurrency    int    // Maximum number of document evidence uploads in progress at once.
+	TrailingHashes       bool   // If true, the default, hash streamed document files as they're sent, in a trailing manifest part.
+	SummaryFile          string // Path of a JSON file to write the run summary of a command to.
+	StrictRows           bool   // If true, fail a scan on an evidence row field that can't be converted instead of skipping it.
+	TableFormat          string // Format of the evidence tables printed by a dryrun scan: text, markdown, csv, json or html.  Empty for the legacy layout.
+	TableTimezone        string // IANA time zone of the timestamps in the evidence tables printed by a dryrun scan.
+	StateDir             string // Directory of the evidence state store.  Empty means every evidence is reported in full.
+	UnchangedEvidence    string // How evidence unchanged since it was last reported is reported: marker or skip.
+
+	// Regular expressions of secrets to scrub from evidence sources, logs and dry-run output.
+	RedactPatterns []string
 )
 
 // Receptor is the main interface for the Receptor implementor-facing  API.
@@ -75,9 +95,11 @@ type Receptor interface {
 	// receptor
 	GetAuthMethods() (authMethods interface{})
 
-	//GetEvidenceInfo returns a list of Evidences that a receptor has implemented
-	//The metadata is extracted and then printed out
-	//<receptor_type> evidenceinfo
+	// GetEvidenceInfo returns a list of Evidences that a receptor has implemented, with their Caption and
+	// Description but without rows or sources.  Structured evidence declares the struct of its rows in RowType, for
+	// example RowType: GitLabUser{}, so the lint and schema commands can check it.  The metadata is extracted and
+	// then printed out
+	// <receptor_type> evidenceinfo
 	GetEvidenceInfo(credentials interface{}) (evidences []*Evidence)
 
 	// Verify read-only access to a service provider account.  Return ok if the credentials are valid and err
@@ -113,6 +135,50 @@ type Receptor interface {
 	GetInstructions() (instructions string, err error)
 }
 
+// ContextReceptor is an optional extension of the [Receptor] interface.  When a receptor implements
+// ContextReceptor, the CLI framework invokes the context-aware methods below in place of their [Receptor]
+// counterparts.  The context is canceled when the receptor process receives SIGINT or SIGTERM, or when the
+// command's '--timeout' expires.  Implementations should pass the context to service provider API calls and
+// return promptly once it's done.
+type ContextReceptor interface {
+	Receptor
+
+	// VerifyContext is the context-aware equivalent of [Receptor.Verify].
+	VerifyContext(ctx context.Context, credentials interface{}, config interface{}) (ok bool, err error)
+
+	// DiscoverContext is the context-aware equivalent of [Receptor.Discover].
+	DiscoverContext(ctx context.Context, credentials interface{}, config interface{}) (services []*receptor_v1.ServiceEntity, err error)
+
+	// ReportContext is the context-aware equivalent of [Receptor.Report].
+	ReportContext(ctx context.Context, credentials interface{}, config interface{}) (evidences []*Evidence, err error)
+
+	// ReportBatchContext is the context-aware equivalent of [Receptor.ReportBatch].  The implementation must
+	// close evidenceChan when done, including when the context is canceled.
+	ReportBatchContext(ctx context.Context, credentials interface{}, config interface{}, evidenceChan chan []*Evidence)
+}
+
+// Emitter reports batches of evidence on behalf of a [BatchReceptor].
+type Emitter interface {
+	// Emit reports a batch of evidence.  Structured evidence is reported to Trustero before Emit returns, while
+	// document evidence is streamed in the background.  Emit returns an error if the batch couldn't be reported, if
+	// the document upload of a previously emitted batch failed, or if the context is done.  A receptor should stop
+	// collecting evidence and return the error.
+	Emit(evidences ...*Evidence) error
+}
+
+// BatchReceptor is an optional extension of the [Receptor] interface.  When a receptor implements BatchReceptor,
+// the CLI framework invokes ReportBatches in place of [Receptor.ReportBatch] and [ContextReceptor.ReportBatchContext].
+// Unlike ReportBatch, the CLI framework owns the lifecycle of the batches: reporting is complete once ReportBatches
+// returns.  The error returned by ReportBatches, along with any failed upload, fails the scan and is reported in the
+// scan's Notify result.
+type BatchReceptor interface {
+	Receptor
+
+	// ReportBatches reports in-use service entity's configurations as evidence in batches by calling emitter's Emit
+	// for each batch.  The context is the same as the one passed to [ContextReceptor] methods.
+	ReportBatches(ctx context.Context, credentials interface{}, config interface{}, emitter Emitter) error
+}
+
 // Evidence is a discovered evidence from an in-use service.  All rows in the evidence are instances of the same
 // Golang struct.  Fields of this evidence row struct must be public and annotated with Trustero's field annotation
 // where:
@@ -130,6 +196,20 @@ type Receptor interface {
 //	    IsAdmin  bool    `trustero:"display:Admin;order:3"`
 //	    Username string  `trustero:"id;display:User Name;order:1"`
 //	}
+//
+// Fields are converted to a [receptor_v1.Value] as follows:
+//   - a type implementing [TrusteroValuer] converts itself.
+//   - time.Time and *timestamppb.Timestamp are timestamps.
+//   - a type implementing encoding.TextMarshaler or fmt.Stringer, such as time.Duration or an enum, is a string.
+//   - booleans, integers, floating point numbers and strings are converted to their value, and []byte to a string.
+//   - a pointer or an interface is converted as the value it points to, or an empty value if nil.
+//   - a nested struct or a map is a struct list of one struct, whose fields are named by their 'trustero' display
+//     sub-tag, their field name or their map key.
+//   - a slice or an array of structs or maps is a struct list, and of any other type a string list.
+//
+// Fields of other types, such as channels, functions or lists of lists, are skipped with a warning, or fail the scan
+// if the '--strict-rows' flag is set.  So does a pointer, map or slice referring back to a value it's nested in,
+// which is otherwise an empty value.
 type Evidence struct {
 	ServiceName           string                         // ServiceName where this evidence was gathered. For example, "S3".
 	EntityType            string                         // EntityType of rows of evidence.  For example, "bucket".
@@ -137,6 +217,9 @@ type Evidence struct {
 	Description           string                         // Description provides additional information on origins of the evidence.
 	Sources               []*receptor_v1.Source          // Sources of raw API request and response used to gather the evidence.
 	Rows                  []interface{}                  // Rows of formatted evidence represented by a Golang struct.
+	RowType               interface{}                    // RowType is a zero value of the struct of Rows, declared by GetEvidenceInfo in place of rows.
+	Struct                *receptor_v1.Struct            // Structured evidence built at run time, for example by a StructBuilder.  Rows are ignored if set.
+	Columns               []Column                       // Columns of Struct, set by StructBuilder.Evidence so its values are typed without rows.
 	ServiceAccountId      string                         // AccountId of multi-account organization
 	Document              *[]Document                    // Unstructured evidence in a Document format
 	Controls              []string                       // Controls associated with the evidence
@@ -152,6 +235,14 @@ type Evidence struct {
 
 }
 
+// TrusteroValuer is implemented by evidence row field types that convert themselves to a [receptor_v1.Value], in
+// place of the built-in conversion described in [Evidence].  For example, a type can report a provider SDK's
+// struct as a string, or a set of flags as a string list.  A nil value is reported as an empty value, and an error
+// skips the field with a warning or, if the '--strict-rows' flag is set, fails the scan.
+type TrusteroValuer interface {
+	TrusteroValue() (*receptor_v1.Value, error)
+}
+
 // Document is a unstructured byte array that can be used to store any type of data
 // in Content field and Mime describes the Content type
 type Document struct {
@@ -0,0 +1,367 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+// Package receptortest provides an in-process fake Trustero GRPC service for end-to-end testing of a
+// [receptor_sdk.Receptor].  The fake service records every RPC a receptor makes through the real GRPC client
+// path so a test can assert on what would have been sent to Trustero.
+//
+// For example:
+//
+//	srv, err := receptortest.NewServer()
+//	if err != nil {
+//	    t.Fatal(err)
+//	}
+//	defer srv.Close()
+//
+//	creds, _ := receptortest.EncodeCredentials(&Receptor{Token: "token", GroupID: "42"})
+//	if err = srv.Run(&Receptor{}, "scan", receptortest.Token, "--find-evidence", "--credentials", creds); err != nil {
+//	    t.Fatal(err)
+//	}
+//	findings := srv.ReportRequests()
+package receptortest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/trustero/api/go/receptor_sdk"
+	"github.com/trustero/api/go/receptor_sdk/client"
+	"github.com/trustero/api/go/receptor_sdk/cmd"
+	"github.com/trustero/api/go/receptor_v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Token is a Trustero access token accepted by the fake service.  Pass it in place of a real token on the
+// command line given to [Server.Run].
+const Token = "receptortest-token"
+
+const (
+	serverName = "receptortest.local"
+	bufferSize = 1024 * 1024
+)
+
+// runMu serializes [Server.Run] calls since the CLI framework keeps its state in package variables.
+var runMu sync.Mutex
+
+// Server is an in-memory Trustero GRPC service implementing [receptor_v1.ReceptorServer].  All requests received
+// are recorded in the order they arrived.
+type Server struct {
+	receptor_v1.UnimplementedReceptorServer
+
+	// Configuration is returned from GetConfiguration calls.  The receptor object ID of the request is copied
+	// into the returned configuration.
+	Configuration *receptor_v1.ReceptorConfiguration
+
+	listener *bufconn.Listener
+	server   *grpc.Server
+	certPool *x509.CertPool
+
+	mu              sync.Mutex
+	failures        map[string][]error
+	idempotencyKeys map[string][]string
+	verified        []*receptor_v1.Credential
+	discovered      []*receptor_v1.ServiceEntities
+	reports         []*receptor_v1.Finding
+	notifications   []*receptor_v1.JobResult
+	configurations  []*receptor_v1.ReceptorConfiguration
+	streamReports   []*StreamedReport
+}
+
+// NewServer starts a fake Trustero GRPC service listening on an in-memory connection secured with an ephemeral
+// self-signed certificate.  Call [Server.Close] to stop the service.
+func NewServer() (s *Server, err error) {
+	var cert tls.Certificate
+	s = &Server{
+		Configuration:   &receptor_v1.ReceptorConfiguration{},
+		listener:        bufconn.Listen(bufferSize),
+		certPool:        x509.NewCertPool(),
+		failures:        map[string][]error{},
+		idempotencyKeys: map[string][]string{},
+	}
+	if cert, err = s.selfSignedCert(); err != nil {
+		return nil, err
+	}
+
+	s.server = grpc.NewServer(
+		grpc.Creds(credentials.NewServerTLSFromCert(&cert)),
+		grpc.UnaryInterceptor(s.interceptUnary),
+		grpc.StreamInterceptor(s.interceptStream))
+	receptor_v1.RegisterReceptorServer(s.server, s)
+	go func() {
+		_ = s.server.Serve(s.listener)
+	}()
+	return
+}
+
+// Close stops the fake service and closes its listener.
+func (s *Server) Close() {
+	s.server.Stop()
+	_ = s.listener.Close()
+}
+
+// DialOptions returns the GRPC dial options connecting a client to this fake service regardless of the target
+// address dialed.
+func (s *Server) DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return s.listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			ServerName: serverName,
+			RootCAs:    s.certPool,
+		})),
+	}
+}
+
+// Run executes the receptor CLI with the given arguments against this fake service.  The arguments are the
+// same as the receptor's command line, for example "verify", [Token], "--credentials", "...".  Run returns the
+// command's error.
+func (s *Server) Run(r receptor_sdk.Receptor, args ...string) error {
+	runMu.Lock()
+	defer runMu.Unlock()
+
+	saved := client.DialOptions
+	client.DialOptions = s.DialOptions()
+	defer func() {
+		client.DialOptions = saved
+	}()
+
+	return cmd.ExecuteArgs(r, args)
+}
+
+// EncodeCredentials json marshals a credential object and base64 URL encodes it as expected by the
+// '--credentials' and '--config' command line flags.
+func EncodeCredentials(credentialObj interface{}) (encoded string, err error) {
+	var bytes []byte
+	if bytes, err = json.Marshal(credentialObj); err == nil {
+		encoded = base64.URLEncoding.EncodeToString(bytes)
+	}
+	return
+}
+
+// VerifiedRequests returns all recorded Verified requests.
+func (s *Server) VerifiedRequests() []*receptor_v1.Credential {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*receptor_v1.Credential{}, s.verified...)
+}
+
+// DiscoveredRequests returns all recorded Discovered requests.
+func (s *Server) DiscoveredRequests() []*receptor_v1.ServiceEntities {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*receptor_v1.ServiceEntities{}, s.discovered...)
+}
+
+// ReportRequests returns all recorded Report requests.
+func (s *Server) ReportRequests() []*receptor_v1.Finding {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*receptor_v1.Finding{}, s.reports...)
+}
+
+// NotifyRequests returns all recorded Notify requests.
+func (s *Server) NotifyRequests() []*receptor_v1.JobResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*receptor_v1.JobResult{}, s.notifications...)
+}
+
+// SetConfigurationRequests returns all recorded SetConfiguration requests.
+func (s *Server) SetConfigurationRequests() []*receptor_v1.ReceptorConfiguration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*receptor_v1.ReceptorConfiguration{}, s.configurations...)
+}
+
+// StreamReportRequests returns all recorded and reassembled StreamReport uploads.
+func (s *Server) StreamReportRequests() []*StreamedReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*StreamedReport{}, s.streamReports...)
+}
+
+// FailNext makes the next count calls of the named RPC method, for example "Report" or "StreamReport", fail
+// with err before reaching the method.  Use a GRPC status error, such as status.Error(codes.Unavailable, "..."),
+// to exercise the receptor's retry behavior.
+func (s *Server) FailNext(method string, count int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < count; i++ {
+		s.failures[method] = append(s.failures[method], err)
+	}
+}
+
+// IdempotencyKeys returns the idempotency keys received with each call of the named RPC method, including
+// failed calls.  Calls without a key are recorded as an empty string.
+func (s *Server) IdempotencyKeys(method string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.idempotencyKeys[method]...)
+}
+
+// Reset discards all recorded requests and pending failures.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = map[string][]error{}
+	s.idempotencyKeys = map[string][]string{}
+	s.verified = nil
+	s.discovered = nil
+	s.reports = nil
+	s.notifications = nil
+	s.configurations = nil
+	s.streamReports = nil
+}
+
+// Verified implements [receptor_v1.ReceptorServer.Verified].
+func (s *Server) Verified(_ context.Context, in *receptor_v1.Credential) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verified = append(s.verified, proto.Clone(in).(*receptor_v1.Credential))
+	return &emptypb.Empty{}, nil
+}
+
+// GetConfiguration implements [receptor_v1.ReceptorServer.GetConfiguration].
+func (s *Server) GetConfiguration(_ context.Context, in *receptor_v1.ReceptorOID) (*receptor_v1.ReceptorConfiguration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	config := proto.Clone(s.Configuration).(*receptor_v1.ReceptorConfiguration)
+	config.ReceptorObjectId = in.GetReceptorObjectId()
+	return config, nil
+}
+
+// Discovered implements [receptor_v1.ReceptorServer.Discovered].
+func (s *Server) Discovered(_ context.Context, in *receptor_v1.ServiceEntities) (*wrapperspb.StringValue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.discovered = append(s.discovered, proto.Clone(in).(*receptor_v1.ServiceEntities))
+	return wrapperspb.String(in.GetReceptorType()), nil
+}
+
+// Report implements [receptor_v1.ReceptorServer.Report].
+func (s *Server) Report(_ context.Context, in *receptor_v1.Finding) (*wrapperspb.StringValue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reports = append(s.reports, proto.Clone(in).(*receptor_v1.Finding))
+	return wrapperspb.String(in.GetDiscoveryId()), nil
+}
+
+// Notify implements [receptor_v1.ReceptorServer.Notify].
+func (s *Server) Notify(_ context.Context, in *receptor_v1.JobResult) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifications = append(s.notifications, proto.Clone(in).(*receptor_v1.JobResult))
+	return &emptypb.Empty{}, nil
+}
+
+// SetConfiguration implements [receptor_v1.ReceptorServer.SetConfiguration].
+func (s *Server) SetConfiguration(_ context.Context, in *receptor_v1.ReceptorConfiguration) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configurations = append(s.configurations, proto.Clone(in).(*receptor_v1.ReceptorConfiguration))
+	return &emptypb.Empty{}, nil
+}
+
+// StreamReport implements [receptor_v1.ReceptorServer.StreamReport].  The uploaded chunks are reassembled and
+// decoded into a [StreamedReport].
+func (s *Server) StreamReport(stream grpc.ClientStreamingServer[receptor_v1.ReportChunk, receptor_v1.ReportResponse]) (err error) {
+	var report *StreamedReport
+	if report, err = receiveStreamedReport(stream); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.streamReports = append(s.streamReports, report)
+	s.mu.Unlock()
+
+	return stream.SendAndClose(&receptor_v1.ReportResponse{Status: "ok"})
+}
+
+func (s *Server) interceptUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := s.intercept(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) interceptStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.intercept(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// intercept records the idempotency key of a call and returns the next injected failure of its method.
+func (s *Server) intercept(ctx context.Context, fullMethod string) (err error) {
+	method := path.Base(fullMethod)
+	key := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(client.IdempotencyKeyHeader); len(values) > 0 {
+			key = values[0]
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idempotencyKeys[method] = append(s.idempotencyKeys[method], key)
+	if failures := s.failures[method]; len(failures) > 0 {
+		err, s.failures[method] = failures[0], failures[1:]
+	}
+	return
+}
+
+func (s *Server) selfSignedCert() (cert tls.Certificate, err error) {
+	var (
+		key  *ecdsa.PrivateKey
+		der  []byte
+		leaf *x509.Certificate
+	)
+	if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: serverName},
+		DNSNames:              []string{serverName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if der, err = x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key); err != nil {
+		return
+	}
+	if leaf, err = x509.ParseCertificate(der); err != nil {
+		return
+	}
+	s.certPool.AddCert(leaf)
+
+	cert = tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	return
+}
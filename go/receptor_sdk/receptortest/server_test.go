@@ -0,0 +1,387 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package receptortest_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/trustero/api/go/receptor_sdk"
+	"github.com/trustero/api/go/receptor_sdk/bundle"
+	"github.com/trustero/api/go/receptor_sdk/client"
+	"github.com/trustero/api/go/receptor_sdk/receptortest"
+	"github.com/trustero/api/go/receptor_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type testUser struct {
+	Username string `trustero:"id;display:Username;order:1"`
+	IsAdmin  bool   `trustero:"display:Admin;order:2"`
+}
+
+type testCredentials struct {
+	Token string `trustero:"display:Token;placeholder:token"`
+}
+
+// testReceptor reports one structured evidence and, if document is set, one streamed document evidence.
+type testReceptor struct {
+	credentials testCredentials
+	document    string // path of the streamed document file
+}
+
+func (r *testReceptor) GetReceptorType() string                                    { return "trr-test" }
+func (r *testReceptor) GetKnownServices() []string                                 { return []string{"Test"} }
+func (r *testReceptor) GetCredentialObj() interface{}                              { return &r.credentials }
+func (r *testReceptor) GetConfigObj(_ interface{}) interface{}                     { return nil }
+func (r *testReceptor) GetConfigObjDesc() interface{}                              { return nil }
+func (r *testReceptor) GetAuthMethods() interface{}                                { return nil }
+func (r *testReceptor) GetLogo() (string, error)                                   { return "", nil }
+func (r *testReceptor) GetInstructions() (string, error)                           { return "", nil }
+func (r *testReceptor) ReportBatch(_ interface{}, c chan []*receptor_sdk.Evidence) { close(c) }
+
+func (r *testReceptor) GetEvidenceInfo(_ interface{}) []*receptor_sdk.Evidence {
+	return []*receptor_sdk.Evidence{{Caption: "Users", ServiceName: "Test", RowType: testUser{}}}
+}
+
+func (r *testReceptor) Verify(credentials interface{}, _ interface{}) (bool, error) {
+	if credentials.(*testCredentials).Token != "secret" {
+		return false, errors.New("invalid token")
+	}
+	return true, nil
+}
+
+func (r *testReceptor) Discover(_ interface{}, _ interface{}) ([]*receptor_v1.ServiceEntity, error) {
+	return []*receptor_v1.ServiceEntity{{ServiceName: "Test", EntityType: "user", EntityInstanceName: "users", EntityInstanceId: "users"}}, nil
+}
+
+func (r *testReceptor) Report(_ interface{}, _ interface{}) (evidences []*receptor_sdk.Evidence, err error) {
+	evidences = append(evidences, &receptor_sdk.Evidence{
+		Caption:     "Users",
+		ServiceName: "Test",
+		EntityType:  "user",
+		Rows:        []interface{}{testUser{Username: "alice", IsAdmin: true}, testUser{Username: "bob"}},
+	})
+	if r.document != "" {
+		evidences = append(evidences, &receptor_sdk.Evidence{
+			Caption:     "Policy",
+			ServiceName: "Test",
+			Document: &[]receptor_sdk.Document{{
+				StreamFilePath: r.document,
+				FileName:       "policy.txt",
+				Mime:           "text/plain",
+			}},
+		})
+	}
+	return
+}
+
+func (r *testReceptor) Configure(_ interface{}) (*receptor_v1.ReceptorConfiguration, error) {
+	return &receptor_v1.ReceptorConfiguration{}, nil
+}
+
+func newServer(t *testing.T) *receptortest.Server {
+	t.Helper()
+	srv, err := receptortest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func encodeCredentials(t *testing.T, token string) string {
+	t.Helper()
+	creds, err := receptortest.EncodeCredentials(&testCredentials{Token: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return creds
+}
+
+func TestVerify(t *testing.T) {
+	srv := newServer(t)
+	for _, test := range []struct {
+		token string
+		ok    bool
+	}{{"secret", true}, {"wrong", false}} {
+		srv.Reset()
+		_ = srv.Run(&testReceptor{}, "verify", receptortest.Token, "--credentials", encodeCredentials(t, test.token))
+		verified := srv.VerifiedRequests()
+		if len(verified) != 1 || verified[0].GetIsCredentialValid() != test.ok {
+			t.Errorf("token %q: Verified requests = %v, want one with IsCredentialValid %v", test.token, verified, test.ok)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	srv := newServer(t)
+	document := filepath.Join(t.TempDir(), "policy.txt")
+	content := []byte("all users must use MFA\n")
+	if err := os.WriteFile(document, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := srv.Run(&testReceptor{document: document}, "scan", receptortest.Token, "--find-evidence",
+		"--credentials", encodeCredentials(t, "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var users *receptor_v1.Struct
+	for _, finding := range srv.ReportRequests() {
+		for _, evidence := range finding.GetEvidences() {
+			if evidence.GetCaption() == "Users" {
+				users = evidence.GetStruct()
+			}
+		}
+	}
+	if users == nil {
+		t.Fatalf("no Users evidence in Report requests %v", srv.ReportRequests())
+	}
+	if got := len(users.GetRows()); got != 2 {
+		t.Fatalf("Users rows = %d, want 2", got)
+	}
+	alice := users.GetRows()[0]
+	if alice.GetEntityInstanceId() != "alice" || !alice.GetCols()["IsAdmin"].GetBoolValue() {
+		t.Errorf("first Users row = %v, want alice as an admin", alice)
+	}
+
+	streamed := srv.StreamReportRequests()
+	if len(streamed) != 1 {
+		t.Fatalf("StreamReport requests = %d, want 1", len(streamed))
+	}
+	if evidences := streamed[0].Finding.GetEvidences(); len(evidences) != 1 || evidences[0].GetCaption() != "Policy" {
+		t.Errorf("streamed finding evidences = %v, want the Policy evidence", evidences)
+	}
+	if documents := streamed[0].Documents; len(documents) != 1 || string(documents[0].Body) != string(content) {
+		t.Errorf("streamed documents = %v, want policy.txt", documents)
+	}
+	if _, err = os.Stat(document); !os.IsNotExist(err) {
+		t.Errorf("stream file %s wasn't removed after it was streamed", document)
+	}
+}
+
+func TestScanRetriesReport(t *testing.T) {
+	srv := newServer(t)
+	srv.FailNext("Report", 1, status.Error(codes.Unavailable, "try again"))
+
+	err := srv.Run(&testReceptor{}, "scan", receptortest.Token, "--find-evidence",
+		"--credentials", encodeCredentials(t, "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(srv.ReportRequests()); got != 1 {
+		t.Errorf("Report requests = %d, want 1", got)
+	}
+	keys := srv.IdempotencyKeys("Report")
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("Report idempotency keys = %q, want the same key for the failed call and its retry", keys)
+	}
+}
+
+func TestScanFailsWithoutRetry(t *testing.T) {
+	srv := newServer(t)
+	srv.FailNext("Report", 1, status.Error(codes.InvalidArgument, "bad finding"))
+
+	_ = srv.Run(&testReceptor{}, "scan", receptortest.Token, "--find-evidence",
+		"--credentials", encodeCredentials(t, "secret"))
+
+	if got := len(srv.ReportRequests()); got != 0 {
+		t.Errorf("Report requests = %d, want 0", got)
+	}
+	if got := len(srv.IdempotencyKeys("Report")); got != 1 {
+		t.Errorf("Report calls = %d, want 1 since InvalidArgument isn't retried", got)
+	}
+}
+
+func TestScanRetriesStreamReport(t *testing.T) {
+	srv := newServer(t)
+	document := filepath.Join(t.TempDir(), "policy.txt")
+	if err := os.WriteFile(document, []byte("all users must use MFA\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srv.FailNext("StreamReport", 1, status.Error(codes.Unavailable, "try again"))
+
+	err := srv.Run(&testReceptor{document: document}, "scan", receptortest.Token, "--find-evidence",
+		"--credentials", encodeCredentials(t, "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streamed := srv.StreamReportRequests()
+	if len(streamed) != 1 || len(streamed[0].Documents) != 1 {
+		t.Fatalf("StreamReport requests = %v, want one with the replayed document", streamed)
+	}
+	keys := srv.IdempotencyKeys("StreamReport")
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("StreamReport idempotency keys = %q, want the same key for the failed upload and its retry", keys)
+	}
+}
+
+func TestUploadBundle(t *testing.T) {
+	srv := newServer(t)
+	dir := t.TempDir()
+	document := filepath.Join(dir, "policy.txt")
+	if err := os.WriteFile(document, []byte("all users must use MFA\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "evidence.trb")
+	err := srv.Run(&testReceptor{document: document}, "scan", "--find-evidence", "--bundle", path,
+		"--credentials", encodeCredentials(t, "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := bundle.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := map[string][]string{}
+	for _, entry := range reader.Manifest().Entries {
+		recorded[entry.Kind] = append(recorded[entry.Kind], entry.IdempotencyKey)
+	}
+	reader.Close()
+
+	// each upload of the bundle sends the keys its requests were recorded with
+	for upload := 1; upload <= 2; upload++ {
+		if err = srv.Run(&testReceptor{}, "upload", receptortest.Token, path); err != nil {
+			t.Fatal(err)
+		}
+		for kind, method := range map[string]string{bundle.KindReport: "Report", bundle.KindStream: "StreamReport"} {
+			keys := srv.IdempotencyKeys(method)
+			if len(recorded[kind]) == 0 || len(keys) != upload*len(recorded[kind]) ||
+				keys[len(keys)-1] != recorded[kind][len(recorded[kind])-1] {
+				t.Errorf("upload %d: %s idempotency keys = %q, want the recorded keys %q", upload, method, keys, recorded[kind])
+			}
+		}
+	}
+
+	// an upload under another discovery derives its keys from the recorded keys
+	srv.Reset()
+	if err = srv.Run(&testReceptor{}, "upload", receptortest.Token, path, "--discovery-id", "other"); err != nil {
+		t.Fatal(err)
+	}
+	want := client.IdempotencyKey(recorded[bundle.KindReport][0], "other")
+	if keys := srv.IdempotencyKeys("Report"); len(keys) != 1 || keys[0] != want {
+		t.Errorf("Report idempotency keys under another discovery = %q, want %q", keys, want)
+	}
+}
+
+type typedCredentials struct {
+	Token string `trustero:"display:Token;placeholder:token"`
+}
+
+func (c *typedCredentials) Validate() error {
+	if c.Token != "secret" {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+type typedConfig struct {
+	Groups   []string `json:"groups"`
+	MaxUsers int      `json:"max_users"`
+}
+
+func (k *typedConfig) Validate() error {
+	if k.MaxUsers < 0 {
+		return errors.New("max_users must not be negative")
+	}
+	return nil
+}
+
+// typedReceptor records the typed credentials and config it's given.
+type typedReceptor struct {
+	credentials *typedCredentials
+	config      *typedConfig
+}
+
+func (r *typedReceptor) GetReceptorType() string                                      { return "trr-typed" }
+func (r *typedReceptor) GetKnownServices() []string                                   { return []string{"Test"} }
+func (r *typedReceptor) GetConfigObjDesc() interface{}                                { return nil }
+func (r *typedReceptor) GetAuthMethods() interface{}                                  { return nil }
+func (r *typedReceptor) GetLogo() (string, error)                                     { return "", nil }
+func (r *typedReceptor) GetInstructions() (string, error)                             { return "", nil }
+func (r *typedReceptor) GetEvidenceInfo(_ *typedCredentials) []*receptor_sdk.Evidence { return nil }
+
+func (r *typedReceptor) Verify(_ context.Context, c *typedCredentials, k *typedConfig) (bool, error) {
+	r.credentials, r.config = c, k
+	return true, nil
+}
+
+func (r *typedReceptor) Discover(_ context.Context, c *typedCredentials, k *typedConfig) ([]*receptor_v1.ServiceEntity, error) {
+	r.credentials, r.config = c, k
+	return nil, nil
+}
+
+func (r *typedReceptor) Report(_ context.Context, c *typedCredentials, k *typedConfig) ([]*receptor_sdk.Evidence, error) {
+	r.credentials, r.config = c, k
+	return []*receptor_sdk.Evidence{{
+		Caption:     "Users",
+		ServiceName: "Test",
+		Rows:        []interface{}{testUser{Username: "alice"}},
+	}}, nil
+}
+
+func (r *typedReceptor) ReportBatch(_ context.Context, _ *typedCredentials, _ *typedConfig, c chan []*receptor_sdk.Evidence) {
+	close(c)
+}
+
+func (r *typedReceptor) Configure(_ *typedCredentials) (*receptor_v1.ReceptorConfiguration, error) {
+	return &receptor_v1.ReceptorConfiguration{}, nil
+}
+
+func TestTypedReceptor(t *testing.T) {
+	srv := newServer(t)
+	for _, test := range []struct {
+		name   string
+		token  string
+		config typedConfig
+		valid  bool
+	}{
+		{"valid", "secret", typedConfig{Groups: []string{"admins"}, MaxUsers: 10}, true},
+		{"invalid credentials", "wrong", typedConfig{}, false},
+		{"invalid config", "secret", typedConfig{MaxUsers: -1}, false},
+	} {
+		srv.Reset()
+		creds, err := receptortest.EncodeCredentials(&typedCredentials{Token: test.token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, err := receptortest.EncodeCredentials(&test.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &typedReceptor{}
+		err = srv.Run(receptor_sdk.NewTypedAdapter[typedCredentials, typedConfig](r), "scan", receptortest.Token,
+			"--find-evidence", "--credentials", creds, "--config", config)
+
+		if !test.valid {
+			// Validate fails the scan before the receptor is called
+			if err == nil || r.credentials != nil || len(srv.ReportRequests()) != 0 {
+				t.Errorf("%s: scan = %v with %d Report requests, want a validation error", test.name, err,
+					len(srv.ReportRequests()))
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if r.credentials == nil || r.credentials.Token != "secret" {
+			t.Errorf("%s: typed credentials = %+v, want the decoded token", test.name, r.credentials)
+		}
+		if r.config == nil || strings.Join(r.config.Groups, ",") != "admins" || r.config.MaxUsers != 10 {
+			t.Errorf("%s: typed config = %+v, want the decoded config", test.name, r.config)
+		}
+		if len(srv.ReportRequests()) != 1 {
+			t.Errorf("%s: Report requests = %d, want 1", test.name, len(srv.ReportRequests()))
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package receptortest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/textproto"
+
+	"github.com/trustero/api/go/receptor_sdk/multipartkit"
+	"github.com/trustero/api/go/receptor_v1"
+	"google.golang.org/grpc"
+)
+
+// StreamedReport is a StreamReport upload reassembled from its [receptor_v1.ReportChunk] stream.
+type StreamedReport struct {
+	ContentType string               // ContentType sent in the leading boundary chunk.
+	Payload     []byte               // Payload is the raw multipart body following the boundary chunk.
+	Finding     *receptor_v1.Finding // Finding decoded from the "receptor_v1.Finding" part.
+	Sources     *receptor_v1.Sources // Sources decoded from the "receptor_v1.Sources" part.
+	Documents   []*Document          // Documents are the file parts in the order they were sent.
+}
+
+// Document is a file part of a [StreamedReport].
+type Document struct {
+	Name        string               // Name of the multipart part.
+	FileName    string               // FileName of the document.
+	ContentType string               // ContentType is the document's MIME type.
+	Header      textproto.MIMEHeader // Header holds all part headers including document metadata.
+	Body        []byte               // Body of the document.
+}
+
+func receiveStreamedReport(stream grpc.ClientStreamingServer[receptor_v1.ReportChunk, receptor_v1.ReportResponse]) (report *StreamedReport, err error) {
+	var (
+		chunk   *receptor_v1.ReportChunk
+		payload bytes.Buffer
+	)
+	report = &StreamedReport{}
+	for {
+		if chunk, err = stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if chunk.GetIsBoundary() {
+			report.ContentType = string(chunk.GetContent())
+			continue
+		}
+		payload.Write(chunk.GetContent())
+	}
+	report.Payload = payload.Bytes()
+
+	if err = report.decode(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (r *StreamedReport) decode() (err error) {
+	var (
+		reader   *multipartkit.MultipartReader
+		boundary string
+		body     []byte
+	)
+	if boundary, err = multipartkit.ParseBoundary(r.ContentType); err != nil {
+		return
+	}
+	if reader, err = multipartkit.NewMultipartReader(bytes.NewReader(r.Payload), boundary, 0); err != nil {
+		return
+	}
+
+	for {
+		part, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read multipart part: %v", err)
+		}
+
+		switch {
+		case part.Kind == multipartkit.PartProtobuf && part.Name == "receptor_v1.Finding":
+			r.Finding = &receptor_v1.Finding{}
+			err = part.Decode(r.Finding)
+		case part.Kind == multipartkit.PartProtobuf && part.Name == "receptor_v1.Sources":
+			r.Sources = &receptor_v1.Sources{}
+			err = part.Decode(r.Sources)
+		default:
+			// Documents are verified against their Content-Size and Content-Hash headers while they're read.
+			if body, err = io.ReadAll(part); err == nil {
+				r.Documents = append(r.Documents, &Document{
+					Name:        part.Name,
+					FileName:    part.FileName,
+					ContentType: part.ContentType,
+					Header:      part.Header,
+					Body:        body,
+				})
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode part %s: %w", part.Name, err)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package receptor_sdk
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trustero/api/go/receptor_v1"
+)
+
+// DefaultMaxBodySize is the default maximum number of bytes of a request or response body recorded by a [Recorder].
+const DefaultMaxBodySize = 1 << 20
+
+// DefaultRedactedHeaders lists the headers whose values a [Recorder] replaces with "REDACTED" by default.
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "Private-Token"}
+
+// Recorder is an [http.RoundTripper] recording the service provider API calls made through it.  Each call is
+// recorded as an [Exchange] to the [Capture] sessions active when the call is made, so the raw API trail of an
+// evidence is complete by construction.  A capture session is either scoped to a context with [WithCapture], and
+// records the calls made with that context, or started explicitly with [Recorder.StartCapture], and records every
+// call made through the recorder until it's stopped.  Attach a capture to an evidence with [Evidence.AddCapture].
+//
+// For example:
+//
+//	recorder := receptor_sdk.NewRecorder(nil)
+//	client := &http.Client{Transport: recorder}
+//
+//	ctx, capture := receptor_sdk.WithCapture(ctx)
+//	users, err := listUsers(ctx, client)
+//	...
+//	evidence.AddCapture(capture)
+type Recorder struct {
+	Transport       http.RoundTripper // Transport sends the calls.  Nil means http.DefaultTransport.
+	MaxBodySize     int64             // Maximum number of body bytes recorded.  Zero means DefaultMaxBodySize.
+	RedactedHeaders []string          // Headers whose values aren't recorded.  Nil means DefaultRedactedHeaders.
+
+	mu       sync.Mutex
+	captures []*Capture // explicit capture sessions
+}
+
+// NewRecorder returns a [Recorder] sending calls with transport, or http.DefaultTransport if transport is nil.
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	return &Recorder{Transport: transport}
+}
+
+// Client returns an [http.Client] making calls through the recorder.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// StartCapture starts an explicit capture session recording every call made through the recorder, from any
+// goroutine, until the capture is stopped.
+func (r *Recorder) StartCapture() *Capture {
+	c := &Capture{recorder: r}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.captures = append(r.captures, c)
+	return c
+}
+
+func (r *Recorder) stopCapture(c *Capture) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, capture := range r.captures {
+		if capture == c {
+			r.captures = append(r.captures[:i], r.captures[i+1:]...)
+			return
+		}
+	}
+}
+
+// RoundTrip implements [http.RoundTripper].  The call is sent with the recorder's Transport and recorded to the
+// capture sessions of the request's context and to the recorder's explicit capture sessions.
+func (r *Recorder) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	r.mu.Lock()
+	captures := append(capturesFromContext(req.Context()), r.captures...)
+	r.mu.Unlock()
+	if len(captures) == 0 {
+		return r.transport().RoundTrip(req)
+	}
+
+	exchange := &Exchange{
+		Method:        req.Method,
+		URL:           req.URL.String(),
+		RequestHeader: r.redact(req.Header),
+		Started:       time.Now(),
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		// record the request body as the transport sends it, and again from the start if the transport resends it
+		requestBody := &limitedBuffer{limit: r.maxBodySize()}
+		exchange.requestBody = requestBody
+		req = req.Clone(req.Context())
+		req.Body = &recordingBody{ReadCloser: req.Body, buffer: requestBody}
+		if getBody := req.GetBody; getBody != nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				requestBody.Reset()
+				return &recordingBody{ReadCloser: body, buffer: requestBody}, nil
+			}
+		}
+	}
+
+	resp, err = r.transport().RoundTrip(req)
+	exchange.Duration = time.Since(exchange.Started)
+	if err != nil {
+		exchange.Err = err.Error()
+	} else {
+		exchange.StatusCode = resp.StatusCode
+		exchange.Status = resp.Status
+		exchange.ResponseHeader = r.redact(resp.Header)
+		// record the response body as the caller reads it
+		exchange.responseBody = &limitedBuffer{limit: r.maxBodySize()}
+		resp.Body = &recordingBody{ReadCloser: resp.Body, buffer: exchange.responseBody}
+	}
+
+	for _, c := range captures {
+		c.add(exchange)
+	}
+	return
+}
+
+func (r *Recorder) transport() http.RoundTripper {
+	if r.Transport == nil {
+		return http.DefaultTransport
+	}
+	return r.Transport
+}
+
+func (r *Recorder) maxBodySize() int64 {
+	if r.MaxBodySize <= 0 {
+		return DefaultMaxBodySize
+	}
+	return r.MaxBodySize
+}
+
+// redact returns a copy of header with the values of the recorder's redacted headers replaced.
+func (r *Recorder) redact(header http.Header) http.Header {
+	redacted := header.Clone()
+	if redacted == nil {
+		redacted = http.Header{}
+	}
+	names := r.RedactedHeaders
+	if names == nil {
+		names = DefaultRedactedHeaders
+	}
+	for _, name := range names {
+		if values := redacted.Values(name); len(values) > 0 {
+			redacted.Set(name, "REDACTED")
+		}
+	}
+	return redacted
+}
+
+// Exchange is a service provider API call recorded by a [Recorder].
+type Exchange struct {
+	Method         string        // HTTP method of the request.
+	URL            string        // URL of the request.
+	RequestHeader  http.Header   // Headers of the request, with redacted headers' values replaced.
+	StatusCode     int           // Status code of the response.  Zero if the call failed.
+	Status         string        // Status line of the response.  For example, "200 OK".
+	ResponseHeader http.Header   // Headers of the response, with redacted headers' values replaced.
+	Err            string        // Error the call failed with, if any.
+	Started        time.Time     // Time the call was made.
+	Duration       time.Duration // Duration until the response headers were received.
+
+	requestBody  *limitedBuffer
+	responseBody *limitedBuffer
+}
+
+// RequestBody returns the part of the request body sent so far, up to the recorder's MaxBodySize.
+func (x *Exchange) RequestBody() []byte {
+	if x.requestBody == nil {
+		return nil
+	}
+	return x.requestBody.Bytes()
+}
+
+// ResponseBody returns the part of the response body read so far, up to the recorder's MaxBodySize.
+func (x *Exchange) ResponseBody() []byte {
+	if x.responseBody == nil {
+		return nil
+	}
+	return x.responseBody.Bytes()
+}
+
+// Source returns the exchange as a [receptor_v1.Source].  The raw request and response are formatted as HTTP
+// messages: the request or status line, the headers, a blank line and the body.
+func (x *Exchange) Source() *receptor_v1.Source {
+	var request, response strings.Builder
+	fmt.Fprintf(&request, "%s %s\r\n", x.Method, x.URL)
+	_ = x.RequestHeader.Write(&request)
+	request.WriteString("\r\n")
+	request.Write(x.RequestBody())
+
+	if len(x.Err) > 0 {
+		response.WriteString(x.Err)
+	} else {
+		fmt.Fprintf(&response, "%s\r\n", x.Status)
+		_ = x.ResponseHeader.Write(&response)
+		response.WriteString("\r\n")
+		response.Write(x.ResponseBody())
+	}
+	return &receptor_v1.Source{RawApiRequest: request.String(), RawApiResponse: response.String()}
+}
+
+// Capture is a capture session of a [Recorder].  Its methods are safe for concurrent use.
+type Capture struct {
+	recorder  *Recorder // nil for a capture scoped to a context
+	mu        sync.Mutex
+	exchanges []*Exchange
+}
+
+type captureKey struct{}
+
+// WithCapture returns a context and a capture session recording the calls made with the context, or a context
+// derived from it, through any [Recorder].  Captures nest: a call is recorded to every capture of its context.
+func WithCapture(ctx context.Context) (context.Context, *Capture) {
+	c := &Capture{}
+	captures := append(capturesFromContext(ctx), c)
+	return context.WithValue(ctx, captureKey{}, captures), c
+}
+
+func capturesFromContext(ctx context.Context) []*Capture {
+	captures, _ := ctx.Value(captureKey{}).([]*Capture)
+	return captures[:len(captures):len(captures)] // appending must copy
+}
+
+// Stop stops an explicit capture session.  Exchanges recorded so far are kept.  Stop is a no-op for a capture
+// scoped to a context.
+func (c *Capture) Stop() {
+	if c.recorder != nil {
+		c.recorder.stopCapture(c)
+	}
+}
+
+// Exchanges returns the exchanges recorded so far, in the order the calls were made.
+func (c *Capture) Exchanges() []*Exchange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Exchange(nil), c.exchanges...)
+}
+
+// Sources returns the exchanges recorded so far as [receptor_v1.Source] entries.
+func (c *Capture) Sources() (sources []*receptor_v1.Source) {
+	for _, exchange := range c.Exchanges() {
+		sources = append(sources, exchange.Source())
+	}
+	return
+}
+
+// Reset discards the exchanges recorded so far, so the capture can be reused for another evidence.
+func (c *Capture) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.exchanges = nil
+}
+
+func (c *Capture) add(exchange *Exchange) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.exchanges = append(c.exchanges, exchange)
+}
+
+// AddCapture appends the exchanges recorded by a capture session to an Evidence struct's sources.  Response bodies
+// are recorded as they're read, so add the capture once the responses have been read.
+func (ev *Evidence) AddCapture(c *Capture) *Evidence {
+	ev.Sources = append(ev.Sources, c.Sources()...)
+	return ev
+}
+
+// limitedBuffer records up to limit bytes written to it.
+type limitedBuffer struct {
+	mu    sync.Mutex
+	limit int64
+	buf   bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if remaining := b.limit - int64(b.buf.Len()); remaining > 0 {
+		if int64(len(p)) > remaining {
+			b.buf.Write(p[:remaining])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+// recordingBody records a request or response body as it's read.
+type recordingBody struct {
+	io.ReadCloser
+	buffer *limitedBuffer
+}
+
+func (b *recordingBody) Read(p []byte) (n int, err error) {
+	n, err = b.ReadCloser.Read(p)
+	_, _ = b.buffer.Write(p[:n])
+	return
+}
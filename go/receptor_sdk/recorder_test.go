@@ -0,0 +1,123 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package receptor_sdk
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newEchoServer returns a server responding with the request body, a session cookie and the request's
+// Authorization header in X-Authorization.
+func newEchoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t"})
+		w.Header().Set("X-Authorization", req.Header.Get("Authorization"))
+		_, _ = io.Copy(w, req.Body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRecorder(t *testing.T) {
+	srv := newEchoServer(t)
+	recorder := &Recorder{MaxBodySize: 8, RedactedHeaders: append([]string{"X-Api-Key"}, DefaultRedactedHeaders...)}
+	ctx, capture := WithCapture(context.Background())
+
+	body := strings.Repeat("0123456789", 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/users", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Api-Key", "key")
+	req.Header.Set("Accept", "application/json")
+	resp, err := recorder.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	echoed, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the call is sent unchanged
+	if string(echoed) != body || resp.Header.Get("X-Authorization") != "Bearer token" {
+		t.Errorf("server received body %q and Authorization %q, want the request's", echoed, resp.Header.Get("X-Authorization"))
+	}
+	if req.Header.Get("Authorization") != "Bearer token" {
+		t.Error("recording the call redacted the request's own headers")
+	}
+
+	exchanges := capture.Exchanges()
+	if len(exchanges) != 1 {
+		t.Fatalf("exchanges = %d, want 1", len(exchanges))
+	}
+	x := exchanges[0]
+	if x.Method != http.MethodPost || x.URL != srv.URL+"/users" || x.StatusCode != http.StatusOK {
+		t.Errorf("exchange = %s %s %d, want POST /users 200", x.Method, x.URL, x.StatusCode)
+	}
+	for header, want := range map[string]string{"Authorization": "REDACTED", "X-Api-Key": "REDACTED", "Accept": "application/json"} {
+		if got := x.RequestHeader.Get(header); got != want {
+			t.Errorf("recorded request header %s = %q, want %q", header, got, want)
+		}
+	}
+	if got := x.ResponseHeader.Get("Set-Cookie"); got != "REDACTED" {
+		t.Errorf("recorded Set-Cookie = %q, want REDACTED", got)
+	}
+	// bodies are recorded up to MaxBodySize as they're sent and read
+	if string(x.RequestBody()) != body[:8] || string(x.ResponseBody()) != body[:8] {
+		t.Errorf("recorded bodies = %q and %q, want the first 8 bytes", x.RequestBody(), x.ResponseBody())
+	}
+
+	source := x.Source()
+	if !strings.HasPrefix(source.RawApiRequest, "POST "+srv.URL+"/users\r\n") || strings.Contains(source.RawApiRequest, "token") {
+		t.Errorf("raw request = %q, want the request line and no token", source.RawApiRequest)
+	}
+	if !strings.HasPrefix(source.RawApiResponse, "200 OK\r\n") || strings.Contains(source.RawApiResponse, "s3cr3t") {
+		t.Errorf("raw response = %q, want the status line and no cookie", source.RawApiResponse)
+	}
+}
+
+func TestRecorderCaptures(t *testing.T) {
+	srv := newEchoServer(t)
+	recorder := NewRecorder(nil)
+	get := func(ctx context.Context) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := recorder.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	explicit := recorder.StartCapture()
+	outerCtx, outer := WithCapture(context.Background())
+	innerCtx, inner := WithCapture(outerCtx)
+	get(innerCtx)
+	get(outerCtx)
+	explicit.Stop()
+	get(outerCtx)
+
+	for name, test := range map[string]struct {
+		capture *Capture
+		want    int
+	}{"explicit": {explicit, 2}, "outer": {outer, 3}, "inner": {inner, 1}} {
+		if got := len(test.capture.Exchanges()); got != test.want {
+			t.Errorf("%s capture exchanges = %d, want %d", name, got, test.want)
+		}
+	}
+	if exchange := inner.Exchanges()[0]; exchange.RequestBody() != nil {
+		t.Errorf("recorded body of a GET = %q, want none", exchange.RequestBody())
+	}
+}
@@ -0,0 +1,188 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package receptor_sdk
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/trustero/api/go/receptor_v1"
+)
+
+// Redacted replaces the secrets scrubbed by a [Redactor].
+const Redacted = "REDACTED"
+
+// MinSecretLength is the minimum length of a secret value scrubbed by a [Redactor].  Shorter values, such as a
+// one-character placeholder, would scrub unrelated text.
+const MinSecretLength = 4
+
+// DefaultRedactionPatterns are the patterns scrubbed by [DefaultRedactor]: bearer tokens, AWS access key IDs and
+// secret access keys, and GitLab and GitHub access tokens.
+var DefaultRedactionPatterns = []string{
+	`(?i)\bbearer\s+([a-z0-9\-._~+/]+=*)`,
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	`(?i)aws_?secret_?access_?key["'\s:=]+([a-z0-9/+=]{40})`,
+	`\bglpat-[0-9A-Za-z_\-]{20,}`,
+	`\bgh[pousr]_[0-9A-Za-z]{36,}`,
+}
+
+// DefaultRedactor scrubs secrets from the evidence sources, log lines and dry-run output of the CLI framework.  The
+// CLI framework adds the values of the credential fields tagged as secret to it, along with the patterns given with
+// the '--redact-pattern' flag.  A receptor can add other secrets it learns of, such as a token it exchanged the
+// credentials for.
+var DefaultRedactor = NewRedactor(DefaultRedactionPatterns...)
+
+// Redactor replaces secret values and text matching secret patterns with [Redacted].  A Redactor is safe for
+// concurrent use.
+type Redactor struct {
+	mu       sync.RWMutex
+	secrets  map[string]bool
+	patterns []*regexp.Regexp
+	replacer *strings.Replacer // replaces secrets, rebuilt when a secret is added
+}
+
+// NewRedactor returns a [Redactor] scrubbing the given patterns.  NewRedactor panics if a pattern isn't a valid
+// regular expression, so it's intended for patterns known at compile time.  Use [Redactor.AddPattern] otherwise.
+func NewRedactor(patterns ...string) *Redactor {
+	r := &Redactor{secrets: map[string]bool{}}
+	for _, pattern := range patterns {
+		if err := r.AddPattern(pattern); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// AddSecret adds a secret value to scrub.  Values shorter than [MinSecretLength] are ignored.  The value is also
+// scrubbed in its JSON string encoded form, as found in log lines.
+func (r *Redactor) AddSecret(value string) {
+	if len(value) < MinSecretLength {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secrets[value] = true
+	if encoded, err := json.Marshal(value); err == nil {
+		r.secrets[string(encoded[1:len(encoded)-1])] = true
+	}
+	r.replacer = nil
+}
+
+// AddPattern adds a regular expression to scrub.  If the expression has a capture group, only the text matching
+// the first group is scrubbed, so `token=(\w+)` scrubs the token but keeps 'token='.  Otherwise, the whole match is
+// scrubbed.  Adding a pattern already added is a no-op.
+func (r *Redactor) AddPattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.patterns {
+		if p.String() == pattern {
+			return nil
+		}
+	}
+	r.patterns = append(r.patterns, re)
+	return nil
+}
+
+// Redact returns s with its secrets scrubbed.
+func (r *Redactor) Redact(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	r.mu.RLock()
+	replacer, patterns := r.replacer, r.patterns
+	r.mu.RUnlock()
+	if replacer == nil {
+		replacer = r.buildReplacer()
+	}
+
+	s = replacer.Replace(s)
+	for _, re := range patterns {
+		s = redactPattern(re, s)
+	}
+	return s
+}
+
+// RedactSources returns copies of sources with their raw requests and responses scrubbed.
+func (r *Redactor) RedactSources(sources []*receptor_v1.Source) (redacted []*receptor_v1.Source) {
+	for _, source := range sources {
+		redacted = append(redacted, &receptor_v1.Source{
+			RawApiRequest:  r.Redact(source.RawApiRequest),
+			RawApiResponse: r.Redact(source.RawApiResponse),
+		})
+	}
+	return
+}
+
+// Writer returns a writer scrubbing each write before writing it to w.  Secrets split across writes aren't
+// scrubbed, so w should be written a line at a time, as a logger does.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &redactingWriter{redactor: r, w: w}
+}
+
+// buildReplacer builds the replacer of the redactor's secrets, longest secret first so a secret containing another
+// is scrubbed whole.
+func (r *Redactor) buildReplacer() *strings.Replacer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.replacer != nil {
+		return r.replacer
+	}
+	secrets := make([]string, 0, len(r.secrets))
+	for secret := range r.secrets {
+		secrets = append(secrets, secret)
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		if len(secrets[i]) != len(secrets[j]) {
+			return len(secrets[i]) > len(secrets[j])
+		}
+		return secrets[i] < secrets[j]
+	})
+	oldnew := make([]string, 0, 2*len(secrets))
+	for _, secret := range secrets {
+		oldnew = append(oldnew, secret, Redacted)
+	}
+	r.replacer = strings.NewReplacer(oldnew...)
+	return r.replacer
+}
+
+// redactPattern scrubs the text matching re in s, or the text matching its first capture group if it has one.
+func redactPattern(re *regexp.Regexp, s string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) > 2 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(Redacted)
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+type redactingWriter struct {
+	redactor *Redactor
+	w        io.Writer
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.redactor.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
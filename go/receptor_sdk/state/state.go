@@ -0,0 +1,134 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+// Package state is a local, file-backed key/value store of the content hashes of reported evidence.  A scan looks up
+// the hash an evidence was last reported with to tell if the evidence changed since, so unchanged evidence isn't
+// reported or uploaded again.
+//
+// A store holds the state of one receptor in a JSON file, named after the receptor, in the state directory.  The
+// file is rewritten as a whole, through a temporary file renamed over it, when the store is saved.  A store isn't
+// safe to share between scans of the same receptor running at the same time.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Version is the state file format version written by this package.
+const Version = 1
+
+// Record is the state of a reported evidence.
+type Record struct {
+	Hash       string            `json:"hash"`                // Hash of the evidence content, including its documents.
+	Documents  map[string]string `json:"documents,omitempty"` // Documents maps the name of each document to its hash.
+	ReportedAt time.Time         `json:"reported_at"`         // ReportedAt is when the evidence was reported with Hash.
+}
+
+type stateFile struct {
+	Version  int                `json:"version"`
+	Receptor string             `json:"receptor"`
+	Records  map[string]*Record `json:"records"`
+}
+
+// Store is the evidence state of a receptor, keyed by evidence.  Store is safe for concurrent use.
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	state stateFile
+	dirty bool
+}
+
+// Open opens the store of the receptor identified by receptorId in dir, creating dir if needed.  The store is empty
+// if the receptor has no state yet.
+func Open(dir, receptorId string) (s *Store, err error) {
+	if len(receptorId) == 0 {
+		return nil, errors.New("a receptor id is required to open its state")
+	}
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	s = &Store{
+		path:  filepath.Join(dir, url.PathEscape(receptorId)+".json"),
+		state: stateFile{Version: Version, Receptor: receptorId, Records: map[string]*Record{}},
+	}
+
+	var data []byte
+	if data, err = os.ReadFile(s.path); os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var state stateFile
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode state %s: %v", s.path, err)
+	}
+	if state.Version > Version {
+		return nil, fmt.Errorf("unsupported state %s version %d", s.path, state.Version)
+	}
+	if state.Records != nil {
+		s.state.Records = state.Records
+	}
+	return
+}
+
+// Path returns the path of the store's file.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Get returns the record of an evidence, and false if the evidence has no record.
+func (s *Store) Get(key string) (record Record, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var r *Record
+	if r, ok = s.state.Records[key]; ok {
+		record = *r
+	}
+	return
+}
+
+// Put sets the record of an evidence.
+func (s *Store) Put(key string, record Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Records[key] = &record
+	s.dirty = true
+}
+
+// Save writes the store to its file if it changed since it was opened or last saved.
+func (s *Store) Save() (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return
+	}
+
+	var data []byte
+	if data, err = json.MarshalIndent(s.state, "", "  "); err != nil {
+		return
+	}
+	var file *os.File
+	if file, err = os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp"); err != nil {
+		return
+	}
+	defer os.Remove(file.Name()) // fails once renamed
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return
+	}
+	if err = file.Close(); err != nil {
+		return
+	}
+	if err = os.Rename(file.Name(), s.path); err != nil {
+		return
+	}
+	s.dirty = false
+	return
+}
@@ -0,0 +1,71 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	store, err := Open(dir, "gitlab/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(store.Path()) != "gitlab%2F42.json" {
+		t.Errorf("Path() = %s, want the receptor id escaped", store.Path())
+	}
+	if _, ok := store.Get("users"); ok {
+		t.Error("Get() of a new store found a record")
+	}
+	// an unchanged store isn't written
+	if err = store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(store.Path()); !os.IsNotExist(err) {
+		t.Errorf("Save() of an unchanged store wrote %s", store.Path())
+	}
+
+	reported := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	store.Put("users", Record{Hash: "h1", Documents: map[string]string{"policy.txt": "d1"}, ReportedAt: reported})
+	if err = store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(dir, "gitlab/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, ok := reopened.Get("users")
+	if !ok || record.Hash != "h1" || record.Documents["policy.txt"] != "d1" || !record.ReportedAt.Equal(reported) {
+		t.Errorf("Get() after reopening = %v, %v, want the saved record", record, ok)
+	}
+	if other, err := Open(dir, "github"); err != nil {
+		t.Fatal(err)
+	} else if _, ok = other.Get("users"); ok {
+		t.Error("another receptor's store has the record")
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Open(dir, ""); err == nil {
+		t.Error("Open() without a receptor id succeeded")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bad.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir, "bad"); err == nil {
+		t.Error("Open() of a corrupt state succeeded")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "future.json"), []byte(`{"version": 99}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir, "future"); err == nil {
+		t.Error("Open() of a newer state version succeeded")
+	}
+}
@@ -0,0 +1,435 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package receptor_sdk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trustero/api/go/receptor_v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ColumnType is the type of the values of a [Column].
+type ColumnType int
+
+const (
+	StringColumn     ColumnType = iota // Values are strings.
+	IntColumn                          // Values are 64-bit signed integers.
+	FloatColumn                        // Values are floating point numbers.
+	BoolColumn                         // Values are booleans.
+	TimestampColumn                    // Values are timestamps.
+	StringListColumn                   // Values are lists of strings.
+)
+
+// Column defines a column of a [StructBuilder], the runtime equivalent of an evidence row struct field and its
+// 'trustero' tag.
+type Column struct {
+	Name      string     // Name of the column, the key of its values in the rows added to the builder.
+	Display   string     // Human-readable name of the column, like the 'display' sub-tag.  Empty means Name.
+	Order     int        // Display order starting with 1, like the 'order' sub-tag.  Zero means not displayed.
+	Id        bool       // If true, the column is the unique identifier of the rows, like the 'id' sub-tag.
+	Check     string     // Control test of the column, like the 'check' sub-tag.
+	Type      ColumnType // Type of the column's values.
+	Layout    string     // Layout of a timestamp column's string values.  Empty means time.RFC3339.
+	Separator string     // Separator of a string list column's string values.  Empty means ",".
+}
+
+// StructBuilder builds the [receptor_v1.Struct] of a structured evidence from columns defined at run time, for
+// sources such as CSV exports or JSON arrays whose columns aren't known at compile time.  Rows are added from maps,
+// CSV records or JSON arrays of objects, and values are converted to their column's type.  The resulting struct
+// has the same wire format as the struct of an evidence whose rows are tagged Golang structs.
+//
+// For example:
+//
+//	builder, err := receptor_sdk.NewStructBuilder(
+//	    receptor_sdk.Column{Name: "user", Display: "User Name", Order: 1, Id: true},
+//	    receptor_sdk.Column{Name: "admin", Display: "Admin", Order: 2, Type: receptor_sdk.BoolColumn},
+//	)
+//	...
+//	if err = builder.ReadCSV(csv.NewReader(export)); err != nil {
+//	    ...
+//	}
+//	evidence := builder.Evidence("GitLab", "user", "GitLab Users", "List of GitLab users")
+type StructBuilder struct {
+	columns []Column
+	index   map[string]int // column name to column index
+	id      int            // index of the id column, or -1
+	rows    []*receptor_v1.Row
+}
+
+// NewStructBuilder returns a [StructBuilder] of the given columns.  NewStructBuilder returns an error if a column
+// has no name, if two columns have the same name or the same order, or if more than one column is the id column.
+func NewStructBuilder(columns ...Column) (*StructBuilder, error) {
+	b := &StructBuilder{columns: append([]Column(nil), columns...), index: map[string]int{}, id: -1}
+	orders := map[int]string{}
+	for i, column := range columns {
+		switch {
+		case len(column.Name) == 0:
+			return nil, fmt.Errorf("column %d has no name", i)
+		case column.Order < 0:
+			return nil, fmt.Errorf("column %s order %d must start at 1", column.Name, column.Order)
+		case column.Type < StringColumn || column.Type > StringListColumn:
+			return nil, fmt.Errorf("column %s has an unknown type %d", column.Name, column.Type)
+		}
+		if _, ok := b.index[column.Name]; ok {
+			return nil, fmt.Errorf("column name %s is used by more than one column", column.Name)
+		}
+		b.index[column.Name] = i
+		if other, ok := orders[column.Order]; ok && column.Order > 0 {
+			return nil, fmt.Errorf("column %s order %d is the same as column %s's", column.Name, column.Order, other)
+		}
+		orders[column.Order] = column.Name
+		if column.Id {
+			if b.id >= 0 {
+				return nil, fmt.Errorf("columns %s and %s are both id columns", columns[b.id].Name, column.Name)
+			}
+			b.id = i
+		}
+	}
+	return b, nil
+}
+
+// AddMap adds a row of values keyed by column name.  Keys that aren't column names are ignored, and columns
+// missing from the row are given an empty value.
+func (b *StructBuilder) AddMap(row map[string]interface{}) error {
+	values := make([]interface{}, len(b.columns))
+	for name, value := range row {
+		if i, ok := b.index[name]; ok {
+			values[i] = value
+		}
+	}
+	return b.addRow(values)
+}
+
+// AddMaps adds rows of values keyed by column name, as in [StructBuilder.AddMap].
+func (b *StructBuilder) AddMaps(rows []map[string]interface{}) error {
+	for _, row := range rows {
+		if err := b.AddMap(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadCSV adds the rows of CSV records.  The first record is the header naming the columns of the values of each
+// record that follows.  Header names that aren't column names are ignored, and columns missing from the header are
+// given an empty value.  An empty CSV value is an empty string or an empty string list in a string or string list
+// column, and an empty value in a column of another type.
+func (b *StructBuilder) ReadCSV(r *csv.Reader) error {
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	indexes := make([]int, len(header)) // column index of each CSV field, or -1
+	for i, name := range header {
+		indexes[i] = -1
+		if j, ok := b.index[strings.TrimSpace(name)]; ok {
+			indexes[i] = j
+		}
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		values := make([]interface{}, len(b.columns))
+		for i, field := range record {
+			if i >= len(indexes) || indexes[i] < 0 {
+				continue
+			}
+			if column := b.columns[indexes[i]]; len(field) > 0 || column.Type == StringColumn || column.Type == StringListColumn {
+				values[indexes[i]] = field
+			}
+		}
+		if err = b.addRow(values); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadJSON adds the rows of a JSON array of objects, as in [StructBuilder.AddMap].  The array is decoded one
+// object at a time, so large arrays can be streamed.
+func (b *StructBuilder) ReadJSON(r io.Reader) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	if token, err := decoder.Token(); err != nil {
+		return err
+	} else if token != json.Delim('[') {
+		return fmt.Errorf("expected a JSON array of objects, got %v", token)
+	}
+	for decoder.More() {
+		var row map[string]interface{}
+		if err := decoder.Decode(&row); err != nil {
+			return fmt.Errorf("row %d: %w", len(b.rows), err)
+		}
+		if err := b.AddMap(row); err != nil {
+			return err
+		}
+	}
+	_, err := decoder.Token()
+	return err
+}
+
+// Struct returns the struct of the rows added so far.
+func (b *StructBuilder) Struct() *receptor_v1.Struct {
+	s := &receptor_v1.Struct{
+		Rows:            append([]*receptor_v1.Row{}, b.rows...),
+		ColDisplayNames: map[string]string{},
+		ColDisplayOrder: []string{},
+		ColTags:         map[string]string{},
+	}
+	var ordered []Column
+	for _, column := range b.columns {
+		s.ColDisplayNames[column.Name] = column.Name
+		if len(column.Display) > 0 {
+			s.ColDisplayNames[column.Name] = column.Display
+		}
+		if len(column.Check) > 0 {
+			s.ColTags[column.Check] = column.Name
+		}
+		if column.Order > 0 {
+			ordered = append(ordered, column)
+		}
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Order < ordered[j].Order })
+	for _, column := range ordered {
+		s.ColDisplayOrder = append(s.ColDisplayOrder, column.Name)
+	}
+	return s
+}
+
+// Columns returns the columns of the builder.
+func (b *StructBuilder) Columns() []Column {
+	return append([]Column(nil), b.columns...)
+}
+
+// Evidence returns a structured evidence of the rows added so far, with the builder's columns.
+func (b *StructBuilder) Evidence(serviceName, entityType, caption, description string) *Evidence {
+	ev := NewEvidence(serviceName, entityType, caption, description)
+	ev.Struct = b.Struct()
+	ev.Columns = b.Columns()
+	return ev
+}
+
+// addRow adds a row of values ordered as the builder's columns.
+func (b *StructBuilder) addRow(values []interface{}) error {
+	row := &receptor_v1.Row{Cols: make(map[string]*receptor_v1.Value, len(b.columns))}
+	for i, column := range b.columns {
+		value, err := column.value(values[i])
+		if err != nil {
+			return fmt.Errorf("row %d column %s: %w", len(b.rows), column.Name, err)
+		}
+		row.Cols[column.Name] = value
+		if i == b.id {
+			row.EntityInstanceId = idString(value)
+		}
+	}
+	b.rows = append(b.rows, row)
+	return nil
+}
+
+// value converts a row value to the column's type.  A nil value is an empty value.
+func (c Column) value(v interface{}) (*receptor_v1.Value, error) {
+	if v == nil {
+		return &receptor_v1.Value{}, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return &receptor_v1.Value{}, nil
+		}
+		v = rv.Elem().Interface()
+	}
+
+	switch c.Type {
+	case IntColumn:
+		i, err := toInt(v)
+		if err != nil {
+			return nil, err
+		}
+		return &receptor_v1.Value{ValueType: &receptor_v1.Value_Int64Value{Int64Value: i}}, nil
+	case FloatColumn:
+		f, err := toFloat(v)
+		if err != nil {
+			return nil, err
+		}
+		return &receptor_v1.Value{ValueType: &receptor_v1.Value_DoubleValue{DoubleValue: f}}, nil
+	case BoolColumn:
+		switch b := v.(type) {
+		case bool:
+			return &receptor_v1.Value{ValueType: &receptor_v1.Value_BoolValue{BoolValue: b}}, nil
+		case string:
+			parsed, err := strconv.ParseBool(strings.TrimSpace(b))
+			if err != nil {
+				return nil, err
+			}
+			return &receptor_v1.Value{ValueType: &receptor_v1.Value_BoolValue{BoolValue: parsed}}, nil
+		}
+	case TimestampColumn:
+		switch t := v.(type) {
+		case time.Time:
+			return &receptor_v1.Value{ValueType: &receptor_v1.Value_TimestampValue{TimestampValue: timestamppb.New(t)}}, nil
+		case string:
+			layout := c.Layout
+			if len(layout) == 0 {
+				layout = time.RFC3339
+			}
+			parsed, err := time.Parse(layout, strings.TrimSpace(t))
+			if err != nil {
+				return nil, err
+			}
+			return &receptor_v1.Value{ValueType: &receptor_v1.Value_TimestampValue{TimestampValue: timestamppb.New(parsed)}}, nil
+		}
+	case StringListColumn:
+		var values []string
+		switch l := v.(type) {
+		case []string:
+			values = append(values, l...)
+		case []interface{}:
+			for _, elem := range l {
+				s, err := toString(elem)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, s)
+			}
+		case string:
+			separator := c.Separator
+			if len(separator) == 0 {
+				separator = ","
+			}
+			for _, s := range strings.Split(l, separator) {
+				if s = strings.TrimSpace(s); len(s) > 0 {
+					values = append(values, s)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("%T isn't a list of strings", v)
+		}
+		return &receptor_v1.Value{ValueType: &receptor_v1.Value_StringListValue{StringListValue: &receptor_v1.StringList{Values: values}}}, nil
+	default:
+		s, err := toString(v)
+		if err != nil {
+			return nil, err
+		}
+		return &receptor_v1.Value{ValueType: &receptor_v1.Value_StringValue{StringValue: s}}, nil
+	}
+	return nil, fmt.Errorf("%T can't be converted to a %s", v, c.Type)
+}
+
+// String returns the name of a column type.
+func (t ColumnType) String() string {
+	switch t {
+	case StringColumn:
+		return "string"
+	case IntColumn:
+		return "integer"
+	case FloatColumn:
+		return "float"
+	case BoolColumn:
+		return "boolean"
+	case TimestampColumn:
+		return "timestamp"
+	case StringListColumn:
+		return "string list"
+	}
+	return "ColumnType(" + strconv.Itoa(int(t)) + ")"
+}
+
+var errNotInteger = errors.New("value isn't an integer")
+
+func toInt(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case json.Number:
+		return n.Int64()
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+	case float32, float64:
+		f := reflect.ValueOf(n).Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, errNotInteger
+		}
+		return int64(f), nil
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, errNotInteger
+		}
+		return int64(rv.Uint()), nil
+	}
+	return 0, fmt.Errorf("%T can't be converted to an integer", v)
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(n), 64)
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	}
+	return 0, fmt.Errorf("%T can't be converted to a float", v)
+}
+
+// toString formats a scalar value as a string.
+func toString(v interface{}) (string, error) {
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case fmt.Stringer:
+		return s.String(), nil
+	case time.Time:
+		return s.Format(time.RFC3339), nil
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Bool, reflect.Float32, reflect.Float64, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("%T can't be converted to a string", v)
+}
+
+// idString returns the entity instance id of an id column value.
+func idString(value *receptor_v1.Value) string {
+	switch v := value.GetValueType().(type) {
+	case *receptor_v1.Value_StringValue:
+		return v.StringValue
+	case *receptor_v1.Value_Int64Value:
+		return strconv.FormatInt(v.Int64Value, 10)
+	case *receptor_v1.Value_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64)
+	case *receptor_v1.Value_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *receptor_v1.Value_TimestampValue:
+		return v.TimestampValue.AsTime().Format(time.RFC3339Nano)
+	case *receptor_v1.Value_StringListValue:
+		return strings.Join(v.StringListValue.GetValues(), ",")
+	}
+	return ""
+}
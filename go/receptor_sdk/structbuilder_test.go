@@ -0,0 +1,113 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package receptor_sdk
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStructBuilder(t *testing.T) *StructBuilder {
+	t.Helper()
+	builder, err := NewStructBuilder(
+		Column{Name: "user", Display: "User Name", Order: 1, Id: true},
+		Column{Name: "admin", Display: "Admin", Order: 3, Type: BoolColumn, Check: "admin"},
+		Column{Name: "logins", Order: 2, Type: IntColumn},
+		Column{Name: "created", Type: TimestampColumn, Layout: "2006-01-02"},
+		Column{Name: "groups", Type: StringListColumn, Separator: ";"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return builder
+}
+
+func TestStructBuilder(t *testing.T) {
+	builder := newTestStructBuilder(t)
+	if err := builder.AddMap(map[string]interface{}{
+		"user": "alice", "admin": true, "logins": 3, "created": time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		"groups": []string{"a", "b"}, "ignored": "x",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.ReadCSV(csv.NewReader(strings.NewReader("user,admin,logins,created,groups\nbob,false,,2024-01-03,a; c\n"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.ReadJSON(strings.NewReader(`[{"user": "carol", "admin": "true", "logins": 7, "groups": ["d"]}]`)); err != nil {
+		t.Fatal(err)
+	}
+
+	s := builder.Struct()
+	if got := strings.Join(s.GetColDisplayOrder(), ","); got != "user,logins,admin" {
+		t.Errorf("ColDisplayOrder = %s, want user,logins,admin", got)
+	}
+	if s.GetColDisplayNames()["user"] != "User Name" || s.GetColDisplayNames()["logins"] != "logins" {
+		t.Errorf("ColDisplayNames = %v", s.GetColDisplayNames())
+	}
+	if s.GetColTags()["admin"] != "admin" {
+		t.Errorf("ColTags = %v, want the admin check", s.GetColTags())
+	}
+	if len(s.GetRows()) != 3 {
+		t.Fatalf("rows = %d, want 3", len(s.GetRows()))
+	}
+
+	alice, bob, carol := s.GetRows()[0], s.GetRows()[1], s.GetRows()[2]
+	if alice.GetEntityInstanceId() != "alice" || !alice.GetCols()["admin"].GetBoolValue() ||
+		alice.GetCols()["logins"].GetInt64Value() != 3 || len(alice.GetCols()["groups"].GetStringListValue().GetValues()) != 2 {
+		t.Errorf("alice = %v", alice)
+	}
+	if bob.GetCols()["logins"].GetValueType() != nil {
+		t.Errorf("bob's empty CSV logins = %v, want an empty value", bob.GetCols()["logins"])
+	}
+	if got := bob.GetCols()["created"].GetTimestampValue().AsTime(); !got.Equal(time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("bob's created = %v, want 2024-01-03", got)
+	}
+	if got := strings.Join(bob.GetCols()["groups"].GetStringListValue().GetValues(), ","); got != "a,c" {
+		t.Errorf("bob's groups = %s, want a,c", got)
+	}
+	if !carol.GetCols()["admin"].GetBoolValue() || carol.GetCols()["logins"].GetInt64Value() != 7 {
+		t.Errorf("carol = %v", carol)
+	}
+
+	evidence := builder.Evidence("GitLab", "user", "Users", "GitLab users")
+	if evidence.Caption != "Users" || len(evidence.Struct.GetRows()) != 3 {
+		t.Errorf("Evidence() = %v", evidence)
+	}
+}
+
+func TestStructBuilderErrors(t *testing.T) {
+	for _, columns := range [][]Column{
+		{{Name: ""}},
+		{{Name: "a"}, {Name: "a"}},
+		{{Name: "a", Order: 1}, {Name: "b", Order: 1}},
+		{{Name: "a", Id: true}, {Name: "b", Id: true}},
+		{{Name: "a", Order: -1}},
+		{{Name: "a", Type: ColumnType(42)}},
+	} {
+		if _, err := NewStructBuilder(columns...); err == nil {
+			t.Errorf("NewStructBuilder(%v) succeeded, want an error", columns)
+		}
+	}
+
+	builder := newTestStructBuilder(t)
+	for _, row := range []map[string]interface{}{
+		{"logins": "many"},
+		{"logins": 1.5},
+		{"admin": "maybe"},
+		{"created": "yesterday"},
+		{"groups": 42},
+	} {
+		if err := builder.AddMap(row); err == nil {
+			t.Errorf("AddMap(%v) succeeded, want an error", row)
+		}
+	}
+	if err := builder.ReadJSON(strings.NewReader(`{"user": "alice"}`)); err == nil {
+		t.Error("ReadJSON of an object succeeded, want an error")
+	}
+	if rows := builder.Struct().GetRows(); len(rows) != 0 {
+		t.Errorf("rows = %v, want no rows added by failed calls", rows)
+	}
+}
@@ -0,0 +1,261 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package receptor_sdk
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/rs/zerolog/log"
+	"github.com/trustero/api/go/receptor_v1"
+)
+
+// Validator is implemented by credential and config types that check their own content.  The CLI framework calls
+// Validate after decoding credentials and config and before invoking any receptor method.
+type Validator interface {
+	Validate() error
+}
+
+// TypedReceptor is a type-safe alternative to the [Receptor] interface.  Credentials are decoded into a *C and
+// configuration into a *K by the CLI framework, so implementations never need to type-assert an interface{}.  C
+// follows the same 'trustero' field tag rules as the credential struct returned by [Receptor.GetCredentialObj].
+// Use struct{} for K if the receptor has no configuration.  Either type may implement [Validator].
+//
+// A TypedReceptor is passed to the CLI framework through a [TypedAdapter]:
+//
+//	cmd.Execute(receptor_sdk.NewTypedAdapter[Credentials, Config](&Receptor{}))
+type TypedReceptor[C, K any] interface {
+	// GetReceptorType is the same as [Receptor.GetReceptorType].
+	GetReceptorType() (receptorType string)
+
+	// GetKnownServices is the same as [Receptor.GetKnownServices].
+	GetKnownServices() (serviceNames []string)
+
+	// GetConfigObjDesc is the same as [Receptor.GetConfigObjDesc].
+	GetConfigObjDesc() (configObjDesc interface{})
+
+	// GetAuthMethods is the same as [Receptor.GetAuthMethods].
+	GetAuthMethods() (authMethods interface{})
+
+	// GetEvidenceInfo is the typed equivalent of [Receptor.GetEvidenceInfo].  Credentials may be nil.
+	GetEvidenceInfo(credentials *C) (evidences []*Evidence)
+
+	// Verify is the typed equivalent of [ContextReceptor.VerifyContext].
+	Verify(ctx context.Context, credentials *C, config *K) (ok bool, err error)
+
+	// Discover is the typed equivalent of [ContextReceptor.DiscoverContext].
+	Discover(ctx context.Context, credentials *C, config *K) (services []*receptor_v1.ServiceEntity, err error)
+
+	// Report is the typed equivalent of [ContextReceptor.ReportContext].
+	Report(ctx context.Context, credentials *C, config *K) (evidences []*Evidence, err error)
+
+	// ReportBatch is the typed equivalent of [ContextReceptor.ReportBatchContext].  The implementation must
+	// close evidenceChan when done.
+	ReportBatch(ctx context.Context, credentials *C, config *K, evidenceChan chan []*Evidence)
+
+	// Configure is the typed equivalent of [Receptor.Configure].
+	Configure(credentials *C) (config *receptor_v1.ReceptorConfiguration, err error)
+
+	// GetLogo is the same as [Receptor.GetLogo].
+	GetLogo() (logo string, err error)
+
+	// GetInstructions is the same as [Receptor.GetInstructions].
+	GetInstructions() (instructions string, err error)
+}
+
+// TypedBatchReceptor is an optional extension of the [TypedReceptor] interface.  When a typed receptor implements
+// TypedBatchReceptor, the CLI framework invokes ReportBatches in place of [TypedReceptor.ReportBatch].
+type TypedBatchReceptor[C, K any] interface {
+	// ReportBatches is the typed equivalent of [BatchReceptor.ReportBatches].
+	ReportBatches(ctx context.Context, credentials *C, config *K, emitter Emitter) error
+}
+
+// TypedAdapter adapts a [TypedReceptor] to the [ContextReceptor] interface expected by the CLI framework.  The
+// adapter owns the *C and *K instances the CLI framework decodes credentials and configuration into.
+type TypedAdapter[C, K any] struct {
+	receptor    TypedReceptor[C, K]
+	credentials *C
+	config      *K
+	noConfig    bool
+}
+
+// NewTypedAdapter returns a [TypedAdapter] for the given [TypedReceptor].
+func NewTypedAdapter[C, K any](r TypedReceptor[C, K]) *TypedAdapter[C, K] {
+	configType := reflect.TypeOf((*K)(nil)).Elem()
+	return &TypedAdapter[C, K]{
+		receptor:    r,
+		credentials: new(C),
+		config:      new(K),
+		noConfig:    configType.Kind() == reflect.Struct && configType.NumField() == 0,
+	}
+}
+
+// Credentials returns the adapter's credential instance.
+func (a *TypedAdapter[C, K]) Credentials() *C {
+	return a.credentials
+}
+
+// Config returns the adapter's configuration instance.
+func (a *TypedAdapter[C, K]) Config() *K {
+	return a.config
+}
+
+// GetReceptorType implements [Receptor.GetReceptorType].
+func (a *TypedAdapter[C, K]) GetReceptorType() (receptorType string) {
+	return a.receptor.GetReceptorType()
+}
+
+// GetKnownServices implements [Receptor.GetKnownServices].
+func (a *TypedAdapter[C, K]) GetKnownServices() (serviceNames []string) {
+	return a.receptor.GetKnownServices()
+}
+
+// GetCredentialObj implements [Receptor.GetCredentialObj].  It always returns the same *C instance.
+func (a *TypedAdapter[C, K]) GetCredentialObj() (credentialObj interface{}) {
+	return a.credentials
+}
+
+// GetConfigObj implements [Receptor.GetConfigObj].  It always returns the same *K instance, or nil if K is an
+// empty struct.
+func (a *TypedAdapter[C, K]) GetConfigObj(_ interface{}) (configObj interface{}) {
+	if a.noConfig {
+		return nil
+	}
+	return a.config
+}
+
+// GetConfigObjDesc implements [Receptor.GetConfigObjDesc].
+func (a *TypedAdapter[C, K]) GetConfigObjDesc() (configObjDesc interface{}) {
+	return a.receptor.GetConfigObjDesc()
+}
+
+// GetAuthMethods implements [Receptor.GetAuthMethods].
+func (a *TypedAdapter[C, K]) GetAuthMethods() (authMethods interface{}) {
+	return a.receptor.GetAuthMethods()
+}
+
+// GetEvidenceInfo implements [Receptor.GetEvidenceInfo].
+func (a *TypedAdapter[C, K]) GetEvidenceInfo(credentials interface{}) (evidences []*Evidence) {
+	c, _ := credentials.(*C)
+	return a.receptor.GetEvidenceInfo(c)
+}
+
+// Verify implements [Receptor.Verify].
+func (a *TypedAdapter[C, K]) Verify(credentials interface{}, config interface{}) (ok bool, err error) {
+	return a.VerifyContext(context.Background(), credentials, config)
+}
+
+// Discover implements [Receptor.Discover].
+func (a *TypedAdapter[C, K]) Discover(credentials interface{}, config interface{}) (services []*receptor_v1.ServiceEntity, err error) {
+	return a.DiscoverContext(context.Background(), credentials, config)
+}
+
+// Report implements [Receptor.Report].
+func (a *TypedAdapter[C, K]) Report(credentials interface{}, config interface{}) (evidences []*Evidence, err error) {
+	return a.ReportContext(context.Background(), credentials, config)
+}
+
+// ReportBatch implements [Receptor.ReportBatch].
+func (a *TypedAdapter[C, K]) ReportBatch(credentials interface{}, evidenceChan chan []*Evidence) {
+	a.ReportBatchContext(context.Background(), credentials, a.GetConfigObj(nil), evidenceChan)
+}
+
+// VerifyContext implements [ContextReceptor.VerifyContext].
+func (a *TypedAdapter[C, K]) VerifyContext(ctx context.Context, credentials interface{}, config interface{}) (ok bool, err error) {
+	var (
+		c *C
+		k *K
+	)
+	if c, k, err = a.typed(credentials, config); err != nil {
+		return
+	}
+	return a.receptor.Verify(ctx, c, k)
+}
+
+// DiscoverContext implements [ContextReceptor.DiscoverContext].
+func (a *TypedAdapter[C, K]) DiscoverContext(ctx context.Context, credentials interface{}, config interface{}) (services []*receptor_v1.ServiceEntity, err error) {
+	var (
+		c *C
+		k *K
+	)
+	if c, k, err = a.typed(credentials, config); err != nil {
+		return
+	}
+	return a.receptor.Discover(ctx, c, k)
+}
+
+// ReportContext implements [ContextReceptor.ReportContext].
+func (a *TypedAdapter[C, K]) ReportContext(ctx context.Context, credentials interface{}, config interface{}) (evidences []*Evidence, err error) {
+	var (
+		c *C
+		k *K
+	)
+	if c, k, err = a.typed(credentials, config); err != nil {
+		return
+	}
+	return a.receptor.Report(ctx, c, k)
+}
+
+// ReportBatchContext implements [ContextReceptor.ReportBatchContext].
+func (a *TypedAdapter[C, K]) ReportBatchContext(ctx context.Context, credentials interface{}, config interface{}, evidenceChan chan []*Evidence) {
+	c, k, err := a.typed(credentials, config)
+	if err != nil {
+		log.Err(err).Msg("failed to report evidence in batches")
+		close(evidenceChan)
+		return
+	}
+	a.receptor.ReportBatch(ctx, c, k, evidenceChan)
+}
+
+// ReportBatches implements [BatchReceptor.ReportBatches].  If the typed receptor doesn't implement
+// [TypedBatchReceptor], the batches sent by its ReportBatch are emitted with [EmitChannel].
+func (a *TypedAdapter[C, K]) ReportBatches(ctx context.Context, credentials interface{}, config interface{}, emitter Emitter) error {
+	c, k, err := a.typed(credentials, config)
+	if err != nil {
+		return err
+	}
+	if br, isBatch := a.receptor.(TypedBatchReceptor[C, K]); isBatch {
+		return br.ReportBatches(ctx, c, k, emitter)
+	}
+	return EmitChannel(ctx, emitter, func(evidenceChan chan []*Evidence) {
+		a.receptor.ReportBatch(ctx, c, k, evidenceChan)
+	})
+}
+
+// Configure implements [Receptor.Configure].
+func (a *TypedAdapter[C, K]) Configure(credentials interface{}) (config *receptor_v1.ReceptorConfiguration, err error) {
+	var c *C
+	if c, _, err = a.typed(credentials, a.config); err != nil {
+		return
+	}
+	return a.receptor.Configure(c)
+}
+
+// GetLogo implements [Receptor.GetLogo].
+func (a *TypedAdapter[C, K]) GetLogo() (logo string, err error) {
+	return a.receptor.GetLogo()
+}
+
+// GetInstructions implements [Receptor.GetInstructions].
+func (a *TypedAdapter[C, K]) GetInstructions() (instructions string, err error) {
+	return a.receptor.GetInstructions()
+}
+
+func (a *TypedAdapter[C, K]) typed(credentials interface{}, config interface{}) (c *C, k *K, err error) {
+	var ok bool
+	if c, ok = credentials.(*C); !ok || c == nil {
+		err = fmt.Errorf("expected credentials of type %T, got %T", a.credentials, credentials)
+		return
+	}
+
+	if k, ok = config.(*K); !ok || k == nil {
+		if config != nil && !a.noConfig {
+			err = fmt.Errorf("expected config of type %T, got %T", a.config, config)
+			return
+		}
+		k = a.config
+	}
+	return
+}
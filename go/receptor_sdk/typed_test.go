@@ -0,0 +1,115 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package receptor_sdk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/trustero/api/go/receptor_v1"
+)
+
+type typedTestCredentials struct{ Token string }
+
+type typedTestConfig struct{ Region string }
+
+// typedTestReceptor records the typed credentials and config of its last call.
+type typedTestReceptor[K any] struct {
+	credentials *typedTestCredentials
+	config      *K
+}
+
+func (r *typedTestReceptor[K]) GetReceptorType() string                             { return "trr-typed" }
+func (r *typedTestReceptor[K]) GetKnownServices() []string                          { return nil }
+func (r *typedTestReceptor[K]) GetConfigObjDesc() interface{}                       { return nil }
+func (r *typedTestReceptor[K]) GetAuthMethods() interface{}                         { return nil }
+func (r *typedTestReceptor[K]) GetLogo() (string, error)                            { return "", nil }
+func (r *typedTestReceptor[K]) GetInstructions() (string, error)                    { return "", nil }
+func (r *typedTestReceptor[K]) GetEvidenceInfo(_ *typedTestCredentials) []*Evidence { return nil }
+
+func (r *typedTestReceptor[K]) Verify(_ context.Context, c *typedTestCredentials, k *K) (bool, error) {
+	r.credentials, r.config = c, k
+	return true, nil
+}
+
+func (r *typedTestReceptor[K]) Discover(_ context.Context, c *typedTestCredentials, k *K) ([]*receptor_v1.ServiceEntity, error) {
+	r.credentials, r.config = c, k
+	return nil, nil
+}
+
+func (r *typedTestReceptor[K]) Report(_ context.Context, c *typedTestCredentials, k *K) ([]*Evidence, error) {
+	r.credentials, r.config = c, k
+	return nil, nil
+}
+
+func (r *typedTestReceptor[K]) ReportBatch(_ context.Context, c *typedTestCredentials, k *K, evidenceChan chan []*Evidence) {
+	r.credentials, r.config = c, k
+	close(evidenceChan)
+}
+
+func (r *typedTestReceptor[K]) Configure(c *typedTestCredentials) (*receptor_v1.ReceptorConfiguration, error) {
+	r.credentials = c
+	return &receptor_v1.ReceptorConfiguration{}, nil
+}
+
+func TestTypedAdapter(t *testing.T) {
+	r := &typedTestReceptor[typedTestConfig]{}
+	a := NewTypedAdapter[typedTestCredentials, typedTestConfig](r)
+	if a.GetCredentialObj() != a.Credentials() || a.GetConfigObj(nil) != a.Config() {
+		t.Error("the adapter's credential and config objects aren't its own instances")
+	}
+
+	credentials := &typedTestCredentials{Token: "token"}
+	config := &typedTestConfig{Region: "us-east-1"}
+	if _, err := a.Verify(credentials, config); err != nil {
+		t.Fatal(err)
+	}
+	if r.credentials != credentials || r.config != config {
+		t.Errorf("Verify passed %v, %v, want the given credentials and config", r.credentials, r.config)
+	}
+
+	// ReportBatch is given the config decoded by the CLI framework
+	evidenceChan := make(chan []*Evidence)
+	a.ReportBatchContext(context.Background(), credentials, config, evidenceChan)
+	if r.config != config {
+		t.Errorf("ReportBatchContext passed config %v, want %v", r.config, config)
+	}
+
+	// without a config, the adapter's instance is passed
+	if _, err := a.Discover(credentials, nil); err != nil {
+		t.Fatal(err)
+	}
+	if r.config != a.Config() {
+		t.Errorf("Discover without a config passed %v, want the adapter's instance", r.config)
+	}
+
+	for _, test := range []struct {
+		name        string
+		credentials interface{}
+		config      interface{}
+	}{
+		{"credentials of another type", &typedTestConfig{}, config},
+		{"nil credentials", (*typedTestCredentials)(nil), config},
+		{"config of another type", credentials, credentials},
+	} {
+		if _, err := a.Report(test.credentials, test.config); err == nil {
+			t.Errorf("Report with %s succeeded, want an error", test.name)
+		}
+	}
+}
+
+func TestTypedAdapterNoConfig(t *testing.T) {
+	r := &typedTestReceptor[struct{}]{}
+	a := NewTypedAdapter[typedTestCredentials, struct{}](r)
+	if config := a.GetConfigObj(nil); config != nil {
+		t.Errorf("GetConfigObj of a receptor without a config = %v, want nil", config)
+	}
+	// a receptor without a config accepts any config, such as one decoded into a map
+	if _, err := a.Report(&typedTestCredentials{}, map[string]interface{}{}); err != nil {
+		t.Error(err)
+	}
+	if r.config != a.Config() {
+		t.Errorf("Report passed config %v, want the adapter's instance", r.config)
+	}
+}
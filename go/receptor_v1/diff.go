@@ -0,0 +1,95 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package receptor_v1
+
+import (
+	"google.golang.org/protobuf/proto"
+)
+
+// StructDiff is the difference between an old and a new version of a Struct.  Rows are matched by their
+// EntityInstanceId.  Rows sharing an EntityInstanceId, including rows without one, are matched in the order they
+// appear in each version.
+type StructDiff struct {
+	Columns   []Column   // Columns of the new version, followed by the columns only in the old version.
+	Added     []*Row     // Rows only in the new version.
+	Removed   []*Row     // Rows only in the old version.
+	Modified  []*RowDiff // Rows in both versions with different values.
+	Unchanged int        // Number of rows in both versions with the same values.
+}
+
+// RowDiff is the difference between the old and the new version of a row.
+type RowDiff struct {
+	EntityInstanceId string
+	Changes          []*CellChange // Changed columns, in the order of the diff's columns.
+}
+
+// CellChange is a changed column of a row.  Old or New is nil when the column isn't in that version of the row.
+type CellChange struct {
+	Column Column
+	Old    *Value
+	New    *Value
+}
+
+// DiffStructs compares an old and a new version of a Struct.  A nil Struct has no rows.
+func DiffStructs(old, new *Struct) *StructDiff {
+	d := &StructDiff{Columns: new.NewTable(TableOptions{}).Columns}
+	seen := map[string]bool{}
+	for _, column := range d.Columns {
+		seen[column.Key] = true
+	}
+	for _, column := range old.NewTable(TableOptions{}).Columns {
+		if !seen[column.Key] {
+			seen[column.Key] = true
+			d.Columns = append(d.Columns, column)
+		}
+	}
+
+	// index the old rows by their id, in order of appearance
+	oldRows := map[string][]*Row{}
+	for _, row := range old.GetRows() {
+		oldRows[row.GetEntityInstanceId()] = append(oldRows[row.GetEntityInstanceId()], row)
+	}
+
+	for _, row := range new.GetRows() {
+		id := row.GetEntityInstanceId()
+		matches := oldRows[id]
+		if len(matches) == 0 {
+			d.Added = append(d.Added, row)
+			continue
+		}
+		oldRow := matches[0]
+		oldRows[id] = matches[1:]
+
+		if changes := d.changes(oldRow, row); len(changes) > 0 {
+			d.Modified = append(d.Modified, &RowDiff{EntityInstanceId: id, Changes: changes})
+		} else {
+			d.Unchanged++
+		}
+	}
+
+	// old rows left unmatched were removed, reported in the order of the old version
+	for _, row := range old.GetRows() {
+		id := row.GetEntityInstanceId()
+		if remaining := oldRows[id]; len(remaining) > 0 && remaining[0] == row {
+			d.Removed = append(d.Removed, row)
+			oldRows[id] = remaining[1:]
+		}
+	}
+	return d
+}
+
+// Empty tells if the old and the new version have the same rows.
+func (d *StructDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+func (d *StructDiff) changes(old, new *Row) (changes []*CellChange) {
+	for _, column := range d.Columns {
+		oldValue, newValue := old.GetCols()[column.Key], new.GetCols()[column.Key]
+		if !proto.Equal(oldValue, newValue) {
+			changes = append(changes, &CellChange{Column: column, Old: oldValue, New: newValue})
+		}
+	}
+	return
+}
@@ -12,6 +12,7 @@ package receptor_v1
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	wrapperspb "google.golang.org/protobuf/types/known/wrapperspb"
@@ -240,10 +241,14 @@ type Evidence struct {
 	RecordIds []string `protobuf:"bytes,15,rep,name=record_ids,json=recordIds,proto3" json:"record_ids,omitempty"`
 	// exceptions is a list of exceptions for the evidence object.
 	Exceptions string `protobuf:"bytes,16,opt,name=exceptions,proto3" json:"exceptions,omitempty"`
-	// // link to the evidence object in the external system.
-	EvidenceLink  string `protobuf:"bytes,17,opt,name=evidence_link,json=evidenceLink,proto3" json:"evidence_link,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	//// link to the evidence object in the external system.
+	EvidenceLink string `protobuf:"bytes,17,opt,name=evidence_link,json=evidenceLink,proto3" json:"evidence_link,omitempty"`
+	// Unchanged_since marks the evidence as unchanged since it was last reported, at the given time.  An unchanged
+	// evidence has no evidence_type or sources.  Trustero keeps the content last reported with the same evidence_key,
+	// or caption if evidence_key is empty.
+	UnchangedSince *timestamppb.Timestamp `protobuf:"bytes,20,opt,name=unchanged_since,json=unchangedSince,proto3" json:"unchanged_since,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *Evidence) Reset() {
@@ -422,6 +427,13 @@ func (x *Evidence) GetEvidenceLink() string {
 	return ""
 }
 
+func (x *Evidence) GetUnchangedSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UnchangedSince
+	}
+	return nil
+}
+
 type isEvidence_EvidenceType interface {
 	isEvidence_EvidenceType()
 }
@@ -1558,14 +1570,16 @@ type JobResult struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Tracer_id is used to track the progress of the receptor request.
 	TracerId string `protobuf:"bytes,1,opt,name=tracer_id,json=tracerId,proto3" json:"tracer_id,omitempty"`
-	// Command is the receptor request that completed.  One of "verify", "scan", or "discover"
+	// Command is the receptor request that completed.  One of "verify", "scan", "discover", or "upload".
 	Command string `protobuf:"bytes,2,opt,name=command,proto3" json:"command,omitempty"`
 	// Result is receptor request result.  One of "success", "fail", or "error".
 	Result string `protobuf:"bytes,3,opt,name=result,proto3" json:"result,omitempty"`
 	// Receptor_object_id is Trustero's receptor record identifier.
 	ReceptorObjectId string `protobuf:"bytes,4,opt,name=receptor_object_id,json=receptorObjectId,proto3" json:"receptor_object_id,omitempty"`
 	// Exceptions contain information about the error like permission missing for the credentials provided.
-	Exceptions    string `protobuf:"bytes,5,opt,name=exceptions,proto3" json:"exceptions,omitempty"`
+	Exceptions string `protobuf:"bytes,5,opt,name=exceptions,proto3" json:"exceptions,omitempty"`
+	// Summary summarizes what the receptor request discovered, reported and failed to report.
+	Summary       *RunSummary `protobuf:"bytes,6,opt,name=summary,proto3" json:"summary,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -1635,6 +1649,333 @@ func (x *JobResult) GetExceptions() string {
 	return ""
 }
 
+func (x *JobResult) GetSummary() *RunSummary {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+// RunSummary summarizes a receptor request.
+type RunSummary struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Command is the receptor request summarized.  One of "verify", "scan", "discover", "configure" or "upload".
+	Command string `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	// Started_at is when the receptor request started.
+	StartedAt *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	// Duration is how long the receptor request took.
+	Duration *durationpb.Duration `protobuf:"bytes,3,opt,name=duration,proto3" json:"duration,omitempty"`
+	// Phases lists the phases of the receptor request in the order they started.
+	Phases []*PhaseSummary `protobuf:"bytes,4,rep,name=phases,proto3" json:"phases,omitempty"`
+	// Services lists the number of service entities discovered per service.
+	Services []*ServiceSummary `protobuf:"bytes,5,rep,name=services,proto3" json:"services,omitempty"`
+	// Evidences lists the evidence reported per evidence caption.
+	Evidences     []*EvidenceSummary `protobuf:"bytes,6,rep,name=evidences,proto3" json:"evidences,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RunSummary) Reset() {
+	*x = RunSummary{}
+	mi := &file_receptor_v1_receptor_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunSummary) ProtoMessage() {}
+
+func (x *RunSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_receptor_v1_receptor_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunSummary.ProtoReflect.Descriptor instead.
+func (*RunSummary) Descriptor() ([]byte, []int) {
+	return file_receptor_v1_receptor_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *RunSummary) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *RunSummary) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *RunSummary) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *RunSummary) GetPhases() []*PhaseSummary {
+	if x != nil {
+		return x.Phases
+	}
+	return nil
+}
+
+func (x *RunSummary) GetServices() []*ServiceSummary {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+func (x *RunSummary) GetEvidences() []*EvidenceSummary {
+	if x != nil {
+		return x.Evidences
+	}
+	return nil
+}
+
+// PhaseSummary summarizes a phase of a receptor request, such as "verify", "discover" or "report".
+type PhaseSummary struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Name of the phase.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Duration is how long the phase took.
+	Duration *durationpb.Duration `protobuf:"bytes,2,opt,name=duration,proto3" json:"duration,omitempty"`
+	// Error is the error the phase failed with, if any.
+	Error         string `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PhaseSummary) Reset() {
+	*x = PhaseSummary{}
+	mi := &file_receptor_v1_receptor_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PhaseSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PhaseSummary) ProtoMessage() {}
+
+func (x *PhaseSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_receptor_v1_receptor_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PhaseSummary.ProtoReflect.Descriptor instead.
+func (*PhaseSummary) Descriptor() ([]byte, []int) {
+	return file_receptor_v1_receptor_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *PhaseSummary) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *PhaseSummary) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *PhaseSummary) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// ServiceSummary summarizes the service entities discovered in a service.
+type ServiceSummary struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Service_name is the name of the service.  For example, "S3".
+	ServiceName string `protobuf:"bytes,1,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	// Entities is the number of service entities discovered.
+	Entities      int32 `protobuf:"varint,2,opt,name=entities,proto3" json:"entities,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServiceSummary) Reset() {
+	*x = ServiceSummary{}
+	mi := &file_receptor_v1_receptor_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServiceSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceSummary) ProtoMessage() {}
+
+func (x *ServiceSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_receptor_v1_receptor_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceSummary.ProtoReflect.Descriptor instead.
+func (*ServiceSummary) Descriptor() ([]byte, []int) {
+	return file_receptor_v1_receptor_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ServiceSummary) GetServiceName() string {
+	if x != nil {
+		return x.ServiceName
+	}
+	return ""
+}
+
+func (x *ServiceSummary) GetEntities() int32 {
+	if x != nil {
+		return x.Entities
+	}
+	return 0
+}
+
+// EvidenceSummary summarizes the evidence reported with the same caption.
+type EvidenceSummary struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Caption identifies the evidence.
+	Caption string `protobuf:"bytes,1,opt,name=caption,proto3" json:"caption,omitempty"`
+	// Service_name is the name of the service the evidence was gathered from.
+	ServiceName string `protobuf:"bytes,2,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	// Evidences is the number of evidences reported with the caption.
+	Evidences int32 `protobuf:"varint,3,opt,name=evidences,proto3" json:"evidences,omitempty"`
+	// Rows is the number of rows of structured evidence.
+	Rows int32 `protobuf:"varint,4,opt,name=rows,proto3" json:"rows,omitempty"`
+	// Documents is the number of documents uploaded.
+	Documents int32 `protobuf:"varint,5,opt,name=documents,proto3" json:"documents,omitempty"`
+	// Document_bytes is the size in bytes of the documents uploaded.
+	DocumentBytes int64 `protobuf:"varint,6,opt,name=document_bytes,json=documentBytes,proto3" json:"document_bytes,omitempty"`
+	// Errors lists the errors evidence with the caption failed to be reported with.
+	Errors []string `protobuf:"bytes,7,rep,name=errors,proto3" json:"errors,omitempty"`
+	// Unchanged is the number of evidences found unchanged since they were last reported, and so reported as
+	// unchanged or skipped.
+	Unchanged     int32 `protobuf:"varint,8,opt,name=unchanged,proto3" json:"unchanged,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EvidenceSummary) Reset() {
+	*x = EvidenceSummary{}
+	mi := &file_receptor_v1_receptor_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EvidenceSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EvidenceSummary) ProtoMessage() {}
+
+func (x *EvidenceSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_receptor_v1_receptor_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EvidenceSummary.ProtoReflect.Descriptor instead.
+func (*EvidenceSummary) Descriptor() ([]byte, []int) {
+	return file_receptor_v1_receptor_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *EvidenceSummary) GetCaption() string {
+	if x != nil {
+		return x.Caption
+	}
+	return ""
+}
+
+func (x *EvidenceSummary) GetServiceName() string {
+	if x != nil {
+		return x.ServiceName
+	}
+	return ""
+}
+
+func (x *EvidenceSummary) GetEvidences() int32 {
+	if x != nil {
+		return x.Evidences
+	}
+	return 0
+}
+
+func (x *EvidenceSummary) GetRows() int32 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
+func (x *EvidenceSummary) GetDocuments() int32 {
+	if x != nil {
+		return x.Documents
+	}
+	return 0
+}
+
+func (x *EvidenceSummary) GetDocumentBytes() int64 {
+	if x != nil {
+		return x.DocumentBytes
+	}
+	return 0
+}
+
+func (x *EvidenceSummary) GetErrors() []string {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+func (x *EvidenceSummary) GetUnchanged() int32 {
+	if x != nil {
+		return x.Unchanged
+	}
+	return 0
+}
+
 type ReportChunk struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Content       []byte                 `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
@@ -1645,7 +1986,7 @@ type ReportChunk struct {
 
 func (x *ReportChunk) Reset() {
 	*x = ReportChunk{}
-	mi := &file_receptor_v1_receptor_proto_msgTypes[18]
+	mi := &file_receptor_v1_receptor_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1657,7 +1998,7 @@ func (x *ReportChunk) String() string {
 func (*ReportChunk) ProtoMessage() {}
 
 func (x *ReportChunk) ProtoReflect() protoreflect.Message {
-	mi := &file_receptor_v1_receptor_proto_msgTypes[18]
+	mi := &file_receptor_v1_receptor_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1670,7 +2011,7 @@ func (x *ReportChunk) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ReportChunk.ProtoReflect.Descriptor instead.
 func (*ReportChunk) Descriptor() ([]byte, []int) {
-	return file_receptor_v1_receptor_proto_rawDescGZIP(), []int{18}
+	return file_receptor_v1_receptor_proto_rawDescGZIP(), []int{22}
 }
 
 func (x *ReportChunk) GetContent() []byte {
@@ -1697,7 +2038,7 @@ type ReportResponse struct {
 
 func (x *ReportResponse) Reset() {
 	*x = ReportResponse{}
-	mi := &file_receptor_v1_receptor_proto_msgTypes[19]
+	mi := &file_receptor_v1_receptor_proto_msgTypes[23]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1709,7 +2050,7 @@ func (x *ReportResponse) String() string {
 func (*ReportResponse) ProtoMessage() {}
 
 func (x *ReportResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_receptor_v1_receptor_proto_msgTypes[19]
+	mi := &file_receptor_v1_receptor_proto_msgTypes[23]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1722,7 +2063,7 @@ func (x *ReportResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ReportResponse.ProtoReflect.Descriptor instead.
 func (*ReportResponse) Descriptor() ([]byte, []int) {
-	return file_receptor_v1_receptor_proto_rawDescGZIP(), []int{19}
+	return file_receptor_v1_receptor_proto_rawDescGZIP(), []int{23}
 }
 
 func (x *ReportResponse) GetStatus() string {
@@ -1736,13 +2077,13 @@ var File_receptor_v1_receptor_proto protoreflect.FileDescriptor
 
 const file_receptor_v1_receptor_proto_rawDesc = "" +
 	"\n" +
-	"\x1areceptor_v1/receptor.proto\x12\vreceptor_v1\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1egoogle/protobuf/wrappers.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf8\x01\n" +
+	"\x1areceptor_v1/receptor.proto\x12\vreceptor_v1\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1egoogle/protobuf/wrappers.proto\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf8\x01\n" +
 	"\aFinding\x12#\n" +
 	"\rreceptor_type\x18\x01 \x01(\tR\freceptorType\x128\n" +
 	"\x18service_provider_account\x18\x02 \x01(\tR\x16serviceProviderAccount\x126\n" +
 	"\bentities\x18\x03 \x03(\v2\x1a.receptor_v1.ServiceEntityR\bentities\x123\n" +
 	"\tevidences\x18\x04 \x03(\v2\x15.receptor_v1.EvidenceR\tevidences\x12!\n" +
-	"\fdiscovery_id\x18\x05 \x01(\tR\vdiscoveryId\"\xa8\x06\n" +
+	"\fdiscovery_id\x18\x05 \x01(\tR\vdiscoveryId\"\xed\x06\n" +
 	"\bEvidence\x12\x18\n" +
 	"\acaption\x18\x01 \x01(\tR\acaption\x12 \n" +
 	"\vdescription\x18\x02 \x01(\tR\vdescription\x12!\n" +
@@ -1767,7 +2108,8 @@ const file_receptor_v1_receptor_proto_rawDesc = "" +
 	"\n" +
 	"exceptions\x18\x10 \x01(\tR\n" +
 	"exceptions\x12#\n" +
-	"\revidence_link\x18\x11 \x01(\tR\fevidenceLinkB\x0f\n" +
+	"\revidence_link\x18\x11 \x01(\tR\fevidenceLink\x12C\n" +
+	"\x0funchanged_since\x18\x14 \x01(\v2\x1a.google.protobuf.TimestampR\x0eunchangedSinceB\x0f\n" +
 	"\revidence_type\"Z\n" +
 	"\x06Source\x12&\n" +
 	"\x0fraw_api_request\x18\x01 \x01(\tR\rrawApiRequest\x12(\n" +
@@ -1864,7 +2206,7 @@ const file_receptor_v1_receptor_proto_rawDesc = "" +
 	"credential\x12\x16\n" +
 	"\x06config\x18\x03 \x01(\tR\x06config\x128\n" +
 	"\x18service_provider_account\x18\x04 \x01(\tR\x16serviceProviderAccount\x12\x19\n" +
-	"\bmodel_id\x18\x05 \x01(\tR\amodelId\"\xa8\x01\n" +
+	"\bmodel_id\x18\x05 \x01(\tR\amodelId\"\xdb\x01\n" +
 	"\tJobResult\x12\x1b\n" +
 	"\ttracer_id\x18\x01 \x01(\tR\btracerId\x12\x18\n" +
 	"\acommand\x18\x02 \x01(\tR\acommand\x12\x16\n" +
@@ -1872,7 +2214,33 @@ const file_receptor_v1_receptor_proto_rawDesc = "" +
 	"\x12receptor_object_id\x18\x04 \x01(\tR\x10receptorObjectId\x12\x1e\n" +
 	"\n" +
 	"exceptions\x18\x05 \x01(\tR\n" +
-	"exceptions\"H\n" +
+	"exceptions\x121\n" +
+	"\asummary\x18\x06 \x01(\v2\x17.receptor_v1.RunSummaryR\asummary\"\xc0\x02\n" +
+	"\n" +
+	"RunSummary\x12\x18\n" +
+	"\acommand\x18\x01 \x01(\tR\acommand\x129\n" +
+	"\n" +
+	"started_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\tstartedAt\x125\n" +
+	"\bduration\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\bduration\x121\n" +
+	"\x06phases\x18\x04 \x03(\v2\x19.receptor_v1.PhaseSummaryR\x06phases\x127\n" +
+	"\bservices\x18\x05 \x03(\v2\x1b.receptor_v1.ServiceSummaryR\bservices\x12:\n" +
+	"\tevidences\x18\x06 \x03(\v2\x1c.receptor_v1.EvidenceSummaryR\tevidences\"o\n" +
+	"\fPhaseSummary\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x125\n" +
+	"\bduration\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\bduration\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"O\n" +
+	"\x0eServiceSummary\x12!\n" +
+	"\fservice_name\x18\x01 \x01(\tR\vserviceName\x12\x1a\n" +
+	"\bentities\x18\x02 \x01(\x05R\bentities\"\xfb\x01\n" +
+	"\x0fEvidenceSummary\x12\x18\n" +
+	"\acaption\x18\x01 \x01(\tR\acaption\x12!\n" +
+	"\fservice_name\x18\x02 \x01(\tR\vserviceName\x12\x1c\n" +
+	"\tevidences\x18\x03 \x01(\x05R\tevidences\x12\x12\n" +
+	"\x04rows\x18\x04 \x01(\x05R\x04rows\x12\x1c\n" +
+	"\tdocuments\x18\x05 \x01(\x05R\tdocuments\x12%\n" +
+	"\x0edocument_bytes\x18\x06 \x01(\x03R\rdocumentBytes\x12\x16\n" +
+	"\x06errors\x18\a \x03(\tR\x06errors\x12\x1c\n" +
+	"\tunchanged\x18\b \x01(\x05R\tunchanged\"H\n" +
 	"\vReportChunk\x12\x18\n" +
 	"\acontent\x18\x01 \x01(\fR\acontent\x12\x1f\n" +
 	"\vis_boundary\x18\x02 \x01(\bR\n" +
@@ -1917,7 +2285,7 @@ func file_receptor_v1_receptor_proto_rawDescGZIP() []byte {
 }
 
 var file_receptor_v1_receptor_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_receptor_v1_receptor_proto_msgTypes = make([]protoimpl.MessageInfo, 25)
+var file_receptor_v1_receptor_proto_msgTypes = make([]protoimpl.MessageInfo, 29)
 var file_receptor_v1_receptor_proto_goTypes = []any{
 	(EvidenceObjectType)(0),        // 0: receptor_v1.EvidenceObjectType
 	(*Finding)(nil),                // 1: receptor_v1.Finding
@@ -1938,16 +2306,21 @@ var file_receptor_v1_receptor_proto_goTypes = []any{
 	(*ReceptorOID)(nil),            // 16: receptor_v1.ReceptorOID
 	(*ReceptorConfiguration)(nil),  // 17: receptor_v1.ReceptorConfiguration
 	(*JobResult)(nil),              // 18: receptor_v1.JobResult
-	(*ReportChunk)(nil),            // 19: receptor_v1.ReportChunk
-	(*ReportResponse)(nil),         // 20: receptor_v1.ReportResponse
-	nil,                            // 21: receptor_v1.Document.MetadataEntry
-	nil,                            // 22: receptor_v1.Struct.ColDisplayNamesEntry
-	nil,                            // 23: receptor_v1.Struct.ColTagsEntry
-	nil,                            // 24: receptor_v1.Row.ColsEntry
-	nil,                            // 25: receptor_v1.StructStruct.FieldsEntry
-	(*timestamppb.Timestamp)(nil),  // 26: google.protobuf.Timestamp
-	(*emptypb.Empty)(nil),          // 27: google.protobuf.Empty
-	(*wrapperspb.StringValue)(nil), // 28: google.protobuf.StringValue
+	(*RunSummary)(nil),             // 19: receptor_v1.RunSummary
+	(*PhaseSummary)(nil),           // 20: receptor_v1.PhaseSummary
+	(*ServiceSummary)(nil),         // 21: receptor_v1.ServiceSummary
+	(*EvidenceSummary)(nil),        // 22: receptor_v1.EvidenceSummary
+	(*ReportChunk)(nil),            // 23: receptor_v1.ReportChunk
+	(*ReportResponse)(nil),         // 24: receptor_v1.ReportResponse
+	nil,                            // 25: receptor_v1.Document.MetadataEntry
+	nil,                            // 26: receptor_v1.Struct.ColDisplayNamesEntry
+	nil,                            // 27: receptor_v1.Struct.ColTagsEntry
+	nil,                            // 28: receptor_v1.Row.ColsEntry
+	nil,                            // 29: receptor_v1.StructStruct.FieldsEntry
+	(*timestamppb.Timestamp)(nil),  // 30: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),    // 31: google.protobuf.Duration
+	(*emptypb.Empty)(nil),          // 32: google.protobuf.Empty
+	(*wrapperspb.StringValue)(nil), // 33: google.protobuf.StringValue
 }
 var file_receptor_v1_receptor_proto_depIdxs = []int32{
 	14, // 0: receptor_v1.Finding.entities:type_name -> receptor_v1.ServiceEntity
@@ -1956,43 +2329,51 @@ var file_receptor_v1_receptor_proto_depIdxs = []int32{
 	5,  // 3: receptor_v1.Evidence.doc:type_name -> receptor_v1.Document
 	7,  // 4: receptor_v1.Evidence.struct:type_name -> receptor_v1.Struct
 	6,  // 5: receptor_v1.Evidence.docs:type_name -> receptor_v1.Documents
-	26, // 6: receptor_v1.Evidence.relevant_date:type_name -> google.protobuf.Timestamp
+	30, // 6: receptor_v1.Evidence.relevant_date:type_name -> google.protobuf.Timestamp
 	0,  // 7: receptor_v1.Evidence.evidence_object_type:type_name -> receptor_v1.EvidenceObjectType
-	3,  // 8: receptor_v1.Sources.sources:type_name -> receptor_v1.Source
-	26, // 9: receptor_v1.Document.last_modified:type_name -> google.protobuf.Timestamp
-	21, // 10: receptor_v1.Document.metadata:type_name -> receptor_v1.Document.MetadataEntry
-	5,  // 11: receptor_v1.Documents.docs:type_name -> receptor_v1.Document
-	8,  // 12: receptor_v1.Struct.rows:type_name -> receptor_v1.Row
-	22, // 13: receptor_v1.Struct.col_display_names:type_name -> receptor_v1.Struct.ColDisplayNamesEntry
-	23, // 14: receptor_v1.Struct.col_tags:type_name -> receptor_v1.Struct.ColTagsEntry
-	24, // 15: receptor_v1.Row.cols:type_name -> receptor_v1.Row.ColsEntry
-	26, // 16: receptor_v1.Value.timestamp_value:type_name -> google.protobuf.Timestamp
-	10, // 17: receptor_v1.Value.string_list_value:type_name -> receptor_v1.StringList
-	11, // 18: receptor_v1.Value.struct_list_value:type_name -> receptor_v1.StructList
-	12, // 19: receptor_v1.StructList.values:type_name -> receptor_v1.StructStruct
-	25, // 20: receptor_v1.StructStruct.fields:type_name -> receptor_v1.StructStruct.FieldsEntry
-	14, // 21: receptor_v1.ServiceEntities.entities:type_name -> receptor_v1.ServiceEntity
-	9,  // 22: receptor_v1.Row.ColsEntry.value:type_name -> receptor_v1.Value
-	9,  // 23: receptor_v1.StructStruct.FieldsEntry.value:type_name -> receptor_v1.Value
-	15, // 24: receptor_v1.Receptor.Verified:input_type -> receptor_v1.Credential
-	16, // 25: receptor_v1.Receptor.GetConfiguration:input_type -> receptor_v1.ReceptorOID
-	13, // 26: receptor_v1.Receptor.Discovered:input_type -> receptor_v1.ServiceEntities
-	1,  // 27: receptor_v1.Receptor.Report:input_type -> receptor_v1.Finding
-	18, // 28: receptor_v1.Receptor.Notify:input_type -> receptor_v1.JobResult
-	17, // 29: receptor_v1.Receptor.SetConfiguration:input_type -> receptor_v1.ReceptorConfiguration
-	19, // 30: receptor_v1.Receptor.StreamReport:input_type -> receptor_v1.ReportChunk
-	27, // 31: receptor_v1.Receptor.Verified:output_type -> google.protobuf.Empty
-	17, // 32: receptor_v1.Receptor.GetConfiguration:output_type -> receptor_v1.ReceptorConfiguration
-	28, // 33: receptor_v1.Receptor.Discovered:output_type -> google.protobuf.StringValue
-	28, // 34: receptor_v1.Receptor.Report:output_type -> google.protobuf.StringValue
-	27, // 35: receptor_v1.Receptor.Notify:output_type -> google.protobuf.Empty
-	27, // 36: receptor_v1.Receptor.SetConfiguration:output_type -> google.protobuf.Empty
-	20, // 37: receptor_v1.Receptor.StreamReport:output_type -> receptor_v1.ReportResponse
-	31, // [31:38] is the sub-list for method output_type
-	24, // [24:31] is the sub-list for method input_type
-	24, // [24:24] is the sub-list for extension type_name
-	24, // [24:24] is the sub-list for extension extendee
-	0,  // [0:24] is the sub-list for field type_name
+	30, // 8: receptor_v1.Evidence.unchanged_since:type_name -> google.protobuf.Timestamp
+	3,  // 9: receptor_v1.Sources.sources:type_name -> receptor_v1.Source
+	30, // 10: receptor_v1.Document.last_modified:type_name -> google.protobuf.Timestamp
+	25, // 11: receptor_v1.Document.metadata:type_name -> receptor_v1.Document.MetadataEntry
+	5,  // 12: receptor_v1.Documents.docs:type_name -> receptor_v1.Document
+	8,  // 13: receptor_v1.Struct.rows:type_name -> receptor_v1.Row
+	26, // 14: receptor_v1.Struct.col_display_names:type_name -> receptor_v1.Struct.ColDisplayNamesEntry
+	27, // 15: receptor_v1.Struct.col_tags:type_name -> receptor_v1.Struct.ColTagsEntry
+	28, // 16: receptor_v1.Row.cols:type_name -> receptor_v1.Row.ColsEntry
+	30, // 17: receptor_v1.Value.timestamp_value:type_name -> google.protobuf.Timestamp
+	10, // 18: receptor_v1.Value.string_list_value:type_name -> receptor_v1.StringList
+	11, // 19: receptor_v1.Value.struct_list_value:type_name -> receptor_v1.StructList
+	12, // 20: receptor_v1.StructList.values:type_name -> receptor_v1.StructStruct
+	29, // 21: receptor_v1.StructStruct.fields:type_name -> receptor_v1.StructStruct.FieldsEntry
+	14, // 22: receptor_v1.ServiceEntities.entities:type_name -> receptor_v1.ServiceEntity
+	19, // 23: receptor_v1.JobResult.summary:type_name -> receptor_v1.RunSummary
+	30, // 24: receptor_v1.RunSummary.started_at:type_name -> google.protobuf.Timestamp
+	31, // 25: receptor_v1.RunSummary.duration:type_name -> google.protobuf.Duration
+	20, // 26: receptor_v1.RunSummary.phases:type_name -> receptor_v1.PhaseSummary
+	21, // 27: receptor_v1.RunSummary.services:type_name -> receptor_v1.ServiceSummary
+	22, // 28: receptor_v1.RunSummary.evidences:type_name -> receptor_v1.EvidenceSummary
+	31, // 29: receptor_v1.PhaseSummary.duration:type_name -> google.protobuf.Duration
+	9,  // 30: receptor_v1.Row.ColsEntry.value:type_name -> receptor_v1.Value
+	9,  // 31: receptor_v1.StructStruct.FieldsEntry.value:type_name -> receptor_v1.Value
+	15, // 32: receptor_v1.Receptor.Verified:input_type -> receptor_v1.Credential
+	16, // 33: receptor_v1.Receptor.GetConfiguration:input_type -> receptor_v1.ReceptorOID
+	13, // 34: receptor_v1.Receptor.Discovered:input_type -> receptor_v1.ServiceEntities
+	1,  // 35: receptor_v1.Receptor.Report:input_type -> receptor_v1.Finding
+	18, // 36: receptor_v1.Receptor.Notify:input_type -> receptor_v1.JobResult
+	17, // 37: receptor_v1.Receptor.SetConfiguration:input_type -> receptor_v1.ReceptorConfiguration
+	23, // 38: receptor_v1.Receptor.StreamReport:input_type -> receptor_v1.ReportChunk
+	32, // 39: receptor_v1.Receptor.Verified:output_type -> google.protobuf.Empty
+	17, // 40: receptor_v1.Receptor.GetConfiguration:output_type -> receptor_v1.ReceptorConfiguration
+	33, // 41: receptor_v1.Receptor.Discovered:output_type -> google.protobuf.StringValue
+	33, // 42: receptor_v1.Receptor.Report:output_type -> google.protobuf.StringValue
+	32, // 43: receptor_v1.Receptor.Notify:output_type -> google.protobuf.Empty
+	32, // 44: receptor_v1.Receptor.SetConfiguration:output_type -> google.protobuf.Empty
+	24, // 45: receptor_v1.Receptor.StreamReport:output_type -> receptor_v1.ReportResponse
+	39, // [39:46] is the sub-list for method output_type
+	32, // [32:39] is the sub-list for method input_type
+	32, // [32:32] is the sub-list for extension type_name
+	32, // [32:32] is the sub-list for extension extendee
+	0,  // [0:32] is the sub-list for field type_name
 }
 
 func init() { file_receptor_v1_receptor_proto_init() }
@@ -2024,7 +2405,7 @@ func file_receptor_v1_receptor_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_receptor_v1_receptor_proto_rawDesc), len(file_receptor_v1_receptor_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   25,
+			NumMessages:   29,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
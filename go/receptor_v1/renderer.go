@@ -0,0 +1,271 @@
+// This file is subject to the terms and conditions defined in
+// file 'LICENSE.txt', which is part of this source code package.
+
+package receptor_v1
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Renderer renders a [Table] to a writer.
+type Renderer interface {
+	Render(w io.Writer, t *Table) error
+}
+
+// RendererFormats lists the formats of the renderers returned by [NewRenderer].
+var RendererFormats = []string{"text", "markdown", "csv", "json", "html"}
+
+// NewRenderer returns the renderer of a format: text, markdown, csv, json or html.
+func NewRenderer(format string) (Renderer, error) {
+	switch strings.ToLower(format) {
+	case "text", "":
+		return TextRenderer{}, nil
+	case "markdown", "md":
+		return MarkdownRenderer{}, nil
+	case "csv":
+		return CSVRenderer{}, nil
+	case "json":
+		return JSONRenderer{Indent: "  "}, nil
+	case "html":
+		return HTMLRenderer{}, nil
+	}
+	return nil, fmt.Errorf("unknown table format %q, expected one of %s", format, strings.Join(RendererFormats, ", "))
+}
+
+// TextRenderer renders a table as plain text, with its columns aligned and its header underlined.  Line breaks in
+// values are rendered as spaces.
+type TextRenderer struct{}
+
+// Render implements [Renderer.Render].
+func (TextRenderer) Render(w io.Writer, t *Table) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	flattener := strings.NewReplacer("\r\n", " ", "\n", " ", "\t", " ")
+	writeLine := func(cells []string) {
+		for i, cell := range cells {
+			cells[i] = flattener.Replace(cell)
+		}
+		_, _ = fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+
+	headers := t.Headers()
+	writeLine(headers)
+	underlines := make([]string, len(headers))
+	for i, header := range headers {
+		underlines[i] = strings.Repeat("-", len([]rune(header)))
+	}
+	writeLine(underlines)
+	for _, row := range t.Cells() {
+		writeLine(row)
+	}
+	return tw.Flush()
+}
+
+// MarkdownRenderer renders a table as a GitHub Flavored Markdown table.  Pipes and angle brackets in values are
+// escaped, so values aren't read as Markdown table syntax or HTML, and line breaks are rendered as <br>.
+type MarkdownRenderer struct{}
+
+// Render implements [Renderer.Render].
+func (MarkdownRenderer) Render(w io.Writer, t *Table) error {
+	escaper := strings.NewReplacer("|", `\|`, "<", "&lt;", ">", "&gt;", "\r\n", "<br>", "\n", "<br>")
+	writeLine := func(cells []string) error {
+		for i, cell := range cells {
+			cells[i] = escaper.Replace(cell)
+		}
+		_, err := fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+		return err
+	}
+
+	headers := t.Headers()
+	if err := writeLine(headers); err != nil {
+		return err
+	}
+	separators := make([]string, len(headers))
+	for i := range separators {
+		separators[i] = "---"
+	}
+	if err := writeLine(separators); err != nil {
+		return err
+	}
+	for _, row := range t.Cells() {
+		if err := writeLine(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CSVRenderer renders a table as RFC 4180 CSV, with a header record.
+type CSVRenderer struct {
+	Comma rune // Field delimiter.  Zero means ','.
+}
+
+// Render implements [Renderer.Render].
+func (r CSVRenderer) Render(w io.Writer, t *Table) error {
+	cw := csv.NewWriter(w)
+	if r.Comma != 0 {
+		cw.Comma = r.Comma
+	}
+	if err := cw.Write(t.Headers()); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(t.Cells()); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// JSONRenderer renders a table as a JSON object of its columns and rows.  Values keep their JSON type: numbers and
+// booleans are rendered as such, string lists as arrays of strings and struct lists as arrays of objects.
+// Timestamps are rendered as strings formatted with the table's options.  For example:
+//
+//	{
+//	  "columns": [{"key": "Username", "header": "User Name"}, {"key": "IsAdmin", "header": "Admin"}],
+//	  "rows": [{"entity_instance_id": "alice", "values": ["alice", true]}]
+//	}
+type JSONRenderer struct {
+	Indent string // Indent of nested JSON values.  Empty means compact JSON.
+}
+
+type jsonColumn struct {
+	Key    string `json:"key"`
+	Header string `json:"header"`
+}
+
+type jsonRow struct {
+	EntityInstanceId string        `json:"entity_instance_id"`
+	Values           []interface{} `json:"values"`
+}
+
+type jsonTable struct {
+	Columns []jsonColumn `json:"columns"`
+	Rows    []jsonRow    `json:"rows"`
+}
+
+// Render implements [Renderer.Render].
+func (r JSONRenderer) Render(w io.Writer, t *Table) error {
+	table := jsonTable{Columns: []jsonColumn{}, Rows: []jsonRow{}}
+	for _, column := range t.Columns {
+		table.Columns = append(table.Columns, jsonColumn{Key: column.Key, Header: column.Header})
+	}
+	for _, row := range t.Rows {
+		values := make([]interface{}, len(t.Columns))
+		for i, column := range t.Columns {
+			values[i] = t.JSONValue(row.GetCols()[column.Key])
+		}
+		table.Rows = append(table.Rows, jsonRow{EntityInstanceId: row.GetEntityInstanceId(), Values: values})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if len(r.Indent) > 0 {
+		encoder.SetIndent("", r.Indent)
+	}
+	return encoder.Encode(table)
+}
+
+// JSONValue returns a value as it's rendered by [JSONRenderer], for encoding with encoding/json.
+func (t *Table) JSONValue(value *Value) interface{} {
+	switch v := value.GetValueType().(type) {
+	case nil:
+		return nil
+	case *Value_DoubleValue:
+		return v.DoubleValue
+	case *Value_FloatValue:
+		return v.FloatValue
+	case *Value_Int32Value:
+		return v.Int32Value
+	case *Value_Int64Value:
+		return v.Int64Value
+	case *Value_Uint32Value:
+		return v.Uint32Value
+	case *Value_Uint64Value:
+		return v.Uint64Value
+	case *Value_BoolValue:
+		return v.BoolValue
+	case *Value_StringValue:
+		return v.StringValue
+	case *Value_TimestampValue:
+		return t.formatTime(v)
+	case *Value_StringListValue:
+		return append([]string{}, v.StringListValue.GetValues()...)
+	case *Value_StructListValue:
+		structs := []map[string]interface{}{}
+		for _, s := range v.StructListValue.GetValues() {
+			fields := map[string]interface{}{}
+			for key, field := range s.GetFields() {
+				fields[key] = t.JSONValue(field)
+			}
+			structs = append(structs, fields)
+		}
+		return structs
+	}
+	return nil
+}
+
+// HTMLRenderer renders a table as a standalone HTML document.  String lists are rendered as bulleted lists and
+// struct lists as nested tables of their fields, ordered by key.
+type HTMLRenderer struct {
+	Title string // Title of the document.  Empty means "Evidence".
+}
+
+const htmlStyle = `body{font-family:sans-serif}table{border-collapse:collapse}` +
+	`th,td{border:1px solid #999;padding:4px 8px;text-align:left;vertical-align:top}th{background:#eee}` +
+	`ul{margin:0;padding-left:1.2em}`
+
+// Render implements [Renderer.Render].
+func (r HTMLRenderer) Render(w io.Writer, t *Table) error {
+	title := r.Title
+	if len(title) == 0 {
+		title = "Evidence"
+	}
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n<style>%s</style>\n</head>\n<body>\n<table>\n<thead>\n<tr>", html.EscapeString(title), htmlStyle)
+	for _, header := range t.Headers() {
+		fmt.Fprintf(&b, "<th>%s</th>", html.EscapeString(header))
+	}
+	b.WriteString("</tr>\n</thead>\n<tbody>\n")
+	for _, row := range t.Rows {
+		b.WriteString("<tr>")
+		for _, column := range t.Columns {
+			b.WriteString("<td>")
+			t.writeHTML(&b, row.GetCols()[column.Key])
+			b.WriteString("</td>")
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</tbody>\n</table>\n</body>\n</html>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (t *Table) writeHTML(b *strings.Builder, value *Value) {
+	switch v := value.GetValueType().(type) {
+	case *Value_StringListValue:
+		b.WriteString("<ul>")
+		for _, s := range v.StringListValue.GetValues() {
+			fmt.Fprintf(b, "<li>%s</li>", html.EscapeString(s))
+		}
+		b.WriteString("</ul>")
+	case *Value_StructListValue:
+		for _, s := range v.StructListValue.GetValues() {
+			b.WriteString("<table>")
+			for _, key := range sortedKeys(s.GetFields()) {
+				fmt.Fprintf(b, "<tr><th>%s</th><td>", html.EscapeString(key))
+				t.writeHTML(b, s.GetFields()[key])
+				b.WriteString("</td></tr>")
+			}
+			b.WriteString("</table>")
+		}
+	default:
+		b.WriteString(strings.ReplaceAll(html.EscapeString(t.Format(value)), "\n", "<br>"))
+	}
+}
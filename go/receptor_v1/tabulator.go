@@ -5,104 +5,183 @@
 package receptor_v1
 
 import (
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
-const dateTimeLayout = "2006-Jan-02"
+// TableOptions control how the values of a [Table] are formatted.
+type TableOptions struct {
+	Layout   string         // Layout of timestamps, as in time.Time's Format.  Empty means time.RFC3339.
+	Location *time.Location // Location timestamps are shown in.  Nil means UTC.
+	True     string         // Text of a true boolean.  Empty means "true".
+	False    string         // Text of a false boolean.  Empty means "false".
+	Missing  string         // Text of an unset timestamp, one at or before the Unix epoch.  Empty means "".
+}
 
-// Tabulate converts a receptor_v1.Struct to an ordered and displayable array header strings, and an array of
-// rows of strings.  Each row's columns are ordered according to its headers in the headers array.
-func (s *Struct) Tabulate() (headers []string, rows [][]string, err error) {
-	headerKeys := s.getHeaderKeys()
+// Column is a column of a [Table].
+type Column struct {
+	Key    string // Key of the column in the rows' Cols.
+	Header string // Display name of the column.
+}
 
-	for _, row := range s.Rows {
-		cols := make([]string, len(headerKeys))
-		for i, key := range headerKeys {
-			if len(key) == 0 {
-				cols[i] = ""
-				continue
-			}
-			cols[i] = toStringValue(row.Cols[key])
+// Table is a receptor_v1.Struct laid out for display.  Columns in the struct's ColDisplayOrder come first, in that
+// order, followed by the other columns found in the struct's rows, ordered by key.
+type Table struct {
+	Columns []Column
+	Rows    []*Row
+	Options TableOptions
+}
+
+// NewTable lays out a receptor_v1.Struct for display with the given options.
+func (s *Struct) NewTable(opts TableOptions) *Table {
+	t := &Table{Rows: s.GetRows(), Options: opts}
+
+	seen := map[string]bool{}
+	addColumn := func(key string) {
+		if seen[key] {
+			return
 		}
-		rows = append(rows, cols)
+		seen[key] = true
+		header := key
+		if name, ok := s.GetColDisplayNames()[key]; ok {
+			header = name
+		}
+		t.Columns = append(t.Columns, Column{Key: key, Header: header})
 	}
 
-	// Get displayable headers
-	for _, h := range headerKeys {
-		name := h
-		if v, ok := s.ColDisplayNames[h]; ok {
-			name = v
+	for _, key := range s.GetColDisplayOrder() {
+		addColumn(key)
+	}
+	var unordered []string
+	found := map[string]bool{}
+	for _, row := range t.Rows {
+		for key := range row.GetCols() {
+			if !seen[key] && !found[key] {
+				found[key] = true
+				unordered = append(unordered, key)
+			}
 		}
-		headers = append(headers, name)
 	}
-
-	return
+	sort.Strings(unordered)
+	for _, key := range unordered {
+		addColumn(key)
+	}
+	return t
 }
 
-func (s *Struct) getHeaderKeys() (displayHeaders []string) {
-	if len(s.Rows) == 0 {
+// Tabulate converts a receptor_v1.Struct to an ordered and displayable array header strings, and an array of
+// rows of strings.  Each row's columns are ordered according to its headers in the headers array.  Tabulate keeps
+// the columns, date layout and boolean symbols of earlier versions, and returns no headers for a struct with no
+// rows; use [Struct.NewTable] for other options.
+func (s *Struct) Tabulate() (headers []string, rows [][]string, err error) {
+	if len(s.GetRows()) == 0 {
 		return
 	}
-
-	// Build displayHeader array of column key names
-	var allHeaders []string
-	for k, _ := range s.Rows[0].Cols {
-		allHeaders = append(allHeaders, k)
+	t := &Table{
+		Rows:    s.GetRows(),
+		Options: TableOptions{Layout: "2006-Jan-02", True: ":heavy_check_mark:", False: "-", Missing: "-"},
 	}
+	for _, key := range s.GetColDisplayOrder() {
+		header := key
+		if name, ok := s.GetColDisplayNames()[key]; ok {
+			header = name
+		}
+		t.Columns = append(t.Columns, Column{Key: key, Header: header})
+	}
+	return t.Headers(), t.Cells(), nil
+}
 
-	displayHeaders = s.ColDisplayOrder
-
+// Headers returns the display names of the table's columns.
+func (t *Table) Headers() (headers []string) {
+	for _, column := range t.Columns {
+		headers = append(headers, column.Header)
+	}
 	return
 }
 
-func toStringValue(value *Value) (str string) {
-	str = ""
-	v := value.GetValueType()
-	if dv, ok := v.(*Value_DoubleValue); ok {
-		str = strconv.FormatFloat(dv.DoubleValue, 'g', -1, 64)
-
-	} else if fv, ok := v.(*Value_FloatValue); ok {
-		str = strconv.FormatFloat(float64(fv.FloatValue), 'g', -1, 32)
-
-	} else if i, ok := v.(*Value_Int32Value); ok {
-		str = strconv.FormatInt(int64(i.Int32Value), 10)
-
-	} else if ii, ok := v.(*Value_Int64Value); ok {
-		str = strconv.FormatInt(ii.Int64Value, 10)
-
-	} else if ui, ok := v.(*Value_Uint32Value); ok {
-		str = strconv.FormatUint(uint64(ui.Uint32Value), 10)
-
-	} else if uii, ok := v.(*Value_Uint64Value); ok {
-		str = strconv.FormatUint(uii.Uint64Value, 10)
+// Cells returns the table's rows of values formatted as text, ordered as the table's columns.
+func (t *Table) Cells() (rows [][]string) {
+	for _, row := range t.Rows {
+		cols := make([]string, len(t.Columns))
+		for i, column := range t.Columns {
+			cols[i] = t.Format(row.GetCols()[column.Key])
+		}
+		rows = append(rows, cols)
+	}
+	return
+}
 
-	} else if b, ok := v.(*Value_BoolValue); ok {
-		if b.BoolValue {
-			str = ":heavy_check_mark:"
-		} else {
-			str = "-"
+// Format formats a value as text.  A string list is formatted as its values separated by ", ", and a struct list
+// as its structs separated by ", ", each struct formatted as "{key: value, ...}" with its fields ordered by key.
+func (t *Table) Format(value *Value) string {
+	switch v := value.GetValueType().(type) {
+	case *Value_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64)
+	case *Value_FloatValue:
+		return strconv.FormatFloat(float64(v.FloatValue), 'g', -1, 32)
+	case *Value_Int32Value:
+		return strconv.FormatInt(int64(v.Int32Value), 10)
+	case *Value_Int64Value:
+		return strconv.FormatInt(v.Int64Value, 10)
+	case *Value_Uint32Value:
+		return strconv.FormatUint(uint64(v.Uint32Value), 10)
+	case *Value_Uint64Value:
+		return strconv.FormatUint(v.Uint64Value, 10)
+	case *Value_BoolValue:
+		return t.formatBool(v.BoolValue)
+	case *Value_StringValue:
+		return strings.TrimSpace(v.StringValue)
+	case *Value_TimestampValue:
+		return t.formatTime(v)
+	case *Value_StringListValue:
+		return strings.Join(v.StringListValue.GetValues(), ", ")
+	case *Value_StructListValue:
+		structs := make([]string, 0, len(v.StructListValue.GetValues()))
+		for _, s := range v.StructListValue.GetValues() {
+			var fields []string
+			for _, key := range sortedKeys(s.GetFields()) {
+				fields = append(fields, key+": "+t.Format(s.GetFields()[key]))
+			}
+			structs = append(structs, "{"+strings.Join(fields, ", ")+"}")
 		}
+		return strings.Join(structs, ", ")
+	}
+	return ""
+}
 
-	} else if s, ok := v.(*Value_StringValue); ok {
-		str = strings.TrimSpace(s.StringValue)
+func (t *Table) formatBool(b bool) string {
+	switch {
+	case b && len(t.Options.True) > 0:
+		return t.Options.True
+	case !b && len(t.Options.False) > 0:
+		return t.Options.False
+	}
+	return strconv.FormatBool(b)
+}
 
-	} else if t, ok := v.(*Value_TimestampValue); ok {
-		dt := t.TimestampValue
-		if dt == nil || dt.Seconds <= 0 {
-			str = "-"
-		} else {
-			str = dt.AsTime().Format(dateTimeLayout)
-		}
+func (t *Table) formatTime(v *Value_TimestampValue) string {
+	ts := v.TimestampValue
+	if ts == nil || ts.Seconds <= 0 {
+		return t.Options.Missing
 	}
-	return
+	layout := t.Options.Layout
+	if len(layout) == 0 {
+		layout = time.RFC3339
+	}
+	location := t.Options.Location
+	if location == nil {
+		location = time.UTC
+	}
+	return ts.AsTime().In(location).Format(layout)
 }
 
-func contains(s []string, e string) bool {
-	for _, a := range s {
-		if a == e {
-			return true
-		}
+func sortedKeys(fields map[string]*Value) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
 	}
-	return false
+	sort.Strings(keys)
+	return keys
 }